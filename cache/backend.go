@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Backend stores the cached responses. A ttl of 0 means the value doesn't expire, but the backend can still evict it.
+//
+// The in-memory backend is used by default. An external backend shared by several API instances can be plugged in with New
+type Backend interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte, ttl time.Duration)
+}
+
+type memoryEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// MemoryBackend is an in-memory LRU backend with a maximum number of entries
+type MemoryBackend struct {
+	maxEntries int
+	mutex      sync.Mutex
+	entries    map[string]*list.Element
+	order      *list.List
+}
+
+// NewMemoryBackend creates an in-memory backend which evicts the least recently used entries after maxEntries
+func NewMemoryBackend(maxEntries int) *MemoryBackend {
+	return &MemoryBackend{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// Get returns the value of the key if it exists and hasn't expired
+func (b *MemoryBackend) Get(key string) ([]byte, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	element, ok := b.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*memoryEntry)
+	if !entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt) {
+		b.order.Remove(element)
+		delete(b.entries, key)
+		return nil, false
+	}
+	b.order.MoveToFront(element)
+	return entry.value, true
+}
+
+// Set stores the value and evicts the least recently used entries if the backend is full
+func (b *MemoryBackend) Set(key string, value []byte, ttl time.Duration) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	entry := &memoryEntry{key: key, value: value}
+	if ttl > 0 {
+		entry.expiresAt = time.Now().Add(ttl)
+	}
+	if element, ok := b.entries[key]; ok {
+		element.Value = entry
+		b.order.MoveToFront(element)
+		return
+	}
+	b.entries[key] = b.order.PushFront(entry)
+
+	for b.order.Len() > b.maxEntries {
+		oldest := b.order.Back()
+		b.order.Remove(oldest)
+		delete(b.entries, oldest.Value.(*memoryEntry).key)
+	}
+}
@@ -0,0 +1,120 @@
+// Package cache caches API responses and invalidates them when the indexer changes the polymorphs they contain.
+package cache
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const DEFAULT_CACHE_ENTRIES = 10000
+const DEFAULT_CACHE_TTL = 5 * time.Minute
+
+const generationKeyPrefix = "generation:"
+
+// ResponseCache caches responses by key and tags.
+//
+// Every tag has a generation stored in the backend and the generations of its tags are part of the key of a response.
+// Invalidating a tag changes its generation, so the responses with the tag aren't found anymore and expire from the backend.
+// Generations are stored in the backend too, so API instances sharing an external backend see each other's invalidations.
+//
+// A nil *ResponseCache is valid and caches nothing
+type ResponseCache struct {
+	backend Backend
+	ttl     time.Duration
+}
+
+// New creates a response cache. Responses expire after ttl even if they aren't invalidated
+func New(backend Backend, ttl time.Duration) *ResponseCache {
+	return &ResponseCache{backend: backend, ttl: ttl}
+}
+
+// NewFromEnv creates an in-memory response cache from the .env variables.
+//
+// RESPONSE_CACHE_ENTRIES is the maximum number of cached responses, 0 disables the cache. RESPONSE_CACHE_TTL_SECONDS is the maximum age of a response
+func NewFromEnv() *ResponseCache {
+	entries := envInt("RESPONSE_CACHE_ENTRIES", DEFAULT_CACHE_ENTRIES)
+	if entries == 0 {
+		return nil
+	}
+	ttl := DEFAULT_CACHE_TTL
+	if seconds := envInt("RESPONSE_CACHE_TTL_SECONDS", 0); seconds > 0 {
+		ttl = time.Duration(seconds) * time.Second
+	}
+	return New(NewMemoryBackend(entries), ttl)
+}
+
+func envInt(name string, defaultValue int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 0 {
+		log.Fatalf("Invalid %v: %v", name, value)
+	}
+	return parsed
+}
+
+// Key returns the cache key of the response with the current generations of the tags.
+//
+// The key must be created before the response is read from the database. An invalidation during the read then changes the generation and the stale response is stored under an outdated key
+func (c *ResponseCache) Key(key string, tags ...string) string {
+	if c == nil {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(key)
+	for _, tag := range tags {
+		b.WriteString("|")
+		b.WriteString(c.generation(tag))
+	}
+	return b.String()
+}
+
+// Get returns the cached response of the key created by Key
+func (c *ResponseCache) Get(key string) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+	return c.backend.Get(key)
+}
+
+// Set caches the response under the key created by Key
+func (c *ResponseCache) Set(key string, value []byte) {
+	if c == nil {
+		return
+	}
+	c.backend.Set(key, value, c.ttl)
+}
+
+// Invalidate drops the responses with any of the tags
+func (c *ResponseCache) Invalidate(tags ...string) {
+	if c == nil {
+		return
+	}
+	for _, tag := range tags {
+		c.backend.Set(generationKeyPrefix+tag, newGeneration(), 0)
+	}
+}
+
+// generation returns the generation of the tag. Tags without generation, e.g. evicted ones, get a new generation, so they never match responses cached before
+func (c *ResponseCache) generation(tag string) string {
+	if generation, ok := c.backend.Get(generationKeyPrefix + tag); ok {
+		return string(generation)
+	}
+	generation := newGeneration()
+	c.backend.Set(generationKeyPrefix+tag, generation, 0)
+	return string(generation)
+}
+
+var generationCounter uint64
+
+// newGeneration returns a generation which is unique in the process and very unlikely to be used by other processes
+func newGeneration() []byte {
+	count := atomic.AddUint64(&generationCounter, 1)
+	return []byte(strconv.FormatInt(time.Now().UnixNano(), 36) + "-" + strconv.FormatUint(count, 36))
+}
@@ -0,0 +1,26 @@
+package cache
+
+import (
+	"rarity-backend/structs"
+	"strconv"
+)
+
+// Namespace separates the responses of the collections. It's built from the database information, which the API and the indexer both know
+func Namespace(dbInfo structs.DBInfo) string {
+	return dbInfo.PolymorphDBName + "/" + dbInfo.RarityCollectionName
+}
+
+// ListTag tags the responses which list polymorphs. Any change to any polymorph invalidates them
+func ListTag(namespace string) string {
+	return namespace + ":list"
+}
+
+// TokenTag tags the responses of a single polymorph
+func TokenTag(namespace string, tokenId int) string {
+	return namespace + ":token:" + strconv.Itoa(tokenId)
+}
+
+// HistoryTag tags the history responses of a single polymorph
+func HistoryTag(namespace string, tokenId int) string {
+	return namespace + ":history:" + strconv.Itoa(tokenId)
+}
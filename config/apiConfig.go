@@ -2,25 +2,57 @@ package config
 
 import "rarity-backend/constants"
 
-var SEARCH_QUERY_FIELDS []string = []string{
+// SEARCH_NUMBER_FIELDS are matched exactly when the search is a number
+var SEARCH_NUMBER_FIELDS []string = []string{
 	constants.MorphFieldNames.Rank,
 	constants.MorphFieldNames.TokenId,
 	constants.MorphFieldNames.RarityScore,
-	constants.MorphFieldNames.Headwear,
-	constants.MorphFieldNames.Eyewear,
-	constants.MorphFieldNames.Torso,
-	constants.MorphFieldNames.Pants,
-	constants.MorphFieldNames.Footwear,
-	constants.MorphFieldNames.LeftHand,
-	constants.MorphFieldNames.RightHand,
-	constants.MorphFieldNames.Character,
-	constants.MorphFieldNames.MainSetName,
-	constants.MorphFieldNames.SecSetName,
 }
 
+// SEARCH_TEXT_FIELDS are the fields of the text index with their relevance weights
+var SEARCH_TEXT_FIELDS map[string]int32 = map[string]int32{
+	constants.MorphFieldNames.Character:   10,
+	constants.MorphFieldNames.MainSetName: 8,
+	constants.MorphFieldNames.SecSetName:  4,
+	constants.MorphFieldNames.Headwear:    5,
+	constants.MorphFieldNames.Eyewear:     5,
+	constants.MorphFieldNames.Torso:       5,
+	constants.MorphFieldNames.Pants:       5,
+	constants.MorphFieldNames.Footwear:    5,
+	constants.MorphFieldNames.LeftHand:    5,
+	constants.MorphFieldNames.RightHand:   5,
+}
+
+// SEARCH_INDEX_NAME is the name of the text index of the polymorphs collections
+const SEARCH_INDEX_NAME = "polymorph_search"
+
+// SEARCH_SCORE_FIELD is the field of the text search relevance score in the results
+const SEARCH_SCORE_FIELD = "score"
+
 var MORPHS_NO_PROJECTION_FIELDS []string = []string{
 	constants.MorphFieldNames.ObjId,
 	constants.MorphFieldNames.OldGenes,
 }
 
-const RESULTS_LIMIT int64 = 10000
+// RESULTS_LIMIT is the maximum number of polymorphs returned in a single page
+const RESULTS_LIMIT int64 = 1000
+
+const DEFAULT_PAGE_SIZE int64 = 100
+
+// GRAPHQL_MAX_DEPTH is the maximum number of nested selections of a GraphQL query
+const GRAPHQL_MAX_DEPTH = 8
+
+// GRAPHQL_MAX_COMPLEXITY is the maximum number of fields a GraphQL query can resolve, counting every item of the lists
+const GRAPHQL_MAX_COMPLEXITY = 50000
+
+// GRAPHQL_OWNER_COST is the complexity of the owner field of a polymorph, which calls the node. It limits a query to GRAPHQL_MAX_COMPLEXITY / GRAPHQL_OWNER_COST owners
+const GRAPHQL_OWNER_COST = 100
+
+// GRAPHQL_OWNER_WORKERS is the number of concurrent owner requests a GraphQL query sends to the node
+const GRAPHQL_OWNER_WORKERS = 8
+
+// TOP_SPENDERS_DEFAULT_SIZE is the number of tokens or wallets returned by the morph economics leaderboards if no take is passed
+const TOP_SPENDERS_DEFAULT_SIZE int64 = 10
+
+// TOP_SPENDERS_LIMIT is the maximum number of tokens or wallets returned by the morph economics leaderboards
+const TOP_SPENDERS_LIMIT int64 = 100
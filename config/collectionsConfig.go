@@ -0,0 +1,199 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"rarity-backend/structs"
+	"sync"
+)
+
+const DEFAULT_CONFIG_PATH = "./config.json"
+const DEFAULT_RARITY_MODEL = "default"
+
+var collectionsMutex sync.RWMutex
+var collections = map[string]structs.Collection{}
+var defaultCollection string
+
+// NewCollectionsConfig returns the collections which should be indexed by the application.
+//
+// If COLLECTIONS_CONFIG is set in .env, the collections are read from the json file. Otherwise a single collection is built from the .env variables.
+func NewCollectionsConfig() []structs.Collection {
+	var result []structs.Collection
+
+	if configPath := os.Getenv("COLLECTIONS_CONFIG"); configPath != "" {
+		jsonFile, err := os.Open(configPath)
+		if err != nil {
+			log.Fatal("Missing collections config file")
+		}
+		defer jsonFile.Close()
+
+		byteValue, _ := ioutil.ReadAll(jsonFile)
+		if err := json.Unmarshal(byteValue, &result); err != nil {
+			log.Fatal("Invalid collections config file: " + err.Error())
+		}
+	} else {
+		result = append(result, CollectionFromEnv())
+	}
+
+	if len(result) == 0 {
+		log.Fatal("No collections configured")
+	}
+
+	for i := range result {
+		validateCollection(&result[i])
+	}
+	if err := checkImageLocations(result, os.Getenv("IMAGE_STORAGE")); err != nil {
+		log.Fatal(err)
+	}
+
+	return result
+}
+
+// CollectionFromEnv builds the single collection described in .env. Optional fields are left empty
+func CollectionFromEnv() structs.Collection {
+	return structs.Collection{
+		Name:            os.Getenv("COLLECTION_NAME"),
+		ContractAddress: os.Getenv("CONTRACT_ADDRESS"),
+		ConfigPath:      os.Getenv("CONFIG_PATH"),
+		RarityModel:     os.Getenv("RARITY_MODEL"),
+		DBInfo:          dbInfoFromEnv(),
+	}
+}
+
+// dbInfoFromEnv builds the database information of the single collection described in .env
+func dbInfoFromEnv() structs.DBInfo {
+	return structs.DBInfo{
+		PolymorphDBName:                 os.Getenv("POLYMORPH_DB"),
+		RarityCollectionName:            os.Getenv("RARITY_COLLECTION"),
+		TransactionsCollectionName:      os.Getenv("TRANSACTIONS_COLLECTION"),
+		BlocksCollectionName:            os.Getenv("BLOCKS_COLLECTION"),
+		HistoryCollectionName:           os.Getenv("HISTORY_COLLECTION"),
+		MorphCostCollectionName:         os.Getenv("MORPH_COST_COLLECTION"),
+		RankHistoryCollectionName:       os.Getenv("RANK_HISTORY_COLLECTION"),
+		WebhooksCollectionName:          os.Getenv("WEBHOOKS_COLLECTION"),
+		WebhookDeliveriesCollectionName: os.Getenv("WEBHOOK_DELIVERIES_COLLECTION"),
+		MorphStatsCollectionName:        os.Getenv("MORPH_STATS_COLLECTION"),
+	}
+}
+
+// validateCollection fills the optional fields with defaults and stops the application if a required field is missing
+func validateCollection(collection *structs.Collection) {
+	if collection.Name == "" {
+		collection.Name = "polymorphs"
+	}
+	if collection.ConfigPath == "" {
+		collection.ConfigPath = DEFAULT_CONFIG_PATH
+	}
+	if collection.RarityModel == "" {
+		collection.RarityModel = DEFAULT_RARITY_MODEL
+	}
+	if collection.Images.SourceDir == "" {
+		collection.Images.SourceDir = os.Getenv("IMAGE_SOURCE_DIR")
+	}
+	if collection.Images.OutputDir == "" {
+		collection.Images.OutputDir = os.Getenv("IMAGE_OUTPUT_DIR")
+	}
+	if collection.Images.CacheDir == "" {
+		collection.Images.CacheDir = os.Getenv("IMAGE_CACHE_DIR")
+	}
+	if _, ok := RarityModels[collection.RarityModel]; !ok {
+		log.Fatalf("Unknown rarity model %v for collection %v", collection.RarityModel, collection.Name)
+	}
+
+	if collection.ContractAddress == "" {
+		log.Fatalf("Missing contract address for collection %v", collection.Name)
+	}
+	if collection.DBInfo.PolymorphDBName == "" {
+		log.Fatalf("Missing polymorph db name for collection %v", collection.Name)
+	}
+	if collection.DBInfo.RarityCollectionName == "" {
+		log.Fatalf("Missing rarity collection name for collection %v", collection.Name)
+	}
+	if collection.DBInfo.BlocksCollectionName == "" {
+		log.Fatalf("Missing block collection name for collection %v", collection.Name)
+	}
+	if collection.DBInfo.TransactionsCollectionName == "" {
+		log.Fatalf("Missing transactions collection name for collection %v", collection.Name)
+	}
+	if collection.DBInfo.HistoryCollectionName == "" {
+		log.Fatalf("Missing morph history collection name for collection %v", collection.Name)
+	}
+	if collection.DBInfo.MorphCostCollectionName == "" {
+		log.Fatalf("Missing morph cost collection name for collection %v", collection.Name)
+	}
+}
+
+// checkImageLocations returns an error if collections with different image layers store their rendered images or cached variants in the same place, because the images are named by their layers.
+//
+// storage is the IMAGE_STORAGE of .env. Empty buckets are the polymorph buckets
+func checkImageLocations(collections []structs.Collection, storage string) error {
+	if storage == "" {
+		return nil
+	}
+
+	source := func(images structs.Images) string {
+		if storage == "gcs" {
+			return images.SourceBucket
+		}
+		return images.SourceDir
+	}
+	output := func(images structs.Images) string {
+		if storage == "gcs" {
+			return images.UploadBucket
+		}
+		return images.OutputDir
+	}
+
+	for i, a := range collections {
+		for _, b := range collections[i+1:] {
+			if source(a.Images) == source(b.Images) {
+				continue
+			}
+			if output(a.Images) == output(b.Images) {
+				return fmt.Errorf("Collections %v and %v have different image layers but store their images in the same place", a.Name, b.Name)
+			}
+			if a.Images.CacheDir != "" && a.Images.CacheDir == b.Images.CacheDir {
+				return fmt.Errorf("Collections %v and %v have different image layers but share the image cache dir", a.Name, b.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// RegisterCollection makes the collection available to the API. The first registered collection is used by the routes without collection parameter.
+func RegisterCollection(collection structs.Collection) {
+	collectionsMutex.Lock()
+	defer collectionsMutex.Unlock()
+
+	if defaultCollection == "" {
+		defaultCollection = collection.Name
+	}
+	collections[collection.Name] = collection
+}
+
+// GetCollection returns the registered collection with the passed name. Empty name returns the default collection.
+func GetCollection(name string) (structs.Collection, bool) {
+	collectionsMutex.RLock()
+	defer collectionsMutex.RUnlock()
+
+	if name == "" {
+		name = defaultCollection
+	}
+	collection, ok := collections[name]
+	return collection, ok
+}
+
+// GetCollections returns all registered collections
+func GetCollections() []structs.Collection {
+	collectionsMutex.RLock()
+	defer collectionsMutex.RUnlock()
+
+	result := make([]structs.Collection, 0, len(collections))
+	for _, collection := range collections {
+		result = append(result, collection)
+	}
+	return result
+}
@@ -0,0 +1,187 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"rarity-backend/structs"
+	"reflect"
+	"testing"
+)
+
+// setEnv sets the env variable for the test and restores its previous value afterwards
+func setEnv(t *testing.T, name string, value string) {
+	previous, ok := os.LookupEnv(name)
+	os.Setenv(name, value)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(name, previous)
+		} else {
+			os.Unsetenv(name)
+		}
+	})
+}
+
+var testDBInfo = structs.DBInfo{
+	PolymorphDBName:            "polymorphs",
+	RarityCollectionName:       "rarities",
+	TransactionsCollectionName: "transactions",
+	BlocksCollectionName:       "blocks",
+	HistoryCollectionName:      "history",
+	MorphCostCollectionName:    "morph-cost",
+}
+
+func TestNewCollectionsConfigFromFile(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "collections.json")
+	err := ioutil.WriteFile(configPath, []byte(`[
+		{
+			"name": "polymorphs",
+			"contractAddress": "0x1",
+			"db": {
+				"polymorphDb": "polymorphs",
+				"rarityCollection": "rarities",
+				"transactionsCollection": "transactions",
+				"blocksCollection": "blocks",
+				"historyCollection": "history",
+				"morphCostCollection": "morph-cost"
+			}
+		},
+		{
+			"name": "polymorphs-v2",
+			"contractAddress": "0x2",
+			"configPath": "./config-v2.json",
+			"rarityModel": "new-sets",
+			"db": {
+				"polymorphDb": "polymorphs-v2",
+				"rarityCollection": "rarities",
+				"transactionsCollection": "transactions",
+				"blocksCollection": "blocks",
+				"historyCollection": "history",
+				"morphCostCollection": "morph-cost",
+				"rankHistoryCollection": "rank-history"
+			},
+			"images": {"sourceDir": "./images/v2", "outputDir": "./rendered/v2", "cacheDir": "./cache/v2"}
+		}
+	]`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setEnv(t, "COLLECTIONS_CONFIG", configPath)
+	setEnv(t, "IMAGE_STORAGE", "local")
+	setEnv(t, "IMAGE_SOURCE_DIR", "./images")
+	setEnv(t, "IMAGE_OUTPUT_DIR", "./rendered")
+	setEnv(t, "IMAGE_CACHE_DIR", "")
+	// The single collection variables are ignored if COLLECTIONS_CONFIG is set
+	setEnv(t, "COLLECTION_NAME", "ignored")
+
+	v2DBInfo := testDBInfo
+	v2DBInfo.PolymorphDBName = "polymorphs-v2"
+	v2DBInfo.RankHistoryCollectionName = "rank-history"
+	expected := []structs.Collection{
+		{
+			Name:            "polymorphs",
+			ContractAddress: "0x1",
+			ConfigPath:      DEFAULT_CONFIG_PATH,
+			RarityModel:     DEFAULT_RARITY_MODEL,
+			DBInfo:          testDBInfo,
+			Images:          structs.Images{SourceDir: "./images", OutputDir: "./rendered"},
+		},
+		{
+			Name:            "polymorphs-v2",
+			ContractAddress: "0x2",
+			ConfigPath:      "./config-v2.json",
+			RarityModel:     "new-sets",
+			DBInfo:          v2DBInfo,
+			Images:          structs.Images{SourceDir: "./images/v2", OutputDir: "./rendered/v2", CacheDir: "./cache/v2"},
+		},
+	}
+
+	collections := NewCollectionsConfig()
+	if !reflect.DeepEqual(collections, expected) {
+		t.Errorf("Got %+v, expected %+v", collections, expected)
+	}
+}
+
+func TestNewCollectionsConfigFromEnv(t *testing.T) {
+	env := map[string]string{
+		"COLLECTIONS_CONFIG":      "",
+		"COLLECTION_NAME":         "",
+		"CONTRACT_ADDRESS":        "0x1",
+		"CONFIG_PATH":             "",
+		"RARITY_MODEL":            "",
+		"POLYMORPH_DB":            "polymorphs",
+		"RARITY_COLLECTION":       "rarities",
+		"TRANSACTIONS_COLLECTION": "transactions",
+		"BLOCKS_COLLECTION":       "blocks",
+		"HISTORY_COLLECTION":      "history",
+		"MORPH_COST_COLLECTION":   "morph-cost",
+		"RANK_HISTORY_COLLECTION": "",
+		"WEBHOOKS_COLLECTION":     "webhooks",
+		"IMAGE_STORAGE":           "local",
+		"IMAGE_SOURCE_DIR":        "./images",
+		"IMAGE_OUTPUT_DIR":        "./rendered",
+		"IMAGE_CACHE_DIR":         "./cache",
+	}
+	for name, value := range env {
+		setEnv(t, name, value)
+	}
+
+	dbInfo := testDBInfo
+	dbInfo.WebhooksCollectionName = "webhooks"
+	expected := []structs.Collection{{
+		Name:            "polymorphs",
+		ContractAddress: "0x1",
+		ConfigPath:      DEFAULT_CONFIG_PATH,
+		RarityModel:     DEFAULT_RARITY_MODEL,
+		DBInfo:          dbInfo,
+		Images:          structs.Images{SourceDir: "./images", OutputDir: "./rendered", CacheDir: "./cache"},
+	}}
+
+	collections := NewCollectionsConfig()
+	if !reflect.DeepEqual(collections, expected) {
+		t.Errorf("Got %+v, expected %+v", collections, expected)
+	}
+}
+
+func TestCheckImageLocations(t *testing.T) {
+	v1 := structs.Images{SourceDir: "./images/v1", OutputDir: "./rendered/v1", CacheDir: "./cache/v1", SourceBucket: "v1-layers", UploadBucket: "v1-images"}
+	v2 := structs.Images{SourceDir: "./images/v2", OutputDir: "./rendered/v2", CacheDir: "./cache/v2", SourceBucket: "v2-layers", UploadBucket: "v2-images"}
+	sharedOutput := v2
+	sharedOutput.OutputDir = v1.OutputDir
+	sharedOutput.UploadBucket = v1.UploadBucket
+	sharedCache := v2
+	sharedCache.CacheDir = v1.CacheDir
+	sameLayers := v1
+	noCache := v2
+	noCache.CacheDir = ""
+	noCacheV1 := v1
+	noCacheV1.CacheDir = ""
+	defaultBuckets := structs.Images{SourceDir: "./images/v2", OutputDir: "./rendered/v2"}
+
+	tests := []struct {
+		name    string
+		storage string
+		a       structs.Images
+		b       structs.Images
+		valid   bool
+	}{
+		{"separate locations", "local", v1, v2, true},
+		{"shared output", "local", v1, sharedOutput, false},
+		{"shared upload bucket", "gcs", v1, sharedOutput, false},
+		{"shared cache", "local", v1, sharedCache, false},
+		{"shared cache with buckets", "gcs", v1, sharedCache, false},
+		{"same layers can share everything", "local", v1, sameLayers, true},
+		{"no cache", "local", noCacheV1, noCache, true},
+		{"default buckets are shared", "gcs", structs.Images{}, defaultBuckets, true},
+		{"different layers with default upload bucket", "gcs", structs.Images{SourceBucket: "v1-layers"}, defaultBuckets, false},
+		{"rendering disabled", "", v1, sharedOutput, true},
+	}
+
+	for _, test := range tests {
+		collections := []structs.Collection{{Name: "v1", Images: test.a}, {Name: "v2", Images: test.b}}
+		err := checkImageLocations(collections, test.storage)
+		if (err == nil) != test.valid {
+			t.Errorf("%v: got %v, expected valid %v", test.name, err, test.valid)
+		}
+	}
+}
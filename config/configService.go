@@ -5,12 +5,17 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"rarity-backend/constants"
+	"rarity-backend/genome"
 	"rarity-backend/structs"
 )
 
 // NewConfigService tries to read the configarion file which should countain information for each trait, attribute and possible sets.
 //
-// The configuration json MUST be modified with great care. No reordering of the elemets is allowed because it messes the logic of fetching attributes in services/metadata.go.
+// The "genome" section describes the gene layout. Each slot references one of the trait lists by name, so a new trait needs only a new list and a new slot.
+// The configuration is parsed and validated by the genome package.
+//
+// The configuration json MUST be modified with great care. No reordering of the list elemets is allowed because the decoded gene is used as an index in them.
 //
 // Returns ConfigService object containing the configuration
 func NewConfigService(configPath string) *structs.ConfigService {
@@ -28,5 +33,20 @@ func NewConfigService(configPath string) *structs.ConfigService {
 
 	json.Unmarshal(byteValue, &service)
 
+	decoderConfig, err := genome.ParseConfig(byteValue)
+	if err != nil {
+		log.Fatal(err)
+	}
+	decoderConfig.CharacterTrait = constants.MorphAttriutes.Character
+	decoderConfig.ImageURL = constants.POLYMORPH_IMAGE_URL
+	decoderConfig.ExternalURL = constants.EXTERNAL_URL
+
+	decoder, err := genome.NewDecoder(decoderConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	service.Traits = decoderConfig.Lists
+	service.Decoder = decoder
+
 	return &service
 }
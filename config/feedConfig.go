@@ -0,0 +1,15 @@
+package config
+
+import "time"
+
+// FEED_RANK_CHANGE_THRESHOLD is the minimum number of places a polymorph has to move in the ranking for a rank change event
+const FEED_RANK_CHANGE_THRESHOLD = 100
+
+// FEED_HISTORY_SIZE is the number of recent events kept for clients which reconnect to the feed
+const FEED_HISTORY_SIZE = 1000
+
+// FEED_SUBSCRIBER_BUFFER is the number of events a feed client can fall behind before it's disconnected
+const FEED_SUBSCRIBER_BUFFER = 256
+
+// FEED_KEEPALIVE_INTERVAL is the interval of the comments sent to idle feed clients, so proxies don't close the connection
+const FEED_KEEPALIVE_INTERVAL = 15 * time.Second
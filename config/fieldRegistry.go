@@ -0,0 +1,118 @@
+package config
+
+import (
+	"log"
+	"rarity-backend/constants"
+	"rarity-backend/structs"
+	"reflect"
+)
+
+const FIELD_TYPE_INT = "int"
+const FIELD_TYPE_FLOAT = "float"
+const FIELD_TYPE_BOOL = "bool"
+const FIELD_TYPE_STRING = "string"
+const FIELD_TYPE_STRING_ARRAY = "string[]"
+
+// FIELD_TYPE_OPERATORS are the filter operators supported by each field type
+var FIELD_TYPE_OPERATORS = map[string][]string{
+	FIELD_TYPE_INT:          {"eq", "ne", "lt", "lte", "gt", "gte", "in", "nin", "exists"},
+	FIELD_TYPE_FLOAT:        {"eq", "ne", "lt", "lte", "gt", "gte", "in", "nin", "exists"},
+	FIELD_TYPE_BOOL:         {"eq", "ne", "exists"},
+	FIELD_TYPE_STRING:       {"eq", "ne", "in", "nin", "exists", "contains"},
+	FIELD_TYPE_STRING_ARRAY: {"eq", "ne", "in", "nin", "exists", "contains"},
+}
+
+// MORPH_FIELDS is the registry of the polymorph fields which can be used in API queries. Fields missing from the registry are rejected.
+//
+// Private fields are never returned by the API and can't be filtered or sorted on. Facet counts can be requested for facetable fields
+var MORPH_FIELDS = newFieldRegistry(
+	field(constants.MorphFieldNames.ObjId, FIELD_TYPE_STRING, false, false),
+	field(constants.MorphFieldNames.TokenId, FIELD_TYPE_INT, true, true),
+	field(constants.MorphFieldNames.Rank, FIELD_TYPE_INT, true, true),
+	field(constants.MorphFieldNames.CurrentGene, FIELD_TYPE_STRING, false, true),
+	facetable(field(constants.MorphFieldNames.Headwear, FIELD_TYPE_STRING, true, true)),
+	facetable(field(constants.MorphFieldNames.Eyewear, FIELD_TYPE_STRING, true, true)),
+	facetable(field(constants.MorphFieldNames.Torso, FIELD_TYPE_STRING, true, true)),
+	facetable(field(constants.MorphFieldNames.Pants, FIELD_TYPE_STRING, true, true)),
+	facetable(field(constants.MorphFieldNames.Footwear, FIELD_TYPE_STRING, true, true)),
+	facetable(field(constants.MorphFieldNames.LeftHand, FIELD_TYPE_STRING, true, true)),
+	facetable(field(constants.MorphFieldNames.RightHand, FIELD_TYPE_STRING, true, true)),
+	facetable(field(constants.MorphFieldNames.Character, FIELD_TYPE_STRING, true, true)),
+	facetable(field(constants.MorphFieldNames.Background, FIELD_TYPE_STRING, true, true)),
+	field(constants.MorphFieldNames.RarityScore, FIELD_TYPE_FLOAT, true, true),
+	facetable(field(constants.MorphFieldNames.IsVirgin, FIELD_TYPE_BOOL, true, true)),
+	field(constants.MorphFieldNames.ColorMismatches, FIELD_TYPE_INT, true, true),
+	facetable(field(constants.MorphFieldNames.MainSetName, FIELD_TYPE_STRING, true, true)),
+	field(constants.MorphFieldNames.MainMatchingTraits, FIELD_TYPE_STRING_ARRAY, false, true),
+	facetable(field(constants.MorphFieldNames.SecSetName, FIELD_TYPE_STRING, true, true)),
+	field(constants.MorphFieldNames.SecMatchingTraits, FIELD_TYPE_STRING_ARRAY, false, true),
+	facetable(field(constants.MorphFieldNames.HasCompletedSet, FIELD_TYPE_BOOL, true, true)),
+	field(constants.MorphFieldNames.HandsScaler, FIELD_TYPE_FLOAT, true, true),
+	field(constants.MorphFieldNames.HandsSetName, FIELD_TYPE_STRING, true, true),
+	field(constants.MorphFieldNames.MatchingHands, FIELD_TYPE_INT, true, true),
+	field(constants.MorphFieldNames.NoColorMismatchScaler, FIELD_TYPE_FLOAT, true, true),
+	field(constants.MorphFieldNames.ColorMismatchScaler, FIELD_TYPE_FLOAT, true, true),
+	// The degen scaler isn't stored in the entities
+	field(constants.MorphFieldNames.DegenScaler, FIELD_TYPE_FLOAT, false, false),
+	field(constants.MorphFieldNames.VirginScaler, FIELD_TYPE_FLOAT, true, true),
+	field(constants.MorphFieldNames.BaseRarity, FIELD_TYPE_FLOAT, true, true),
+	field(constants.MorphFieldNames.Scrambles, FIELD_TYPE_INT, true, true),
+	field(constants.MorphFieldNames.Morphs, FIELD_TYPE_INT, true, true),
+	field(constants.MorphFieldNames.OldGenes, FIELD_TYPE_STRING_ARRAY, false, false),
+	field(constants.MorphFieldNames.ImageURL, FIELD_TYPE_STRING, false, true),
+	field(constants.MorphFieldNames.Description, FIELD_TYPE_STRING, false, true),
+	field(constants.MorphFieldNames.Name, FIELD_TYPE_STRING, true, true),
+)
+
+// GetMorphField returns the registry entry of a public polymorph field
+func GetMorphField(name string) (structs.FieldSpec, bool) {
+	spec, ok := MORPH_FIELDS[name]
+	if !ok || !spec.Public {
+		return structs.FieldSpec{}, false
+	}
+	return spec, true
+}
+
+func field(name string, fieldType string, sortable bool, public bool) structs.FieldSpec {
+	return structs.FieldSpec{
+		Name:      name,
+		Type:      fieldType,
+		Operators: FIELD_TYPE_OPERATORS[fieldType],
+		Sortable:  sortable,
+		Public:    public,
+	}
+}
+
+// facetable marks the field as facetable. Facets should only be enabled for fields with a small number of distinct values
+func facetable(spec structs.FieldSpec) structs.FieldSpec {
+	spec.Facetable = true
+	return spec
+}
+
+func newFieldRegistry(fields ...structs.FieldSpec) map[string]structs.FieldSpec {
+	registry := make(map[string]structs.FieldSpec, len(fields))
+	for _, f := range fields {
+		registry[f.Name] = f
+	}
+	return registry
+}
+
+// init makes sure every polymorph field name is registered, so new fields can't be queried without declaring their type
+func init() {
+	if missing := unregisteredFields(constants.MorphFieldNames, MORPH_FIELDS); len(missing) > 0 {
+		log.Fatalf("Polymorph fields %v are missing from the field registry", missing)
+	}
+}
+
+// unregisteredFields returns the values of the string fields of names which aren't in the registry
+func unregisteredFields(names interface{}, registry map[string]structs.FieldSpec) []string {
+	var missing []string
+	values := reflect.ValueOf(names)
+	for i := 0; i < values.NumField(); i++ {
+		name := values.Field(i).String()
+		if _, ok := registry[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"rarity-backend/constants"
+	"reflect"
+	"testing"
+)
+
+func TestGetMorphField(t *testing.T) {
+	tests := []struct {
+		name      string
+		found     bool
+		fieldType string
+		sortable  bool
+		facetable bool
+	}{
+		{"tokenid", true, FIELD_TYPE_INT, true, false},
+		{"rarityscore", true, FIELD_TYPE_FLOAT, true, false},
+		{"isvirgin", true, FIELD_TYPE_BOOL, true, true},
+		{"mainsetname", true, FIELD_TYPE_STRING, true, true},
+		{"currentgene", true, FIELD_TYPE_STRING, false, false},
+		{"mainmatchingtraits", true, FIELD_TYPE_STRING_ARRAY, false, false},
+		// Private fields are treated like unknown ones
+		{"oldgenes", false, "", false, false},
+		{"_id", false, "", false, false},
+		{"color", false, "", false, false},
+		{"TokenId", false, "", false, false},
+	}
+
+	for _, test := range tests {
+		spec, ok := GetMorphField(test.name)
+		if ok != test.found {
+			t.Errorf("%v: got found %v, expected %v", test.name, ok, test.found)
+			continue
+		}
+		if spec.Type != test.fieldType || spec.Sortable != test.sortable || spec.Facetable != test.facetable {
+			t.Errorf("%v: got %+v, expected type %v, sortable %v and facetable %v", test.name, spec, test.fieldType, test.sortable, test.facetable)
+		}
+	}
+}
+
+func TestFieldOperators(t *testing.T) {
+	tests := []struct {
+		field     string
+		operator  string
+		supported bool
+	}{
+		{"tokenid", "gte", true},
+		{"tokenid", "contains", false},
+		{"rarityscore", "in", true},
+		{"isvirgin", "eq", true},
+		{"isvirgin", "lt", false},
+		{"isvirgin", "in", false},
+		{"mainsetname", "contains", true},
+		{"mainsetname", "gt", false},
+		{"mainmatchingtraits", "contains", true},
+		{"secsetname", "exists", true},
+	}
+
+	for _, test := range tests {
+		spec, _ := GetMorphField(test.field)
+		if spec.SupportsOperator(test.operator) != test.supported {
+			t.Errorf("%v %v: expected supported to be %v", test.field, test.operator, test.supported)
+		}
+	}
+}
+
+func TestUnregisteredFields(t *testing.T) {
+	if missing := unregisteredFields(constants.MorphFieldNames, MORPH_FIELDS); len(missing) != 0 {
+		t.Errorf("Got unregistered polymorph fields %v", missing)
+	}
+
+	names := struct {
+		TokenId string
+		Color   string
+		Shape   string
+	}{"tokenid", "color", "shape"}
+	if missing := unregisteredFields(names, MORPH_FIELDS); !reflect.DeepEqual(missing, []string{"color", "shape"}) {
+		t.Errorf("Got %v, expected color and shape to be unregistered", missing)
+	}
+}
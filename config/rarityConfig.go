@@ -121,3 +121,9 @@ var CombosMapNewSets = map[string]int{
 	"Stoner":           1,
 	"Party Degen":      5,
 }
+
+// RarityModels contains the rarity models which can be selected per collection
+var RarityModels = map[string]structs.RarityModel{
+	DEFAULT_RARITY_MODEL: {CombosMap: CombosMap, HandsMap: HandsMap},
+	"new-sets":           {CombosMap: CombosMapNewSets, HandsMap: HandsMap},
+}
@@ -0,0 +1,4 @@
+package config
+
+// VERIFY_GENE_WORKERS is the number of concurrent requests the consistency verifier sends to the node for the genes of the polymorphs
+const VERIFY_GENE_WORKERS = 8
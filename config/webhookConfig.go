@@ -0,0 +1,20 @@
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+// WEBHOOK_MAX_PER_TARGET is the maximum number of webhooks watching the same token, wallet or set
+const WEBHOOK_MAX_PER_TARGET = 10
+
+// WebhookApiKeysFromEnv returns the comma separated API keys of WEBHOOK_API_KEYS which can register webhooks. Registration is disabled if there are none
+func WebhookApiKeysFromEnv() []string {
+	var keys []string
+	for _, key := range strings.Split(os.Getenv("WEBHOOK_API_KEYS"), ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,11 @@
+package constants
+
+import "rarity-backend/structs"
+
+var FailedRenderFieldNames = structs.FailedRenderFieldNames{
+	Image:    "image",
+	Genes:    "genes",
+	Error:    "error",
+	Attempts: "attempts",
+	FailedAt: "failedat",
+}
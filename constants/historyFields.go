@@ -0,0 +1,23 @@
+package constants
+
+import "rarity-backend/structs"
+
+var HistoryFieldNames = structs.HistoryFieldNames{
+	ObjId:            "_id",
+	Type:             "type",
+	TokenId:          "tokenid",
+	DateTime:         "datetime",
+	AttributeChanged: "attributechanged",
+	Price:            "price",
+	NewGene:          "newgene",
+	OldGene:          "oldgene",
+	Character:        "character",
+	Wallet:           "wallet",
+}
+
+// History snapshot types
+const HISTORY_TYPE_MORPH = "Morph"
+const HISTORY_TYPE_SCRAMBLE = "Scramble"
+
+// DAY_LAYOUT is the format of the days accepted by the activity and morph economics endpoints
+const DAY_LAYOUT = "2006-01-02"
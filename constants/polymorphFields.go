@@ -35,4 +35,7 @@ var MorphFieldNames = structs.PolymorphFieldNames{
 	Scrambles:             "scrambles",
 	Morphs:                "morphs",
 	OldGenes:              "oldgenes",
+	ImageURL:              "imageurl",
+	Description:           "description",
+	Name:                  "name",
 }
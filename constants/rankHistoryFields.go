@@ -0,0 +1,10 @@
+package constants
+
+import "rarity-backend/structs"
+
+var RankHistoryFieldNames = structs.RankHistoryFieldNames{
+	TokenId:      "tokenid",
+	Rank:         "rank",
+	PreviousRank: "previousrank",
+	DateTime:     "datetime",
+}
@@ -0,0 +1,18 @@
+package constants
+
+import "rarity-backend/structs"
+
+var WebhookFieldNames = structs.WebhookFieldNames{
+	Id:            "id",
+	Type:          "type",
+	TokenId:       "tokenid",
+	Wallet:        "wallet",
+	MinRankChange: "minrankchange",
+	SetName:       "setname",
+	CreatedAt:     "createdat",
+}
+
+var WebhookDeliveryFieldNames = structs.WebhookDeliveryFieldNames{
+	WebhookId: "webhookid",
+	CreatedAt: "createdat",
+}
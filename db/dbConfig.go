@@ -0,0 +1,117 @@
+package db
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CONNECTION_CHECK_INTERVAL is the minimum time between two pings of the connection check
+const CONNECTION_CHECK_INTERVAL = 10 * time.Second
+
+// PING_TIMEOUT is the time the connection check waits for the database to answer
+const PING_TIMEOUT = 5 * time.Second
+
+// connectionOptions builds the client options from the environment.
+//
+// MONGO_URI is a complete connection string, e.g. mongodb://localhost:27017 or a replica set without SRV.
+// If it's empty, the Atlas connection string is built from USERNAME, PASSWORD and DB_URL.
+//
+// MONGO_TLS, MONGO_TLS_CA_FILE, MONGO_AUTH_SOURCE, MONGO_MAX_POOL_SIZE, MONGO_CONNECT_TIMEOUT_SECONDS and MONGO_SERVER_SELECTION_TIMEOUT_SECONDS
+// override the matching options of the connection string
+func connectionOptions() (*options.ClientOptions, error) {
+	uri := os.Getenv("MONGO_URI")
+	if uri == "" {
+		username := os.Getenv("USERNAME")
+		password := os.Getenv("PASSWORD")
+		dbUrl := os.Getenv("DB_URL")
+
+		if username == "" {
+			return nil, fmt.Errorf("Missing username in .env")
+		}
+		if password == "" {
+			return nil, fmt.Errorf("Missing password in .env")
+		}
+		if dbUrl == "" {
+			return nil, fmt.Errorf("Missing db url in .env")
+		}
+		uri = "mongodb+srv://" + username + ":" + password + "@" + dbUrl + "?retryWrites=true&w=majority"
+	}
+	opts := options.Client().ApplyURI(uri)
+
+	if authSource := os.Getenv("MONGO_AUTH_SOURCE"); authSource != "" {
+		if opts.Auth == nil {
+			return nil, fmt.Errorf("MONGO_AUTH_SOURCE is set but the connection string has no credentials")
+		}
+		opts.Auth.AuthSource = authSource
+	}
+
+	if value := os.Getenv("MONGO_TLS"); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid MONGO_TLS: %v", value)
+		}
+		if enabled {
+			opts.SetTLSConfig(&tls.Config{})
+		} else {
+			opts.SetTLSConfig(nil)
+		}
+	}
+	if caFile := os.Getenv("MONGO_TLS_CA_FILE"); caFile != "" {
+		tlsConfig, err := caTLSConfig(caFile)
+		if err != nil {
+			return nil, err
+		}
+		opts.SetTLSConfig(tlsConfig)
+	}
+
+	if poolSize, err := envUint("MONGO_MAX_POOL_SIZE"); err != nil {
+		return nil, err
+	} else if poolSize > 0 {
+		opts.SetMaxPoolSize(poolSize)
+	}
+	if seconds, err := envUint("MONGO_CONNECT_TIMEOUT_SECONDS"); err != nil {
+		return nil, err
+	} else if seconds > 0 {
+		opts.SetConnectTimeout(time.Duration(seconds) * time.Second)
+	}
+	if seconds, err := envUint("MONGO_SERVER_SELECTION_TIMEOUT_SECONDS"); err != nil {
+		return nil, err
+	} else if seconds > 0 {
+		opts.SetServerSelectionTimeout(time.Duration(seconds) * time.Second)
+	}
+
+	return opts, opts.Validate()
+}
+
+// caTLSConfig returns a TLS config which trusts the certificates of the CA file
+func caTLSConfig(caFile string) (*tls.Config, error) {
+	pem, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read MONGO_TLS_CA_FILE: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("MONGO_TLS_CA_FILE %v contains no certificates", caFile)
+	}
+	return &tls.Config{RootCAs: pool}, nil
+}
+
+// envUint parses the unsigned integer env variable or returns 0 if it's empty
+func envUint(name string) (uint64, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid %v: %v", name, value)
+	}
+	return n, nil
+}
@@ -3,79 +3,86 @@ package db
 import (
 	"context"
 	"log"
-	"os"
 	"sync"
+	"time"
 
 	"github.com/joho/godotenv"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
-var once sync.Once
+var mutex sync.Mutex
 var instance *mongo.Client
+var lastCheck time.Time
+
+// connectionLost is set if the last connection check failed
+var connectionLost bool
 
 // GetDbConnection returns an instance of a mongo db client. This is a singleton pattern in order to have only one alive connection to the database.
 //
-// If no connection exists, it will connect to database.
+// If no connection exists, it will connect to database. Invalid settings are returned as error and the next call tries again.
 //
-// If connection exists, it will return the instance of the database
-func GetDbConnection() *mongo.Client {
-	once.Do(func() {
-		client := connectToDb()
-		checkConnectionAndRestore(client)
+// If connection exists, it will return the instance of the database. The client is never replaced, the driver reconnects lost connections on its own.
+// The connection is checked in the background at most once every CONNECTION_CHECK_INTERVAL, so lost connections are logged
+func GetDbConnection() (*mongo.Client, error) {
+	mutex.Lock()
+	if instance == nil {
+		client, err := connectToDb()
+		if err != nil {
+			mutex.Unlock()
+			return nil, err
+		}
 		instance = client
-	})
-
-	checkConnectionAndRestore(instance)
-	return instance
-}
-
-// connectToDb retrieves db config from .env and tries to conenct to the database.
-func connectToDb() *mongo.Client {
-	err := godotenv.Load()
-	if err != nil {
-		log.Fatal("Error loading .env file")
-	}
-
-	username := os.Getenv("USERNAME")
-	password := os.Getenv("PASSWORD")
-	dbUrl := os.Getenv("DB_URL")
-
-	if username == "" {
-		log.Fatalln("Missing username in .env")
 	}
-	if password == "" {
-		log.Fatalln("Missing password in .env")
+	client := instance
+	check := time.Since(lastCheck) >= CONNECTION_CHECK_INTERVAL
+	if check {
+		lastCheck = time.Now()
 	}
-	if dbUrl == "" {
-		log.Fatalln("Missing db url in .env")
+	mutex.Unlock()
+
+	if check {
+		go checkConnection(client)
 	}
+	return client, nil
+}
 
-	connectionStr := "mongodb+srv://" + username + ":" + password + "@" + dbUrl + "?retryWrites=true&w=majority"
+// connectToDb retrieves db config from .env and creates a client. The client connects in the background, so an unreachable database isn't an error here
+func connectToDb() (*mongo.Client, error) {
+	// The variables can also be set without a .env file
+	if err := godotenv.Load(); err != nil {
+		log.Println("Could not load .env file: " + err.Error())
+	}
 
-	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(connectionStr))
+	opts, err := connectionOptions()
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-
-	return client
+	return mongo.Connect(context.Background(), opts)
 }
 
-// checkConnectionAndRestore ping the client and it throws and error, it tries to reconnect.
-func checkConnectionAndRestore(client *mongo.Client) {
-	err := client.Ping(context.Background(), readpref.Primary())
+// checkConnection pings the database and logs when the connection is lost or restored
+func checkConnection(client *mongo.Client) {
+	ctx, cancel := context.WithTimeout(context.Background(), PING_TIMEOUT)
+	defer cancel()
+	err := client.Ping(ctx, readpref.Primary())
 
-	if err != nil {
-		log.Fatal(err)
-		newClient := connectToDb()
-		client = newClient
+	mutex.Lock()
+	defer mutex.Unlock()
+	if err != nil && !connectionLost {
+		log.Println("Lost the connection to the database: " + err.Error())
+	} else if err == nil && connectionLost {
+		log.Println("Reconnected to the database")
 	}
+	connectionLost = err != nil
 }
 
 // GetMongoDbCollection accepts dbName and collectionname and returns an instance of the specified collection.
 func GetMongoDbCollection(DbName string, CollectionName string) (*mongo.Collection, error) {
-	client := GetDbConnection()
+	client, err := GetDbConnection()
+	if err != nil {
+		return nil, err
+	}
 
 	collection := client.Database(DbName).Collection(CollectionName)
 	return collection, nil
@@ -0,0 +1,85 @@
+package dlt
+
+import (
+	"context"
+	"math/big"
+
+	"rarity-backend/store"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Chain is the part of the chain and the polymorphs contract the indexing reads
+type Chain interface {
+	// HeaderByNumber returns the header of the block, or the latest header if number is nil
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error)
+	// TransactionSender returns the address which sent the transaction at index in the block
+	TransactionSender(ctx context.Context, blockHash common.Hash, index uint) (common.Address, error)
+	GeneOf(tokenId *big.Int) (*big.Int, error)
+	OwnerOf(tokenId *big.Int) (common.Address, error)
+}
+
+// Backend is implemented by both the ethclient and go-ethereum's simulated backend
+type Backend interface {
+	bind.ContractBackend
+	TransactionInBlock(ctx context.Context, blockHash common.Hash, index uint) (*types.Transaction, error)
+}
+
+// senderBackend is implemented by the ethclient, which knows the sender of a transaction it fetched from the node
+type senderBackend interface {
+	TransactionSender(ctx context.Context, tx *types.Transaction, blockHash common.Hash, index uint) (common.Address, error)
+}
+
+// ContractChain reads the chain through a backend and calls the contract at its address
+type ContractChain struct {
+	backend  Backend
+	instance *store.Store
+	// blockNumber is the block of the contract state read by the calls. Calls read the latest state if it's nil
+	blockNumber *big.Int
+}
+
+// NewContractChain binds the polymorphs contract at address to the backend
+func NewContractChain(backend Backend, address common.Address) (*ContractChain, error) {
+	instance, err := store.NewStore(address, backend)
+	if err != nil {
+		return nil, err
+	}
+	return &ContractChain{backend: backend, instance: instance}, nil
+}
+
+// AtBlock returns a chain whose contract calls read the state at the end of the block. Reading old blocks requires an archive node
+func (c *ContractChain) AtBlock(number *big.Int) *ContractChain {
+	return &ContractChain{backend: c.backend, instance: c.instance, blockNumber: number}
+}
+
+func (c *ContractChain) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return c.backend.HeaderByNumber(ctx, number)
+}
+
+func (c *ContractChain) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	return c.backend.FilterLogs(ctx, query)
+}
+
+// TransactionSender uses the sender returned by the node if the backend knows it and recovers it from the signature otherwise
+func (c *ContractChain) TransactionSender(ctx context.Context, blockHash common.Hash, index uint) (common.Address, error) {
+	tx, err := c.backend.TransactionInBlock(ctx, blockHash, index)
+	if err != nil {
+		return common.Address{}, err
+	}
+	if backend, ok := c.backend.(senderBackend); ok {
+		return backend.TransactionSender(ctx, tx, blockHash, index)
+	}
+	return types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+}
+
+func (c *ContractChain) GeneOf(tokenId *big.Int) (*big.Int, error) {
+	return c.instance.GeneOf(&bind.CallOpts{BlockNumber: c.blockNumber}, tokenId)
+}
+
+func (c *ContractChain) OwnerOf(tokenId *big.Int) (common.Address, error) {
+	return c.instance.OwnerOf(&bind.CallOpts{BlockNumber: c.blockNumber}, tokenId)
+}
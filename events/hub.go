@@ -0,0 +1,94 @@
+// Package events publishes the changes committed by the indexer to the clients of the live feed.
+package events
+
+import (
+	"rarity-backend/structs"
+	"sync"
+)
+
+const (
+	MINT        = "mint"
+	MORPH       = "morph"
+	SCRAMBLE    = "scramble"
+	RANK_CHANGE = "rank"
+)
+
+// Hub publishes the events of a single collection to its subscribers.
+//
+// Subscribers which fall behind by more than their buffer are disconnected. The most recent events are kept, so reconnecting clients can catch up.
+//
+// A nil *Hub is valid and drops the published events
+type Hub struct {
+	mutex       sync.Mutex
+	subscribers map[chan structs.FeedEvent]bool
+	recent      []structs.FeedEvent
+	historySize int
+	bufferSize  int
+	lastId      uint64
+}
+
+// NewHub creates a hub which keeps the last historySize events. Subscriber channels are buffered with bufferSize events
+func NewHub(historySize int, bufferSize int) *Hub {
+	return &Hub{
+		subscribers: make(map[chan structs.FeedEvent]bool),
+		historySize: historySize,
+		bufferSize:  bufferSize,
+	}
+}
+
+// Publish assigns ids to the events and sends them to the subscribers
+func (h *Hub) Publish(events ...structs.FeedEvent) {
+	if h == nil {
+		return
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for _, event := range events {
+		h.lastId++
+		event.Id = h.lastId
+
+		h.recent = append(h.recent, event)
+		if len(h.recent) > h.historySize {
+			h.recent = h.recent[len(h.recent)-h.historySize:]
+		}
+
+		for subscriber := range h.subscribers {
+			select {
+			case subscriber <- event:
+			default:
+				delete(h.subscribers, subscriber)
+				close(subscriber)
+			}
+		}
+	}
+}
+
+// Subscribe returns the channel of the events published after the event with lastId and the kept events which were already published after it.
+//
+// The channel is closed if the subscriber falls behind. Unsubscribe must be called when the subscriber stops reading
+func (h *Hub) Subscribe(lastId uint64) (<-chan structs.FeedEvent, []structs.FeedEvent, func()) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	var missed []structs.FeedEvent
+	if lastId > 0 {
+		for _, event := range h.recent {
+			if event.Id > lastId {
+				missed = append(missed, event)
+			}
+		}
+	}
+
+	subscriber := make(chan structs.FeedEvent, h.bufferSize)
+	h.subscribers[subscriber] = true
+	unsubscribe := func() {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		if h.subscribers[subscriber] {
+			delete(h.subscribers, subscriber)
+			close(subscriber)
+		}
+	}
+	return subscriber, missed, unsubscribe
+}
@@ -0,0 +1,104 @@
+package events
+
+import (
+	"rarity-backend/structs"
+	"reflect"
+	"testing"
+)
+
+func publishTokens(hub *Hub, tokenIds ...int) {
+	for _, tokenId := range tokenIds {
+		hub.Publish(structs.FeedEvent{Type: MINT, TokenId: tokenId})
+	}
+}
+
+func eventIds(events []structs.FeedEvent) []uint64 {
+	ids := []uint64{}
+	for _, event := range events {
+		ids = append(ids, event.Id)
+	}
+	return ids
+}
+
+func TestHubReplaysMissedEvents(t *testing.T) {
+	hub := NewHub(3, 10)
+	publishTokens(hub, 1, 2, 3, 4, 5)
+
+	tests := []struct {
+		lastId uint64
+		want   []uint64
+	}{
+		// New clients don't get the kept events
+		{0, []uint64{}},
+		{3, []uint64{4, 5}},
+		// Events older than the kept ones can't be replayed
+		{1, []uint64{3, 4, 5}},
+		{5, []uint64{}},
+		{9, []uint64{}},
+	}
+	for _, test := range tests {
+		_, missed, unsubscribe := hub.Subscribe(test.lastId)
+		unsubscribe()
+		if got := eventIds(missed); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Last-Event-ID %v: got %v, expected %v", test.lastId, got, test.want)
+		}
+	}
+}
+
+func TestHubSendsEventsAfterSubscribing(t *testing.T) {
+	hub := NewHub(10, 10)
+	publishTokens(hub, 1)
+	events, missed, unsubscribe := hub.Subscribe(1)
+	defer unsubscribe()
+	publishTokens(hub, 2)
+
+	event := <-events
+	if len(missed) != 0 || event.Id != 2 || event.TokenId != 2 {
+		t.Errorf("Got missed %v and event %+v, expected only event 2", missed, event)
+	}
+}
+
+func TestHubDropsSlowSubscribers(t *testing.T) {
+	hub := NewHub(10, 2)
+	slow, _, unsubscribeSlow := hub.Subscribe(0)
+	defer unsubscribeSlow()
+	fast, _, unsubscribeFast := hub.Subscribe(0)
+	defer unsubscribeFast()
+
+	for tokenId := 1; tokenId <= 3; tokenId++ {
+		publishTokens(hub, tokenId)
+		<-fast
+	}
+
+	// The slow subscriber gets the buffered events before its channel is closed
+	var received []structs.FeedEvent
+	for event := range slow {
+		received = append(received, event)
+	}
+	if len(received) != 2 {
+		t.Errorf("Slow subscriber got %v events, expected the 2 buffered ones", len(received))
+	}
+
+	publishTokens(hub, 4)
+	if event := <-fast; event.Id != 4 {
+		t.Errorf("Got event %v, expected the fast subscriber to keep receiving events", event.Id)
+	}
+}
+
+func TestHubUnsubscribe(t *testing.T) {
+	hub := NewHub(10, 10)
+	events, _, unsubscribe := hub.Subscribe(0)
+	unsubscribe()
+	// Unsubscribing again, e.g. after the subscriber was dropped, is safe
+	unsubscribe()
+	publishTokens(hub, 1)
+
+	if _, open := <-events; open {
+		t.Error("Expected the channel to be closed")
+	}
+}
+
+func TestNilHub(t *testing.T) {
+	var hub *Hub
+	publishTokens(hub, 1)
+}
@@ -0,0 +1,64 @@
+package genome
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ParseConfig parses a polymorphs config json.
+//
+// The json contains a "genome" array with the slots, a "type" array with the character types and every trait list referenced by the slots.
+// Lists can either contain plain names or objects with name and sets.
+func ParseConfig(data []byte) (Config, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return Config{}, err
+	}
+
+	var config Config
+	if rawLayout, ok := raw["genome"]; ok {
+		if err := json.Unmarshal(rawLayout, &config.Layout); err != nil {
+			return Config{}, fmt.Errorf("invalid genome layout: %v", err)
+		}
+	}
+	if rawTypes, ok := raw["type"]; ok {
+		if err := json.Unmarshal(rawTypes, &config.Types); err != nil {
+			return Config{}, fmt.Errorf("invalid character types: %v", err)
+		}
+	}
+
+	config.Lists = make(map[string][]Trait)
+	for _, slot := range config.Layout {
+		if _, ok := config.Lists[slot.List]; ok {
+			continue
+		}
+		rawList, ok := raw[slot.List]
+		if !ok {
+			continue
+		}
+		list, err := parseList(rawList)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid trait list %v: %v", slot.List, err)
+		}
+		config.Lists[slot.List] = list
+	}
+
+	return config, nil
+}
+
+func parseList(rawList json.RawMessage) ([]Trait, error) {
+	var list []Trait
+	if err := json.Unmarshal(rawList, &list); err == nil {
+		return list, nil
+	}
+
+	var names []string
+	if err := json.Unmarshal(rawList, &names); err != nil {
+		return nil, err
+	}
+	list = make([]Trait, 0, len(names))
+	for _, name := range names {
+		list = append(list, Trait{Name: name})
+	}
+	return list, nil
+}
@@ -0,0 +1,253 @@
+// Package genome decodes polymorph genes into traits and token metadata.
+//
+// The package depends only on the standard library so every service which needs to decode genes can import it and get identical results to the indexer.
+package genome
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const DEFAULT_CHARACTER_TRAIT = "Character"
+
+var ErrInvalidGene = errors.New("gene must contain only digits")
+
+// Slot describes where a single trait is stored in the gene and how it's decoded.
+//
+// Position is the number of digits between the end of the gene and the slot, Width is the number of digits in the slot.
+// The decoded number is wrapped around Count and used as an index in the trait list named List.
+// Layer is the order in which the slot takes part in the image path.
+type Slot struct {
+	TraitType string `json:"traitType"`
+	List      string `json:"list"`
+	Position  int    `json:"position"`
+	Width     int    `json:"width"`
+	Count     int    `json:"count"`
+	Layer     int    `json:"layer"`
+}
+
+// Trait is a single element of a trait list
+type Trait struct {
+	Name string   `json:"name"`
+	Sets []string `json:"sets"`
+}
+
+// Attribute is a decoded trait of a gene
+type Attribute struct {
+	TraitType string   `json:"trait_type"`
+	Value     string   `json:"value"`
+	Sets      []string `json:"sets"`
+}
+
+// Metadata is the token metadata built from a gene
+type Metadata struct {
+	Description string      `json:"description"`
+	Name        string      `json:"name"`
+	Image       string      `json:"image"`
+	Attributes  []Attribute `json:"attributes"`
+	ExternalUrl string      `json:"external_url"`
+}
+
+// Config contains everything the decoder needs.
+//
+// Types are the character types used in the description and are indexed by the character gene.
+type Config struct {
+	Layout         []Slot
+	Lists          map[string][]Trait
+	Types          []string
+	CharacterTrait string
+	ImageURL       string
+	ExternalURL    string
+}
+
+// Decoder decodes genes using a validated configuration
+type Decoder struct {
+	config Config
+	layers []Slot
+}
+
+// NewDecoder validates the configuration and returns a decoder.
+//
+// Every slot must reference an existing list with at least Count elements.
+func NewDecoder(config Config) (*Decoder, error) {
+	if len(config.Layout) == 0 {
+		return nil, errors.New("missing genome layout")
+	}
+	if config.CharacterTrait == "" {
+		config.CharacterTrait = DEFAULT_CHARACTER_TRAIT
+	}
+
+	for _, slot := range config.Layout {
+		if slot.Width <= 0 || slot.Count <= 0 || slot.Position < 0 {
+			return nil, fmt.Errorf("invalid genome slot %v: position, width and count must be positive", slot.TraitType)
+		}
+		list, ok := config.Lists[slot.List]
+		if !ok {
+			return nil, fmt.Errorf("missing trait list %v for genome slot %v", slot.List, slot.TraitType)
+		}
+		if len(list) < slot.Count {
+			return nil, fmt.Errorf("trait list %v has %v elements but genome slot %v needs %v", slot.List, len(list), slot.TraitType, slot.Count)
+		}
+	}
+
+	layers := make([]Slot, len(config.Layout))
+	copy(layers, config.Layout)
+	sort.SliceStable(layers, func(i, j int) bool {
+		return layers[i].Layer < layers[j].Layer
+	})
+
+	return &Decoder{config: config, layers: layers}, nil
+}
+
+// Layout returns the slots in the order in which the attributes are decoded
+func (d *Decoder) Layout() []Slot {
+	return d.config.Layout
+}
+
+// Lists returns the trait lists referenced by the layout
+func (d *Decoder) Lists() map[string][]Trait {
+	return d.config.Lists
+}
+
+// validateGene checks that the gene is a non negative decimal number
+func validateGene(gene string) error {
+	if gene == "" {
+		return ErrInvalidGene
+	}
+	for _, r := range gene {
+		if r < '0' || r > '9' {
+			return ErrInvalidGene
+		}
+	}
+	return nil
+}
+
+// slotValue extracts the digits of the slot and wraps them around the slot's trait count.
+//
+// bigInt.String() removes leading zeroes so the gene is padded back if it's shorter than the slot
+func slotValue(gene string, slot Slot) int {
+	if pad := slot.Position + slot.Width - len(gene); pad > 0 {
+		gene = strings.Repeat("0", pad) + gene
+	}
+	end := len(gene) - slot.Position
+	value, _ := strconv.Atoi(gene[end-slot.Width : end])
+	return value % slot.Count
+}
+
+// Value returns the decoded index of the slot in its trait list
+func (d *Decoder) Value(gene string, slot Slot) (int, error) {
+	if err := validateGene(gene); err != nil {
+		return 0, err
+	}
+	return slotValue(gene, slot), nil
+}
+
+func (d *Decoder) attribute(gene string, slot Slot) Attribute {
+	trait := d.config.Lists[slot.List][slotValue(gene, slot)]
+	return Attribute{
+		TraitType: slot.TraitType,
+		Value:     trait.Name,
+		Sets:      trait.Sets,
+	}
+}
+
+// Attribute decodes a single slot of the gene
+func (d *Decoder) Attribute(gene string, slot Slot) (Attribute, error) {
+	if err := validateGene(gene); err != nil {
+		return Attribute{}, err
+	}
+	return d.attribute(gene, slot), nil
+}
+
+// Decode returns the attributes of the gene in layout order
+func (d *Decoder) Decode(gene string) ([]Attribute, error) {
+	if err := validateGene(gene); err != nil {
+		return nil, err
+	}
+
+	res := make([]Attribute, 0, len(d.config.Layout))
+	for _, slot := range d.config.Layout {
+		res = append(res, d.attribute(gene, slot))
+	}
+	return res, nil
+}
+
+// DecodeBig returns the attributes of the gene in layout order
+func (d *Decoder) DecodeBig(gene *big.Int) ([]Attribute, error) {
+	if gene == nil || gene.Sign() < 0 {
+		return nil, ErrInvalidGene
+	}
+	return d.Decode(gene.String())
+}
+
+// TraitSlot returns the slot of the passed trait type
+func (d *Decoder) TraitSlot(traitType string) (Slot, bool) {
+	for _, slot := range d.config.Layout {
+		if slot.TraitType == traitType {
+			return slot, true
+		}
+	}
+	return Slot{}, false
+}
+
+// SlotAt returns the slot containing the digit at the passed index. The index is counted from the end of the gene.
+func (d *Decoder) SlotAt(idx int) (Slot, bool) {
+	for _, slot := range d.config.Layout {
+		if idx >= slot.Position && idx < slot.Position+slot.Width {
+			return slot, true
+		}
+	}
+	return Slot{}, false
+}
+
+// Paths returns the two digit image path of each slot ordered by layer
+func (d *Decoder) Paths(gene string) ([]string, error) {
+	if err := validateGene(gene); err != nil {
+		return nil, err
+	}
+
+	res := make([]string, 0, len(d.layers))
+	for _, slot := range d.layers {
+		res = append(res, fmt.Sprintf("%02d", slotValue(gene, slot)))
+	}
+	return res, nil
+}
+
+// ImageURL returns the url of the rendered image of the gene
+func (d *Decoder) ImageURL(gene string) (string, error) {
+	paths, err := d.Paths(gene)
+	if err != nil {
+		return "", err
+	}
+	return d.config.ImageURL + strings.Join(paths, "") + ".jpg", nil
+}
+
+// Metadata builds the token metadata of the gene
+func (d *Decoder) Metadata(tokenId string, gene string) (Metadata, error) {
+	attributes, err := d.Decode(gene)
+	if err != nil {
+		return Metadata{}, err
+	}
+	image, _ := d.ImageURL(gene)
+
+	var character Attribute
+	var characterType string
+	if slot, ok := d.TraitSlot(d.config.CharacterTrait); ok {
+		character = d.attribute(gene, slot)
+		if value := slotValue(gene, slot); value < len(d.config.Types) {
+			characterType = d.config.Types[value]
+		}
+	}
+
+	return Metadata{
+		Attributes:  attributes,
+		Name:        fmt.Sprintf("%v #%v", character.Value, tokenId),
+		Description: fmt.Sprintf("The %v named %v #%v is a citizen of the Polymorph Universe and has a unique genetic code! You can scramble your Polymorph at anytime.", characterType, character.Value, tokenId),
+		ExternalUrl: fmt.Sprintf("%s%s", d.config.ExternalURL, tokenId),
+		Image:       image,
+	}, nil
+}
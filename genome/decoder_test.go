@@ -0,0 +1,329 @@
+package genome
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testLayout mirrors the polymorphs layout with synthetic lists so every decoded value is visible in the trait name
+var testLayout = []Slot{
+	{TraitType: "Character", List: "character", Position: 0, Width: 2, Count: 11, Layer: 7},
+	{TraitType: "Footwear", List: "footwear", Position: 8, Width: 2, Count: 25, Layer: 6},
+	{TraitType: "Pants", List: "pants", Position: 4, Width: 2, Count: 33, Layer: 5},
+	{TraitType: "Torso", List: "torso", Position: 6, Width: 2, Count: 34, Layer: 4},
+	{TraitType: "Eyewear", List: "eyewear", Position: 10, Width: 2, Count: 13, Layer: 3},
+	{TraitType: "Headwear", List: "headwear", Position: 12, Width: 2, Count: 31, Layer: 2},
+	{TraitType: "Left Hand", List: "weaponleft", Position: 16, Width: 2, Count: 32, Layer: 1},
+	{TraitType: "Right Hand", List: "weaponright", Position: 14, Width: 2, Count: 32, Layer: 0},
+	{TraitType: "Background", List: "background", Position: 2, Width: 2, Count: 12, Layer: 8},
+}
+
+func newTestDecoder(t *testing.T) *Decoder {
+	t.Helper()
+	lists := make(map[string][]Trait)
+	for _, slot := range testLayout {
+		for i := 0; i < slot.Count; i++ {
+			lists[slot.List] = append(lists[slot.List], Trait{Name: fmt.Sprintf("%v %v", slot.TraitType, i), Sets: []string{slot.List}})
+		}
+	}
+	types := make([]string, 11)
+	for i := range types {
+		types[i] = fmt.Sprintf("Type %v", i)
+	}
+
+	d, err := NewDecoder(Config{Layout: testLayout, Lists: lists, Types: types, ImageURL: "img/", ExternalURL: "ext/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// geneWithSlot returns an 18 digit gene of zeroes with the passed digits in the slot
+func geneWithSlot(slot Slot, digits string) string {
+	gene := []byte(strings.Repeat("0", 18))
+	end := len(gene) - slot.Position
+	copy(gene[end-slot.Width:end], digits)
+	return string(gene)
+}
+
+func TestDecodeSlotBoundaries(t *testing.T) {
+	d := newTestDecoder(t)
+
+	for _, slot := range testLayout {
+		for _, digits := range []string{"01", "07", "10"} {
+			gene := geneWithSlot(slot, digits)
+			attributes, err := d.Decode(gene)
+			if err != nil {
+				t.Fatalf("%v %v: %v", slot.TraitType, gene, err)
+			}
+
+			for i, attr := range attributes {
+				expected := fmt.Sprintf("%v 0", testLayout[i].TraitType)
+				if testLayout[i].TraitType == slot.TraitType {
+					value := 0
+					fmt.Sscanf(digits, "%d", &value)
+					expected = fmt.Sprintf("%v %v", slot.TraitType, value%slot.Count)
+				}
+				if attr.Value != expected {
+					t.Errorf("gene %v: %v decoded as %q, expected %q", gene, attr.TraitType, attr.Value, expected)
+				}
+			}
+		}
+	}
+}
+
+func TestDecodeModuloWrapAround(t *testing.T) {
+	d := newTestDecoder(t)
+
+	tests := []struct {
+		trait    string
+		digits   string
+		expected string
+	}{
+		{"Character", "11", "Character 0"},
+		{"Character", "99", "Character 0"},
+		{"Character", "23", "Character 1"},
+		{"Background", "12", "Background 0"},
+		{"Background", "25", "Background 1"},
+		{"Footwear", "49", "Footwear 24"},
+		{"Torso", "34", "Torso 0"},
+		{"Left Hand", "63", "Left Hand 31"},
+		{"Right Hand", "64", "Right Hand 0"},
+	}
+
+	for _, test := range tests {
+		slot, ok := d.TraitSlot(test.trait)
+		if !ok {
+			t.Fatalf("missing slot %v", test.trait)
+		}
+		attr, err := d.Attribute(geneWithSlot(slot, test.digits), slot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if attr.Value != test.expected {
+			t.Errorf("%v %v: got %q, expected %q", test.trait, test.digits, attr.Value, test.expected)
+		}
+	}
+}
+
+func TestDecodeLeadingZeroGenes(t *testing.T) {
+	d := newTestDecoder(t)
+
+	tests := []struct {
+		gene   string
+		padded string
+	}{
+		{"5", "000000000000000005"},
+		{"123", "000000000000000123"},
+		{"00000000000000000123", "000000000000000123"},
+		{"10000000000000000", "010000000000000000"},
+	}
+
+	for _, test := range tests {
+		short, err := d.Decode(test.gene)
+		if err != nil {
+			t.Fatal(err)
+		}
+		full, _ := d.Decode(test.padded)
+		if !reflect.DeepEqual(short, full) {
+			t.Errorf("gene %v decoded differently than %v", test.gene, test.padded)
+		}
+
+		shortPaths, _ := d.Paths(test.gene)
+		fullPaths, _ := d.Paths(test.padded)
+		if !reflect.DeepEqual(shortPaths, fullPaths) {
+			t.Errorf("gene %v paths %v, expected %v", test.gene, shortPaths, fullPaths)
+		}
+	}
+
+	big, _ := new(big.Int).SetString("123", 10)
+	fromBig, err := d.DecodeBig(big)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromString, _ := d.Decode("000000000000000123")
+	if !reflect.DeepEqual(fromBig, fromString) {
+		t.Errorf("big.Int gene decoded differently than string gene")
+	}
+}
+
+func TestDecodeInvalidGenes(t *testing.T) {
+	d := newTestDecoder(t)
+
+	for _, gene := range []string{"", "12a4", "-123", "1.5"} {
+		if _, err := d.Decode(gene); err != ErrInvalidGene {
+			t.Errorf("gene %q: expected ErrInvalidGene, got %v", gene, err)
+		}
+	}
+	if _, err := d.DecodeBig(big.NewInt(-1)); err != ErrInvalidGene {
+		t.Errorf("negative gene: expected ErrInvalidGene, got %v", err)
+	}
+}
+
+func TestPathsAndMetadata(t *testing.T) {
+	d := newTestDecoder(t)
+
+	// Left hand 01, right hand 02, head 03, eyewear 04, footwear 05, torso 06, pants 07, background 08, character 09
+	gene := "010203040506070809"
+	paths, err := d.Paths(gene)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedPaths := []string{"02", "01", "03", "04", "06", "07", "05", "09", "08"}
+	if !reflect.DeepEqual(paths, expectedPaths) {
+		t.Errorf("got paths %v, expected %v", paths, expectedPaths)
+	}
+
+	m, err := d.Metadata("7", gene)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Name != "Character 9 #7" {
+		t.Errorf("unexpected name %q", m.Name)
+	}
+	if !strings.HasPrefix(m.Description, "The Type 9 named Character 9 #7 ") {
+		t.Errorf("unexpected description %q", m.Description)
+	}
+	if m.Image != "img/020103040607050908.jpg" {
+		t.Errorf("unexpected image %q", m.Image)
+	}
+	if m.ExternalUrl != "ext/7" {
+		t.Errorf("unexpected external url %q", m.ExternalUrl)
+	}
+}
+
+func TestNewDecoderValidation(t *testing.T) {
+	lists := map[string][]Trait{"character": {{Name: "a"}, {Name: "b"}}}
+
+	tests := []struct {
+		name   string
+		layout []Slot
+	}{
+		{"empty layout", nil},
+		{"missing list", []Slot{{TraitType: "Torso", List: "torso", Width: 2, Count: 1}}},
+		{"short list", []Slot{{TraitType: "Character", List: "character", Width: 2, Count: 3}}},
+		{"zero width", []Slot{{TraitType: "Character", List: "character", Width: 0, Count: 2}}},
+		{"zero count", []Slot{{TraitType: "Character", List: "character", Width: 2, Count: 0}}},
+	}
+
+	for _, test := range tests {
+		if _, err := NewDecoder(Config{Layout: test.layout, Lists: lists}); err == nil {
+			t.Errorf("%v: expected error", test.name)
+		}
+	}
+}
+
+// TestGoldenPolymorphsConfig decodes genes with the production config. Any change in these results changes the stored metadata of existing polymorphs.
+func TestGoldenPolymorphsConfig(t *testing.T) {
+	data, err := ioutil.ReadFile("../config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := ParseConfig(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.ImageURL = "https://storage.googleapis.com/polymorph-images/"
+	config.ExternalURL = "https://universe.xyz/polymorphs/"
+	d, err := NewDecoder(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		gene       string
+		name       string
+		image      string
+		attributes [][2]string
+	}{
+		{
+			gene:  "2335105811832988321",
+			name:  "X-YZ #42",
+			image: "https://storage.googleapis.com/polymorph-images/190105033232181011.jpg",
+			attributes: [][2]string{
+				{"Character", "X-YZ"},
+				{"Footwear", "Red Soccer Cleats"},
+				{"Pants", "Tuxedo Pants"},
+				{"Torso", "Weed Plant Tshirt"},
+				{"Eyewear", "Eye Paint"},
+				{"Headwear", "Black Ninja Headband"},
+				{"Left Hand", "American Football"},
+				{"Right Hand", "Golf Club"},
+				{"Background", "Summer Salad"},
+			},
+		},
+		{
+			gene:  "101010101010101010",
+			name:  "X-YZ #42",
+			image: "https://storage.googleapis.com/polymorph-images/101010101010101010.jpg",
+			attributes: [][2]string{
+				{"Character", "X-YZ"},
+				{"Footwear", "Golden Knight Boots"},
+				{"Pants", "Brazil Pants"},
+				{"Torso", "Clown Jacket"},
+				{"Eyewear", "Round Glasses"},
+				{"Headwear", "Clown Hat"},
+				{"Left Hand", "Bow & Arrow"},
+				{"Right Hand", "Bow & Arrow"},
+				{"Background", "Strong Bliss"},
+			},
+		},
+		{
+			gene:  "1234",
+			name:  "Escrow #42",
+			image: "https://storage.googleapis.com/polymorph-images/000000000000000100.jpg",
+			attributes: [][2]string{
+				{"Character", "Escrow"},
+				{"Footwear", "No shoes"},
+				{"Pants", "Underwear"},
+				{"Torso", "No Torso"},
+				{"Eyewear", "No Eyewear"},
+				{"Headwear", "No Headwear"},
+				{"Left Hand", "No Left Hand Accesories"},
+				{"Right Hand", "No Right Hand Accesories"},
+				{"Background", "Angel Tears"},
+			},
+		},
+		{
+			gene:  "999999999999999999999",
+			name:  "Diamond Paws #42",
+			image: "https://storage.googleapis.com/polymorph-images/030306083100240003.jpg",
+			attributes: [][2]string{
+				{"Character", "Diamond Paws"},
+				{"Footwear", "White-Yellow Football Cleats"},
+				{"Pants", "Underwear"},
+				{"Torso", "Tuxedo Jacket"},
+				{"Eyewear", "Respirator"},
+				{"Headwear", "Black Ushanka"},
+				{"Left Hand", "Banana"},
+				{"Right Hand", "Banana"},
+				{"Background", "Deep Relief"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		m, err := d.Metadata("42", test.gene)
+		if err != nil {
+			t.Fatalf("gene %v: %v", test.gene, err)
+		}
+		if m.Name != test.name {
+			t.Errorf("gene %v: got name %q, expected %q", test.gene, m.Name, test.name)
+		}
+		if m.Image != test.image {
+			t.Errorf("gene %v: got image %q, expected %q", test.gene, m.Image, test.image)
+		}
+		if len(m.Attributes) != len(test.attributes) {
+			t.Fatalf("gene %v: got %v attributes, expected %v", test.gene, len(m.Attributes), len(test.attributes))
+		}
+		for i, attr := range m.Attributes {
+			if attr.TraitType != test.attributes[i][0] || attr.Value != test.attributes[i][1] {
+				t.Errorf("gene %v: got %v %q, expected %v %q", test.gene, attr.TraitType, attr.Value, test.attributes[i][0], test.attributes[i][1])
+			}
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package genome
+
+import "strings"
+
+// Differences compares two genes and returns the index of the last differing digit and the number of differing digits.
+//
+// The index is counted from the end of the gene so it can be passed to Decoder.SlotAt.
+// Genes of different length are padded with leading zeroes. An old gene equal to "0" means there is no previous gene.
+func Differences(oldGene string, newGene string) (int, int) {
+	var differences, geneIndex int
+	if oldGene == "0" {
+		return 0, 0
+	}
+
+	// bigInt.String() removes leading zeroes so we have to recover them
+	if len(oldGene) < len(newGene) {
+		oldGene = strings.Repeat("0", len(newGene)-len(oldGene)) + oldGene
+	} else if len(newGene) < len(oldGene) {
+		newGene = strings.Repeat("0", len(oldGene)-len(newGene)) + newGene
+	}
+
+	for i := 0; i < len(oldGene); i++ {
+		idx := len(oldGene) - 1 - i
+		if oldGene[idx] != newGene[idx] {
+			differences++
+			geneIndex = i
+		}
+	}
+
+	return geneIndex, differences
+}
+
+// Changes decodes the slot changed between the two genes.
+//
+// Returns the old attribute, the new attribute and the number of differing digits.
+// The attributes are empty if the genes are equal or the differing digit isn't part of the layout.
+func (d *Decoder) Changes(oldGene string, newGene string) (Attribute, Attribute, int, error) {
+	if err := validateGene(oldGene); err != nil {
+		return Attribute{}, Attribute{}, 0, err
+	}
+	if err := validateGene(newGene); err != nil {
+		return Attribute{}, Attribute{}, 0, err
+	}
+
+	geneIdx, differences := Differences(oldGene, newGene)
+	if differences == 0 {
+		return Attribute{}, Attribute{}, 0, nil
+	}
+
+	slot, ok := d.SlotAt(geneIdx)
+	if !ok {
+		return Attribute{}, Attribute{}, differences, nil
+	}
+	return d.attribute(oldGene, slot), d.attribute(newGene, slot), differences, nil
+}
@@ -0,0 +1,61 @@
+package genome
+
+import "testing"
+
+func TestDifferences(t *testing.T) {
+	tests := []struct {
+		name        string
+		oldGene     string
+		newGene     string
+		index       int
+		differences int
+	}{
+		{"no previous gene", "0", "123456789012345678", 0, 0},
+		{"equal genes", "123456789012345678", "123456789012345678", 0, 0},
+		{"last digit", "123456789012345678", "123456789012345679", 0, 1},
+		{"character slot", "123456789012345678", "123456789012345608", 1, 1},
+		{"morph two digits", "123456789012345678", "123456789012335778", 4, 2},
+		{"first digit", "123456789012345678", "923456789012345678", 17, 1},
+		{"scramble", "123456789012345678", "987654321098765432", 17, 15},
+		{"shorter new gene", "100000000000000001", "2", 17, 2},
+		{"shorter old gene", "5", "10000000000000005", 16, 1},
+		{"leading zeroes", "0000123", "124", 0, 1},
+	}
+
+	for _, test := range tests {
+		index, differences := Differences(test.oldGene, test.newGene)
+		if index != test.index || differences != test.differences {
+			t.Errorf("%v: got index %v and %v differences, expected index %v and %v differences", test.name, index, differences, test.index, test.differences)
+		}
+	}
+}
+
+func TestChanges(t *testing.T) {
+	d := newTestDecoder(t)
+
+	tests := []struct {
+		name        string
+		oldGene     string
+		newGene     string
+		oldValue    string
+		newValue    string
+		differences int
+	}{
+		{"headwear morph", "000000000000000000", "000003000000000000", "Headwear 0", "Headwear 3", 1},
+		{"left hand morph", "010000000000000000", "120000000000000000", "Left Hand 1", "Left Hand 12", 2},
+		{"pants wrap around", "000000000000330000", "000000000000340000", "Pants 0", "Pants 1", 1},
+		{"outside of layout", "1000000000000000000", "2000000000000000000", "", "", 1},
+		{"equal genes", "123", "123", "", "", 0},
+	}
+
+	for _, test := range tests {
+		oldAttr, newAttr, differences, err := d.Changes(test.oldGene, test.newGene)
+		if err != nil {
+			t.Fatalf("%v: %v", test.name, err)
+		}
+		if oldAttr.Value != test.oldValue || newAttr.Value != test.newValue || differences != test.differences {
+			t.Errorf("%v: got %q -> %q with %v differences, expected %q -> %q with %v differences",
+				test.name, oldAttr.Value, newAttr.Value, differences, test.oldValue, test.newValue, test.differences)
+		}
+	}
+}
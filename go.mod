@@ -5,11 +5,13 @@ go 1.16
 require (
 	cloud.google.com/go/storage v1.5.0
 	github.com/aws/aws-sdk-go v1.39.4 // indirect
+	github.com/chai2010/webp v1.1.0
 	github.com/disintegration/imaging v1.6.2
 	github.com/ethereum/go-ethereum v1.10.4
 	github.com/gofiber/fiber v1.14.6
 	github.com/golang/snappy v0.0.4 // indirect
 	github.com/gorilla/schema v1.2.0 // indirect
+	github.com/graphql-go/graphql v0.8.1
 	github.com/jasonlvhit/gocron v0.0.1
 	github.com/joho/godotenv v1.3.0
 	github.com/klauspost/compress v1.13.1 // indirect
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"rarity-backend/config"
+	"rarity-backend/constants"
+	"rarity-backend/db"
+	"rarity-backend/helpers"
+	"rarity-backend/structs"
+	"strconv"
+
+	"github.com/gofiber/fiber"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GetActivity endpoint returns the history snapshots of all polymorphs of the collection, newest first.
+//
+// Returns a page with the total number of matching snapshots, the cursor of the next page and the results
+//
+//	Accepted query parameters:
+//
+//		Take - int - Sets the number of results that should be returned. Default is config.DEFAULT_PAGE_SIZE, at most config.RESULTS_LIMIT
+//
+//		Cursor - string - nextCursor of the previous page
+//
+//		Type - Morph/Scramble - only returns snapshots of this type
+//
+//		Attribute - string - only returns morphs which changed this trait type, e.g. "Headwear"
+//
+//		Character - string - only returns snapshots of polymorphs with this character
+//
+//		From, To - date or RFC3339 time - only returns snapshots in this time range. A date in To includes the whole day
+//
+//		MinPrice, MaxPrice - float - only returns snapshots with a price in this range
+//
+// Returns 400 if a param is invalid
+func GetActivity(c *fiber.Ctx) {
+	dbInfo, ok := getDBInfo(c)
+	if !ok {
+		c.Status(404).Send("Unknown collection")
+		return
+	}
+
+	queryParams := structs.ActivityQueryParams{}
+	if err := c.QueryParser(&queryParams); err != nil {
+		log.Println(err)
+	}
+
+	filter, err := helpers.ParseActivityFilter(queryParams)
+	if err != nil {
+		c.Status(400).Send(err.Error())
+		return
+	}
+
+	take := config.DEFAULT_PAGE_SIZE
+	if queryParams.Take != "" {
+		take, err = strconv.ParseInt(queryParams.Take, 10, 64)
+		if err != nil || take < 1 {
+			c.Status(400).Send("Invalid take")
+			return
+		}
+		if take > config.RESULTS_LIMIT {
+			take = config.RESULTS_LIMIT
+		}
+	}
+
+	// The cursor is only applied to the page query, the total counts all matching snapshots
+	pageFilter := filter
+	if queryParams.Cursor != "" {
+		cursorFilter, err := helpers.ActivityCursorFilter(queryParams.Cursor)
+		if err != nil {
+			c.Status(400).Send(err.Error())
+			return
+		}
+		pageFilter = bson.M{"$and": bson.A{filter, cursorFilter}}
+	}
+
+	collection, err := db.GetMongoDbCollection(dbInfo.PolymorphDBName, dbInfo.HistoryCollectionName)
+	if err != nil {
+		c.Status(500).Send(err)
+		return
+	}
+
+	total, err := collection.CountDocuments(context.Background(), filter)
+	if err != nil {
+		c.Status(500).Send(err)
+		return
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: constants.HistoryFieldNames.DateTime, Value: -1}, {Key: constants.HistoryFieldNames.ObjId, Value: -1}}).
+		SetLimit(take)
+	curr, err := collection.Find(context.Background(), pageFilter, findOptions)
+	if err != nil {
+		c.Status(500).Send(err)
+		return
+	}
+
+	defer curr.Close(context.Background())
+
+	results := []bson.M{}
+	if err := curr.All(context.Background(), &results); err != nil {
+		c.Status(500).Send(err)
+		return
+	}
+
+	page := structs.ActivityPage{Total: total, Results: results}
+	if int64(len(results)) == take {
+		page.NextCursor, err = helpers.NextActivityCursor(results[len(results)-1])
+		if err != nil {
+			c.Status(500).Send(err)
+			return
+		}
+	}
+
+	json, _ := json.Marshal(page)
+	c.Set("Content-Type", "application/json")
+	c.Send(json)
+}
+
+// EnsureActivityIndex creates the index used to sort the history snapshots of the activity endpoint.
+//
+// Creating an index which already exists with the same options does nothing
+func EnsureActivityIndex(polymorphDBName string, historyCollectionName string) error {
+	collection, err := db.GetMongoDbCollection(polymorphDBName, historyCollectionName)
+	if err != nil {
+		return err
+	}
+
+	index := mongo.IndexModel{
+		Keys: bson.D{{Key: constants.HistoryFieldNames.DateTime, Value: -1}, {Key: constants.HistoryFieldNames.ObjId, Value: -1}},
+	}
+	_, err = collection.Indexes().CreateOne(context.Background(), index)
+	return err
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"rarity-backend/config"
+	"rarity-backend/structs"
+	"sync"
+
+	"github.com/gofiber/fiber"
+	"github.com/joho/godotenv"
+)
+
+var envCollectionOnce sync.Once
+var envCollection structs.Collection
+var envConfigOnce sync.Once
+var envConfigService *structs.ConfigService
+
+// getCollection resolves the collection requested in the route.
+//
+// Routes without collection parameter use the default registered collection. If no collections are registered (e.g. the API runs as a cloud function), the .env variables are used.
+//
+// Returns false if the requested collection doesn't exist
+func getCollection(c *fiber.Ctx) (structs.Collection, bool) {
+	name := c.Params("collection")
+	collection, ok := config.GetCollection(name)
+	if ok {
+		return collection, true
+	}
+	if name != "" {
+		return structs.Collection{}, false
+	}
+
+	envCollectionOnce.Do(func() {
+		godotenv.Load()
+		envCollection = config.CollectionFromEnv()
+		if envCollection.ConfigPath == "" {
+			envCollection.ConfigPath = config.DEFAULT_CONFIG_PATH
+		}
+	})
+	return envCollection, true
+}
+
+// getDBInfo resolves the database information of the collection requested in the route.
+//
+// Returns false if the requested collection doesn't exist
+func getDBInfo(c *fiber.Ctx) (structs.DBInfo, bool) {
+	collection, ok := getCollection(c)
+	return collection.DBInfo, ok
+}
+
+// getConfigService returns the trait configuration of the collection. The configuration of the .env collection is loaded on first use
+func getConfigService(collection structs.Collection) *structs.ConfigService {
+	if collection.ConfigService != nil {
+		return collection.ConfigService
+	}
+
+	envConfigOnce.Do(func() {
+		envConfigService = config.NewConfigService(collection.ConfigPath)
+	})
+	return envConfigService
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"context"
+	"rarity-backend/constants"
+	"rarity-backend/db"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// GetRarityDocuments returns every polymorph of the rarity collection
+func GetRarityDocuments(polymorphDBName string, rarityCollectionName string) ([]bson.M, error) {
+	return findAll(polymorphDBName, rarityCollectionName, bson.M{})
+}
+
+// GetHistoryDocuments returns the history snapshots between the from and to times, both inclusive. Times are RFC3339 UTC strings, like the ones of the snapshots
+func GetHistoryDocuments(polymorphDBName string, historyCollectionName string, from string, to string) ([]bson.M, error) {
+	return findAll(polymorphDBName, historyCollectionName, bson.M{
+		constants.HistoryFieldNames.DateTime: bson.M{"$gte": from, "$lte": to},
+	})
+}
+
+// GetAllHistoryDocuments returns every history snapshot of the history collection
+func GetAllHistoryDocuments(polymorphDBName string, historyCollectionName string) ([]bson.M, error) {
+	return findAll(polymorphDBName, historyCollectionName, bson.M{})
+}
+
+// DropDatabase deletes the database with all its collections
+func DropDatabase(dbName string) error {
+	client, err := db.GetDbConnection()
+	if err != nil {
+		return err
+	}
+	return client.Database(dbName).Drop(context.Background())
+}
+
+func findAll(polymorphDBName string, collectionName string, filter bson.M) ([]bson.M, error) {
+	collection, err := db.GetMongoDbCollection(polymorphDBName, collectionName)
+	if err != nil {
+		return nil, err
+	}
+
+	curr, err := collection.Find(context.Background(), filter)
+	if err != nil {
+		return nil, err
+	}
+	defer curr.Close(context.Background())
+
+	results := []bson.M{}
+	err = curr.All(context.Background(), &results)
+	return results, err
+}
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"rarity-backend/cache"
+	"rarity-backend/config"
+	"rarity-backend/constants"
+	"rarity-backend/db"
+	"rarity-backend/helpers"
+	"rarity-backend/structs"
+	"strconv"
+
+	"github.com/gofiber/fiber"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// economicsQuery computes the response of a morph economics endpoint. Returns *invalidQueryError if the query params are invalid
+type economicsQuery func(dbInfo structs.DBInfo, c *fiber.Ctx) (interface{}, error)
+
+// economicsHandler serves the result of the query as json. Responses are cached by name and query params until any polymorph of the collection changes
+func economicsHandler(responseCache *cache.ResponseCache, name string, query economicsQuery) func(*fiber.Ctx) {
+	return func(c *fiber.Ctx) {
+		dbInfo, ok := getDBInfo(c)
+		if !ok {
+			c.Status(404).Send("Unknown collection")
+			return
+		}
+
+		namespace := cache.Namespace(dbInfo)
+		params, _ := json.Marshal([]string{c.Query("from"), c.Query("to"), c.Query("take")})
+		key := responseCache.Key(namespace+"|economics|"+name+"|"+string(params), cache.ListTag(namespace))
+		if body, ok := responseCache.Get(key); ok {
+			sendCachedJSON(c, body)
+			return
+		}
+
+		result, err := query(dbInfo, c)
+		if _, ok := err.(*invalidQueryError); ok {
+			c.Status(400).Send(err.Error())
+			return
+		} else if err != nil {
+			c.Status(500).Send(err)
+			return
+		}
+
+		json, _ := json.Marshal(result)
+		responseCache.Set(key, json)
+		c.Set("Content-Type", "application/json")
+		c.Send(json)
+	}
+}
+
+// GetMorphEconomics endpoint returns the number of morphs and scrambles, the ETH spent on them and the average spend per morphed polymorph
+func GetMorphEconomics(responseCache *cache.ResponseCache) func(*fiber.Ctx) {
+	return economicsHandler(responseCache, "summary", func(dbInfo structs.DBInfo, c *fiber.Ctx) (interface{}, error) {
+		var summary structs.MorphEconomicsSummary
+		err := aggregateHistory(dbInfo, helpers.MorphSummaryPipeline(), func(curr *mongo.Cursor) error {
+			if curr.Next(context.Background()) {
+				return curr.Decode(&summary)
+			}
+			return nil
+		})
+		if summary.Tokens > 0 {
+			summary.AverageSpendPerToken = summary.Volume / float64(summary.Tokens)
+		}
+		return summary, err
+	})
+}
+
+// GetDailyMorphStats endpoint returns the morph and scramble spend per UTC day, oldest first.
+//
+// The days are read from the rolled up stats if the collection has a morph stats collection, otherwise they are aggregated from the history.
+//
+//	Accepted query parameters:
+//
+//		From, To - YYYY-MM-DD - only returns the days in this range, both inclusive
+func GetDailyMorphStats(responseCache *cache.ResponseCache) func(*fiber.Ctx) {
+	return economicsHandler(responseCache, "daily", func(dbInfo structs.DBInfo, c *fiber.Ctx) (interface{}, error) {
+		var from, to string
+		var err error
+		if c.Query("from") != "" {
+			if from, err = helpers.ParseEconomicsDay(c.Query("from")); err != nil {
+				return nil, &invalidQueryError{err.Error()}
+			}
+		}
+		if c.Query("to") != "" {
+			if to, err = helpers.ParseEconomicsDay(c.Query("to")); err != nil {
+				return nil, &invalidQueryError{err.Error()}
+			}
+		}
+
+		days := []structs.DailyMorphStats{}
+		if dbInfo.MorphStatsCollectionName == "" {
+			err = aggregateHistory(dbInfo, helpers.DailyMorphStatsPipeline(from, to), func(curr *mongo.Cursor) error {
+				return curr.All(context.Background(), &days)
+			})
+			return days, err
+		}
+
+		collection, err := db.GetMongoDbCollection(dbInfo.PolymorphDBName, dbInfo.MorphStatsCollectionName)
+		if err != nil {
+			return nil, err
+		}
+		dayRange := bson.M{}
+		if from != "" {
+			dayRange["$gte"] = from
+		}
+		if to != "" {
+			dayRange["$lte"] = to
+		}
+		filter := bson.M{}
+		if len(dayRange) > 0 {
+			filter["_id"] = dayRange
+		}
+
+		curr, err := collection.Find(context.Background(), filter, options.Find().SetSort(bson.M{"_id": 1}))
+		if err != nil {
+			return nil, err
+		}
+		defer curr.Close(context.Background())
+
+		err = curr.All(context.Background(), &days)
+		return days, err
+	})
+}
+
+// GetTopMorphTokens endpoint returns the polymorphs with the highest morph and scramble spend.
+//
+//	Accepted query parameters:
+//
+//		Take - int - number of polymorphs to return. Default is config.TOP_SPENDERS_DEFAULT_SIZE, at most config.TOP_SPENDERS_LIMIT
+func GetTopMorphTokens(responseCache *cache.ResponseCache) func(*fiber.Ctx) {
+	return economicsHandler(responseCache, "tokens", func(dbInfo structs.DBInfo, c *fiber.Ctx) (interface{}, error) {
+		take, err := topSpendersTake(c.Query("take"))
+		if err != nil {
+			return nil, err
+		}
+
+		tokens := []structs.TokenMorphSpend{}
+		err = aggregateHistory(dbInfo, helpers.TopSpendersPipeline(constants.HistoryFieldNames.TokenId, take), func(curr *mongo.Cursor) error {
+			return curr.All(context.Background(), &tokens)
+		})
+		return tokens, err
+	})
+}
+
+// GetTopMorphWallets endpoint returns the wallets with the highest morph and scramble spend.
+//
+// Only the snapshots which recorded the wallet that sent the morph transaction are counted.
+//
+//	Accepted query parameters:
+//
+//		Take - int - number of wallets to return. Default is config.TOP_SPENDERS_DEFAULT_SIZE, at most config.TOP_SPENDERS_LIMIT
+func GetTopMorphWallets(responseCache *cache.ResponseCache) func(*fiber.Ctx) {
+	return economicsHandler(responseCache, "wallets", func(dbInfo structs.DBInfo, c *fiber.Ctx) (interface{}, error) {
+		take, err := topSpendersTake(c.Query("take"))
+		if err != nil {
+			return nil, err
+		}
+
+		wallets := []structs.WalletMorphSpend{}
+		err = aggregateHistory(dbInfo, helpers.TopSpendersPipeline(constants.HistoryFieldNames.Wallet, take), func(curr *mongo.Cursor) error {
+			return curr.All(context.Background(), &wallets)
+		})
+		return wallets, err
+	})
+}
+
+// GetTraitSlotMorphs endpoint returns the number of morphs per changed trait type and the ETH spent on them, most changed first
+func GetTraitSlotMorphs(responseCache *cache.ResponseCache) func(*fiber.Ctx) {
+	return economicsHandler(responseCache, "traits", func(dbInfo structs.DBInfo, c *fiber.Ctx) (interface{}, error) {
+		slots := []structs.TraitSlotMorphs{}
+		err := aggregateHistory(dbInfo, helpers.TraitSlotMorphsPipeline(), func(curr *mongo.Cursor) error {
+			return curr.All(context.Background(), &slots)
+		})
+		return slots, err
+	})
+}
+
+// aggregateHistory runs the pipeline on the history collection and passes the cursor to read
+func aggregateHistory(dbInfo structs.DBInfo, pipeline mongo.Pipeline, read func(*mongo.Cursor) error) error {
+	collection, err := db.GetMongoDbCollection(dbInfo.PolymorphDBName, dbInfo.HistoryCollectionName)
+	if err != nil {
+		return err
+	}
+
+	curr, err := collection.Aggregate(context.Background(), pipeline)
+	if err != nil {
+		return err
+	}
+	defer curr.Close(context.Background())
+
+	return read(curr)
+}
+
+func topSpendersTake(s string) (int64, error) {
+	if s == "" {
+		return config.TOP_SPENDERS_DEFAULT_SIZE, nil
+	}
+	take, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || take < 1 {
+		return 0, &invalidQueryError{"Invalid take"}
+	}
+	if take > config.TOP_SPENDERS_LIMIT {
+		take = config.TOP_SPENDERS_LIMIT
+	}
+	return take, nil
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"context"
+	"rarity-backend/constants"
+	"rarity-backend/db"
+	"rarity-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// FailedRenderQueue stores the failed renders of the image generator in the database. It implements metadata.FailedRenderQueue
+type FailedRenderQueue struct {
+	PolymorphDBName         string
+	FailedRendersCollection string
+}
+
+// Push upserts the failed render by its image name and increments its attempts
+func (q FailedRenderQueue) Push(render models.FailedRender) error {
+	collection, err := db.GetMongoDbCollection(q.PolymorphDBName, q.FailedRendersCollection)
+	if err != nil {
+		return err
+	}
+
+	update := bson.M{
+		"$set": bson.M{
+			constants.FailedRenderFieldNames.Genes:    render.Genes,
+			constants.FailedRenderFieldNames.Error:    render.Error,
+			constants.FailedRenderFieldNames.FailedAt: render.FailedAt,
+		},
+		"$inc": bson.M{constants.FailedRenderFieldNames.Attempts: 1},
+	}
+	filter := bson.M{constants.FailedRenderFieldNames.Image: render.Image}
+
+	_, err = collection.UpdateOne(context.Background(), filter, update, options.Update().SetUpsert(true))
+	return err
+}
+
+// All returns the failed renders, oldest failures first
+func (q FailedRenderQueue) All() ([]models.FailedRender, error) {
+	collection, err := db.GetMongoDbCollection(q.PolymorphDBName, q.FailedRendersCollection)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := options.Find().SetSort(bson.M{constants.FailedRenderFieldNames.FailedAt: 1})
+	cur, err := collection.Find(context.Background(), bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var renders []models.FailedRender
+	err = cur.All(context.Background(), &renders)
+	return renders, err
+}
+
+// Remove deletes the render of the image from the queue
+func (q FailedRenderQueue) Remove(image string) error {
+	collection, err := db.GetMongoDbCollection(q.PolymorphDBName, q.FailedRendersCollection)
+	if err != nil {
+		return err
+	}
+
+	_, err = collection.DeleteOne(context.Background(), bson.M{constants.FailedRenderFieldNames.Image: image})
+	return err
+}
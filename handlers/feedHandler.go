@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"rarity-backend/config"
+	"rarity-backend/events"
+	"rarity-backend/structs"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber"
+)
+
+// GetFeed endpoint streams the mint, morph, scramble and rank change events of the collection as Server-Sent Events.
+//
+//	Accepted query parameters:
+//
+//		Types - string - comma separated list of event types to receive, e.g. "morph,scramble". Default is all types
+//
+// Clients which reconnect with the Last-Event-ID header receive the recent events they missed.
+//
+// Returns 503 if the collection isn't indexed by this instance
+func GetFeed(hubs map[string]*events.Hub) func(*fiber.Ctx) {
+	return func(c *fiber.Ctx) {
+		collection, ok := getCollection(c)
+		if !ok {
+			c.Status(404).Send("Unknown collection")
+			return
+		}
+		hub := hubs[collection.Name]
+		if hub == nil {
+			c.Status(503).Send("Live feed isn't available")
+			return
+		}
+
+		var lastId uint64
+		if header := c.Get("Last-Event-ID"); header != "" {
+			parsed, err := strconv.ParseUint(header, 10, 64)
+			if err != nil {
+				c.Status(400).Send("Invalid Last-Event-ID")
+				return
+			}
+			lastId = parsed
+		}
+		var types map[string]bool
+		if c.Query("types") != "" {
+			types = make(map[string]bool)
+			for _, eventType := range strings.Split(c.Query("types"), ",") {
+				types[strings.ToLower(strings.TrimSpace(eventType))] = true
+			}
+		}
+
+		subscriber, missed, unsubscribe := hub.Subscribe(lastId)
+
+		c.Set("Content-Type", "text/event-stream")
+		c.Set("Cache-Control", "no-cache")
+		c.Set("Connection", "keep-alive")
+		c.Set("X-Accel-Buffering", "no")
+		c.Fasthttp.SetBodyStreamWriter(func(w *bufio.Writer) {
+			defer unsubscribe()
+
+			send := func(event structs.FeedEvent) error {
+				if types != nil && !types[event.Type] {
+					return nil
+				}
+				return writeFeedEvent(w, event)
+			}
+			for _, event := range missed {
+				if send(event) != nil {
+					return
+				}
+			}
+			// The first write sends the headers to the client
+			if _, err := w.WriteString(": connected\n\n"); err != nil || w.Flush() != nil {
+				return
+			}
+
+			keepalive := time.NewTicker(config.FEED_KEEPALIVE_INTERVAL)
+			defer keepalive.Stop()
+			for {
+				select {
+				case event, ok := <-subscriber:
+					if !ok {
+						return
+					}
+					if send(event) != nil {
+						return
+					}
+				case <-keepalive.C:
+					if _, err := w.WriteString(": keepalive\n\n"); err != nil || w.Flush() != nil {
+						return
+					}
+				}
+			}
+		})
+	}
+}
+
+// writeFeedEvent writes the event in the event stream format and flushes it to the client
+func writeFeedEvent(w *bufio.Writer, event structs.FeedEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Id, event.Type, data); err != nil {
+		return err
+	}
+	return w.Flush()
+}
@@ -3,55 +3,70 @@ package handlers
 import (
 	"context"
 	"encoding/json"
-	"os"
+	"rarity-backend/cache"
 	"rarity-backend/constants"
 	"rarity-backend/db"
+	"strconv"
 
 	"github.com/gofiber/fiber"
-	"github.com/joho/godotenv"
 	"go.mongodb.org/mongo-driver/bson"
 )
 
 // GetPolymorphHistory endpoints accepts id of a single polymorph and returns all history snapshot from the database.
 //
-// History snapshots represent the changes made by scrambling or morphing this polymorph.
-func GetPolymorphHistory(c *fiber.Ctx) {
-	godotenv.Load()
+// History snapshots represent the changes made by scrambling or morphing this polymorph. Responses are cached until the polymorph gets a new snapshot.
+func GetPolymorphHistory(responseCache *cache.ResponseCache) func(*fiber.Ctx) {
+	return func(c *fiber.Ctx) {
+		dbInfo, ok := getDBInfo(c)
+		if !ok {
+			c.Status(404).Send("Unknown collection")
+			return
+		}
 
-	polymorphDBName := os.Getenv("POLYMORPH_DB")
-	historyColelctionName := os.Getenv("HISTORY_COLLECTION")
+		collection, err := db.GetMongoDbCollection(dbInfo.PolymorphDBName, dbInfo.HistoryCollectionName)
+		if err != nil {
+			c.Status(500).Send(err)
+			return
+		}
 
-	collection, err := db.GetMongoDbCollection(polymorphDBName, historyColelctionName)
-	if err != nil {
-		c.Status(500).Send(err)
-		return
-	}
-
-	var filter bson.M = bson.M{}
-	if c.Params("id") != "" {
-		id := c.Params("id")
-		filter = bson.M{constants.MorphFieldNames.TokenId: id}
-	}
+		// Snapshots store the token id as a number
+		tokenId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			c.Status(400).Send("Invalid token id")
+			return
+		}
+		filter := bson.M{constants.HistoryFieldNames.TokenId: tokenId}
 
-	var results []bson.M
-	curr, err := collection.Find(context.Background(), filter)
+		namespace := cache.Namespace(dbInfo)
+		key := responseCache.Key(namespace+"|history|"+strconv.Itoa(tokenId), cache.HistoryTag(namespace, tokenId))
+		if body, ok := responseCache.Get(key); ok {
+			sendCachedJSON(c, body)
+			return
+		}
 
-	if err != nil {
-		c.Status(500).Send(err)
-		return
-	}
+		var results []bson.M
+		curr, err := collection.Find(context.Background(), filter)
 
-	defer curr.Close(context.Background())
+		if err != nil {
+			c.Status(500).Send(err)
+			return
+		}
 
-	curr.All(context.Background(), &results)
-	//TODO: Convert tokenid to be integer in database
+		defer curr.Close(context.Background())
 
-	if results == nil {
-		c.Send(bson.M{})
-		return
-	}
+		if err := curr.All(context.Background(), &results); err != nil {
+			c.Status(500).Send(err)
+			return
+		}
 
-	json, _ := json.Marshal(results)
-	c.Send(json)
+		if results == nil {
+			c.Send(bson.M{})
+			return
+		}
 
+		json, _ := json.Marshal(results)
+		responseCache.Set(key, json)
+		c.Set("Content-Type", "application/json")
+		c.Send(json)
+	}
 }
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"rarity-backend/config"
+	"rarity-backend/helpers"
+	"rarity-backend/structs"
+
+	"github.com/gofiber/fiber"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+)
+
+// graphQLRequest is the body of a GraphQL POST request
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+// GraphQL endpoint executes GraphQL queries against the schema for the collection of the route.
+//
+// Queries are accepted as json body of POST requests or as query, variables and operationName query parameters of GET requests.
+//
+// Queries deeper than config.GRAPHQL_MAX_DEPTH or more complex than config.GRAPHQL_MAX_COMPLEXITY are rejected with 400 before they are executed
+func GraphQL(schema graphql.Schema) func(*fiber.Ctx) {
+	limits := helpers.QueryLimits{
+		MaxDepth:        config.GRAPHQL_MAX_DEPTH,
+		MaxComplexity:   config.GRAPHQL_MAX_COMPLEXITY,
+		ListFields:      GRAPHQL_LIST_FIELDS,
+		FieldCosts:      GRAPHQL_FIELD_COSTS,
+		DefaultListSize: int(config.DEFAULT_PAGE_SIZE),
+		MaxListSize:     int(config.RESULTS_LIMIT),
+	}
+
+	return func(c *fiber.Ctx) {
+		collection, ok := getCollection(c)
+		if !ok {
+			c.Status(404).Send("Unknown collection")
+			return
+		}
+
+		var request graphQLRequest
+		if c.Method() == fiber.MethodPost {
+			if err := json.Unmarshal([]byte(c.Body()), &request); err != nil {
+				sendGraphQLResult(c, 400, &graphql.Result{Errors: []gqlerrors.FormattedError{gqlerrors.NewFormattedError("Invalid request body")}})
+				return
+			}
+		} else {
+			request.Query = c.Query("query")
+			request.OperationName = c.Query("operationName")
+			if variables := c.Query("variables"); variables != "" {
+				if err := json.Unmarshal([]byte(variables), &request.Variables); err != nil {
+					sendGraphQLResult(c, 400, &graphql.Result{Errors: []gqlerrors.FormattedError{gqlerrors.NewFormattedError("Invalid variables")}})
+					return
+				}
+			}
+		}
+
+		if err := helpers.CheckQueryLimits(request.Query, request.Variables, limits); err != nil {
+			sendGraphQLResult(c, 400, &graphql.Result{Errors: []gqlerrors.FormattedError{gqlerrors.NewFormattedError(err.Error())}})
+			return
+		}
+
+		result := graphql.Do(graphql.Params{
+			Schema:         schema,
+			RequestString:  request.Query,
+			VariableValues: request.Variables,
+			OperationName:  request.OperationName,
+			Context:        graphQLContext(collection),
+		})
+		sendGraphQLResult(c, 200, result)
+	}
+}
+
+// graphQLContext returns the context of the resolvers of a request with the requested collection and fresh batch loaders
+func graphQLContext(collection structs.Collection) context.Context {
+	ctx := context.WithValue(context.Background(), collectionContextKey, collection)
+	return context.WithValue(ctx, loadersContextKey, helpers.NewBatchLoaders())
+}
+
+func sendGraphQLResult(c *fiber.Ctx, status int, result *graphql.Result) {
+	json, _ := json.Marshal(result)
+	c.Set("Content-Type", "application/json")
+	c.Status(status).Send(json)
+}
@@ -0,0 +1,466 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"rarity-backend/config"
+	"rarity-backend/constants"
+	"rarity-backend/db"
+	"rarity-backend/helpers"
+	"rarity-backend/structs"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// OwnerLookup returns the address of the token owner. It returns an empty address if the owner can't be looked up, e.g. the API runs without an ethereum client
+type OwnerLookup func(collection structs.Collection, tokenId int) (string, error)
+
+type graphQLContextKey string
+
+// collectionContextKey stores the collection requested in the route in the context of the GraphQL resolvers
+const collectionContextKey graphQLContextKey = "collection"
+
+// loadersContextKey stores the batch loaders of the request in the context of the GraphQL resolvers
+const loadersContextKey graphQLContextKey = "loaders"
+
+// GRAPHQL_LIST_FIELDS are the list fields of the schema which have a take argument
+var GRAPHQL_LIST_FIELDS = map[string]bool{
+	"polymorphs":  true,
+	"history":     true,
+	"rankHistory": true,
+}
+
+// GRAPHQL_FIELD_COSTS are the fields of the schema which cost more than one to resolve
+var GRAPHQL_FIELD_COSTS = map[string]int{
+	"owner": config.GRAPHQL_OWNER_COST,
+}
+
+var graphQLFieldTypes = map[string]graphql.Output{
+	config.FIELD_TYPE_INT:          graphql.Int,
+	config.FIELD_TYPE_FLOAT:        graphql.Float,
+	config.FIELD_TYPE_BOOL:         graphql.Boolean,
+	config.FIELD_TYPE_STRING:       graphql.String,
+	config.FIELD_TYPE_STRING_ARRAY: graphql.NewList(graphql.String),
+}
+
+// NewGraphQLSchema builds the GraphQL schema over the polymorphs, their history, the trait catalog and the collection stats.
+//
+// The Polymorph type contains every public field of config.MORPH_FIELDS. The polymorphs query uses the same filter, search, sort and paging as GET /morphs/
+func NewGraphQLSchema(ownerOf OwnerLookup) (graphql.Schema, error) {
+	takeArgument := &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: int(config.DEFAULT_PAGE_SIZE)}
+
+	historyType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "HistoryEntry",
+		Fields: graphql.Fields{
+			"type":              &graphql.Field{Type: graphql.String},
+			"tokenid":           &graphql.Field{Type: graphql.Int},
+			"datetime":          &graphql.Field{Type: graphql.String, Resolve: bsonFieldResolver("datetime")},
+			"attributechanged":  &graphql.Field{Type: graphql.String},
+			"previousattribute": &graphql.Field{Type: graphql.String},
+			"newattribute":      &graphql.Field{Type: graphql.String},
+			"price":             &graphql.Field{Type: graphql.Float},
+			"imageurl":          &graphql.Field{Type: graphql.String},
+			"newgene":           &graphql.Field{Type: graphql.String},
+			"oldgene":           &graphql.Field{Type: graphql.String},
+			"character":         &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	rankChangeType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "RankChange",
+		Fields: graphql.Fields{
+			constants.RankHistoryFieldNames.TokenId:      &graphql.Field{Type: graphql.Int},
+			constants.RankHistoryFieldNames.Rank:         &graphql.Field{Type: graphql.Int},
+			constants.RankHistoryFieldNames.PreviousRank: &graphql.Field{Type: graphql.Int},
+			constants.RankHistoryFieldNames.DateTime:     &graphql.Field{Type: graphql.String, Resolve: bsonFieldResolver(constants.RankHistoryFieldNames.DateTime)},
+		},
+	})
+
+	polymorphFields := graphql.Fields{
+		config.SEARCH_SCORE_FIELD: &graphql.Field{Type: graphql.Float, Description: "Relevance of the polymorph to the search. Only set for searches sorted by relevance"},
+		"owner": &graphql.Field{
+			Type: graphql.String,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				tokenId, ok := polymorphTokenId(p.Source)
+				if !ok || ownerOf == nil {
+					return nil, nil
+				}
+				collection := graphQLCollection(p.Context)
+				loader := graphQLLoaders(p.Context).Get("owner", func(tokenIds []int) (map[int]interface{}, map[int]error) {
+					return findOwners(collection, tokenIds, ownerOf)
+				})
+				return loader.Load(tokenId), nil
+			},
+		},
+		"history": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(historyType))),
+			Args: graphql.FieldConfigArgument{"take": takeArgument},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				dbInfo := graphQLCollection(p.Context).DBInfo
+				return loadTokenDocuments(p, dbInfo.HistoryCollectionName, "_id")
+			},
+		},
+		"rankHistory": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(rankChangeType))),
+			Args: graphql.FieldConfigArgument{"take": takeArgument},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				dbInfo := graphQLCollection(p.Context).DBInfo
+				if dbInfo.RankHistoryCollectionName == "" {
+					return []bson.M{}, nil
+				}
+				return loadTokenDocuments(p, dbInfo.RankHistoryCollectionName, constants.RankHistoryFieldNames.DateTime)
+			},
+		},
+	}
+	for name, spec := range config.MORPH_FIELDS {
+		if spec.Public {
+			polymorphFields[name] = &graphql.Field{Type: graphQLFieldTypes[spec.Type]}
+		}
+	}
+	polymorphType := graphql.NewObject(graphql.ObjectConfig{Name: "Polymorph", Fields: polymorphFields})
+
+	facetCountType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "FacetCount",
+		Fields: graphql.Fields{
+			"value": &graphql.Field{Type: graphql.String},
+			"count": &graphql.Field{Type: graphql.Int},
+		},
+	})
+	facetType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Facet",
+		Fields: graphql.Fields{
+			"field":  &graphql.Field{Type: graphql.String},
+			"values": &graphql.Field{Type: graphql.NewList(facetCountType)},
+		},
+	})
+
+	pageType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "PolymorphsPage",
+		Fields: graphql.Fields{
+			"total": &graphql.Field{Type: graphql.Int},
+			"nextCursor": &graphql.Field{
+				Type: graphql.String,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if cursor := p.Source.(structs.PolymorphsPage).NextCursor; cursor != "" {
+						return cursor, nil
+					}
+					return nil, nil
+				},
+			},
+			"sortField": &graphql.Field{
+				Type: graphql.String,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(structs.PolymorphsPage).Filters.SortField, nil
+				},
+			},
+			"sortDir": &graphql.Field{
+				Type: graphql.String,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(structs.PolymorphsPage).Filters.SortDir, nil
+				},
+			},
+			"results": &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(polymorphType)))},
+			"facets": &graphql.Field{
+				Type: graphql.NewList(graphql.NewNonNull(facetType)),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return facetList(p.Source.(structs.PolymorphsPage).Facets), nil
+				},
+			},
+		},
+	})
+
+	traitValueType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "TraitValue",
+		Fields: graphql.Fields{
+			"value": &graphql.Field{Type: graphql.String},
+			"sets":  &graphql.Field{Type: graphql.NewList(graphql.String)},
+		},
+	})
+	traitTypeType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "TraitType",
+		Fields: graphql.Fields{
+			"traitType": &graphql.Field{Type: graphql.String},
+			"values":    &graphql.Field{Type: graphql.NewList(traitValueType)},
+		},
+	})
+	setType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Set",
+		Fields: graphql.Fields{
+			"name":  &graphql.Field{Type: graphql.String},
+			"size":  &graphql.Field{Type: graphql.Int},
+			"hands": &graphql.Field{Type: graphql.NewList(graphql.String)},
+		},
+	})
+	catalogType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "TraitCatalog",
+		Fields: graphql.Fields{
+			"types": &graphql.Field{Type: graphql.NewList(traitTypeType)},
+			"sets":  &graphql.Field{Type: graphql.NewList(setType)},
+		},
+	})
+
+	statsType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "CollectionStats",
+		Fields: graphql.Fields{
+			"total":              &graphql.Field{Type: graphql.Int},
+			"virgins":            &graphql.Field{Type: graphql.Int},
+			"completedSets":      &graphql.Field{Type: graphql.Int},
+			"averageRarityScore": &graphql.Field{Type: graphql.Float},
+			"minRarityScore":     &graphql.Field{Type: graphql.Float},
+			"maxRarityScore":     &graphql.Field{Type: graphql.Float},
+			"morphs":             &graphql.Field{Type: graphql.Int},
+			"scrambles":          &graphql.Field{Type: graphql.Int},
+		},
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"polymorphs": &graphql.Field{
+				Type: graphql.NewNonNull(pageType),
+				Args: graphql.FieldConfigArgument{
+					"filter":    &graphql.ArgumentConfig{Type: graphql.String},
+					"search":    &graphql.ArgumentConfig{Type: graphql.String},
+					"sortField": &graphql.ArgumentConfig{Type: graphql.String},
+					"sortDir":   &graphql.ArgumentConfig{Type: graphql.String},
+					"take":      takeArgument,
+					"cursor":    &graphql.ArgumentConfig{Type: graphql.String},
+					"page":      &graphql.ArgumentConfig{Type: graphql.Int},
+					"facets":    &graphql.ArgumentConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return findPolymorphs(graphQLCollection(p.Context), graphQLQueryParams(p.Args))
+				},
+			},
+			"polymorph": &graphql.Field{
+				Type: polymorphType,
+				Args: graphql.FieldConfigArgument{
+					"tokenid": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return findPolymorph(graphQLCollection(p.Context).DBInfo, p.Args["tokenid"].(int))
+				},
+			},
+			"traits": &graphql.Field{
+				Type: graphql.NewNonNull(catalogType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					collection := graphQLCollection(p.Context)
+					return helpers.BuildTraitCatalog(getConfigService(collection), getRarityModel(collection)), nil
+				},
+			},
+			"stats": &graphql.Field{
+				Type: graphql.NewNonNull(statsType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return getCollectionStats(graphQLCollection(p.Context).DBInfo)
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: queryType})
+}
+
+// graphQLCollection returns the collection requested in the route of the GraphQL query
+func graphQLCollection(ctx context.Context) structs.Collection {
+	collection, _ := ctx.Value(collectionContextKey).(structs.Collection)
+	return collection
+}
+
+// graphQLLoaders returns the batch loaders of the GraphQL request
+func graphQLLoaders(ctx context.Context) *helpers.BatchLoaders {
+	loaders, ok := ctx.Value(loadersContextKey).(*helpers.BatchLoaders)
+	if !ok {
+		return helpers.NewBatchLoaders()
+	}
+	return loaders
+}
+
+// graphQLQueryParams converts the arguments of the polymorphs query to the query params of GET /morphs/
+func graphQLQueryParams(args map[string]interface{}) structs.QueryParams {
+	var queryParams structs.QueryParams
+	queryParams.Filter, _ = args["filter"].(string)
+	queryParams.Search, _ = args["search"].(string)
+	queryParams.SortField, _ = args["sortField"].(string)
+	queryParams.SortDir, _ = args["sortDir"].(string)
+	queryParams.Cursor, _ = args["cursor"].(string)
+	if take, ok := args["take"].(int); ok {
+		queryParams.Take = strconv.Itoa(take)
+	}
+	if page, ok := args["page"].(int); ok {
+		queryParams.Page = strconv.Itoa(page)
+	}
+	if facets, ok := args["facets"].([]interface{}); ok {
+		var fields []string
+		for _, facet := range facets {
+			fields = append(fields, facet.(string))
+		}
+		queryParams.Facets = strings.Join(fields, ",")
+	}
+	return queryParams
+}
+
+// findPolymorph returns the polymorph without its private fields or nil if it doesn't exist
+func findPolymorph(dbInfo structs.DBInfo, tokenId int) (bson.M, error) {
+	collection, err := db.GetMongoDbCollection(dbInfo.PolymorphDBName, dbInfo.RarityCollectionName)
+	if err != nil {
+		return nil, err
+	}
+
+	var result bson.M
+	opts := options.FindOne().SetProjection(privateFieldsProjection())
+	err = collection.FindOne(context.Background(), bson.M{constants.MorphFieldNames.TokenId: tokenId}, opts).Decode(&result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	return result, err
+}
+
+// loadTokenDocuments queues the polymorph of the resolved field in the loader of the collection and take, so the documents of every polymorph in the list are found with one query.
+//
+// Returns *invalidQueryError if take is less than 1, like findPolymorphs
+func loadTokenDocuments(p graphql.ResolveParams, collectionName string, sortField string) (interface{}, error) {
+	take := p.Args["take"].(int)
+	if take < 1 {
+		return nil, &invalidQueryError{"Invalid take"}
+	}
+	if int64(take) > config.RESULTS_LIMIT {
+		take = int(config.RESULTS_LIMIT)
+	}
+	tokenId, ok := polymorphTokenId(p.Source)
+	if !ok {
+		return []bson.M{}, nil
+	}
+
+	dbInfo := graphQLCollection(p.Context).DBInfo
+	name := collectionName + ":" + strconv.Itoa(take)
+	loader := graphQLLoaders(p.Context).Get(name, func(tokenIds []int) (map[int]interface{}, map[int]error) {
+		documents, err := findTokensDocuments(dbInfo.PolymorphDBName, collectionName, tokenIds, sortField, take)
+		if err != nil {
+			return nil, helpers.BatchErrors(tokenIds, err)
+		}
+		return documents, nil
+	})
+	return loader.Load(tokenId), nil
+}
+
+// findTokensDocuments returns at most take documents of the collection for every polymorph, sorted ascending by sortField.
+// Polymorphs without documents get an empty list
+func findTokensDocuments(polymorphDBName string, collectionName string, tokenIds []int, sortField string, take int) (map[int]interface{}, error) {
+	collection, err := db.GetMongoDbCollection(polymorphDBName, collectionName)
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline := []bson.M{
+		{"$match": bson.M{constants.MorphFieldNames.TokenId: bson.M{"$in": tokenIds}}},
+		{"$sort": bson.D{{Key: constants.MorphFieldNames.TokenId, Value: 1}, {Key: sortField, Value: 1}}},
+		{"$group": bson.M{"_id": "$" + constants.MorphFieldNames.TokenId, "documents": bson.M{"$push": "$$ROOT"}}},
+		{"$project": bson.M{"documents": bson.M{"$slice": bson.A{"$documents", take}}}},
+	}
+	curr, err := collection.Aggregate(context.Background(), pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer curr.Close(context.Background())
+
+	var groups []struct {
+		TokenId   int      `bson:"_id"`
+		Documents []bson.M `bson:"documents"`
+	}
+	if err := curr.All(context.Background(), &groups); err != nil {
+		return nil, err
+	}
+
+	documents := make(map[int]interface{})
+	for _, tokenId := range tokenIds {
+		documents[tokenId] = []bson.M{}
+	}
+	for _, group := range groups {
+		documents[group.TokenId] = group.Documents
+	}
+	return documents, nil
+}
+
+// findOwners looks up the owners of the tokens with config.GRAPHQL_OWNER_WORKERS concurrent requests.
+// Tokens whose owner can't be looked up resolve to nil
+func findOwners(collection structs.Collection, tokenIds []int, ownerOf OwnerLookup) (map[int]interface{}, map[int]error) {
+	owners := make(map[int]interface{})
+	errs := make(map[int]error)
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+
+	jobs := make(chan int)
+	for i := 0; i < config.GRAPHQL_OWNER_WORKERS; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for tokenId := range jobs {
+				owner, err := ownerOf(collection, tokenId)
+				mutex.Lock()
+				if owner != "" {
+					owners[tokenId] = owner
+				}
+				errs[tokenId] = err
+				mutex.Unlock()
+			}
+		}()
+	}
+	for _, tokenId := range tokenIds {
+		jobs <- tokenId
+	}
+	close(jobs)
+	wg.Wait()
+	return owners, errs
+}
+
+// polymorphTokenId returns the token id of a polymorph resolved by the schema
+func polymorphTokenId(polymorph interface{}) (int, bool) {
+	document, ok := polymorph.(bson.M)
+	if !ok {
+		return 0, false
+	}
+	switch id := document[constants.MorphFieldNames.TokenId].(type) {
+	case int32:
+		return int(id), true
+	case int64:
+		return int(id), true
+	case float64:
+		return int(id), true
+	}
+	return 0, false
+}
+
+// bsonFieldResolver resolves a field of a bson document. Dates are returned in RFC 3339 format
+func bsonFieldResolver(field string) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		document, _ := p.Source.(bson.M)
+		if date, ok := document[field].(primitive.DateTime); ok {
+			return date.Time().UTC().Format(time.RFC3339), nil
+		}
+		return document[field], nil
+	}
+}
+
+// facetList converts the facet counts of a page to a list sorted by field name
+func facetList(facets map[string][]structs.FacetCount) []bson.M {
+	var fields []string
+	for field := range facets {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	list := []bson.M{}
+	for _, field := range fields {
+		list = append(list, bson.M{"field": field, "values": facets[field]})
+	}
+	return list
+}
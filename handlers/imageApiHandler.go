@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"rarity-backend/constants"
+	"rarity-backend/db"
+	"rarity-backend/metadata"
+	"rarity-backend/models"
+	"rarity-backend/structs"
+	"strconv"
+
+	"github.com/gofiber/fiber"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GetTokenImage endpoint renders the image of the current gene of a polymorph.
+//
+// Query params size (one of metadata.RENDER_SIZES) and format (jpeg, png or webp) are optional.
+//
+// Returns 503 if image rendering is disabled and 404 if no polymorph is found. imageGenerators contains the image generator of each collection by collection name
+func GetTokenImage(imageGenerators map[string]*metadata.ImageGenerator) func(*fiber.Ctx) {
+	return func(c *fiber.Ctx) {
+		collection, ok := getCollection(c)
+		if !ok {
+			c.Status(404).Send("Unknown collection")
+			return
+		}
+
+		tokenId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			c.Status(400).Send("Invalid token id")
+			return
+		}
+
+		rarityCollection, err := db.GetMongoDbCollection(collection.DBInfo.PolymorphDBName, collection.DBInfo.RarityCollectionName)
+		if err != nil {
+			c.Status(500).Send(err)
+			return
+		}
+
+		var entity models.PolymorphEntity
+		err = rarityCollection.FindOne(context.Background(), bson.M{constants.MorphFieldNames.TokenId: tokenId}).Decode(&entity)
+		if err != nil {
+			c.Status(404).Send("Polymorph not found")
+			return
+		}
+
+		// The gene of a token changes when it's morphed, so the response is only cached briefly
+		sendImage(c, imageGenerators[collection.Name], getConfigService(collection), entity.CurrentGene, "public, max-age=60")
+	}
+}
+
+// GetGeneImage endpoint renders the image of a gene which a polymorph has or had. Accepts the same query params as GetTokenImage.
+//
+// Returns 400 if the gene isn't a number and 404 if no polymorph ever had the gene
+func GetGeneImage(imageGenerators map[string]*metadata.ImageGenerator) func(*fiber.Ctx) {
+	return func(c *fiber.Ctx) {
+		collection, ok := getCollection(c)
+		if !ok {
+			c.Status(404).Send("Unknown collection")
+			return
+		}
+
+		gene, ok := new(big.Int).SetString(c.Params("gene"), 10)
+		if !ok || gene.Sign() < 0 {
+			c.Status(400).Send("Invalid gene")
+			return
+		}
+
+		exists, err := geneExists(collection.DBInfo, gene.String())
+		if err != nil {
+			c.Status(500).Send(err)
+			return
+		}
+		if !exists {
+			c.Status(404).Send("Gene not found")
+			return
+		}
+
+		// The image of a gene never changes
+		sendImage(c, imageGenerators[collection.Name], getConfigService(collection), gene.String(), "public, max-age=31536000, immutable")
+	}
+}
+
+// geneExists returns whether the gene is the current or an old gene of a polymorph
+func geneExists(dbInfo structs.DBInfo, gene string) (bool, error) {
+	collection, err := db.GetMongoDbCollection(dbInfo.PolymorphDBName, dbInfo.RarityCollectionName)
+	if err != nil {
+		return false, err
+	}
+
+	filter := bson.M{"$or": []bson.M{
+		{constants.MorphFieldNames.CurrentGene: gene},
+		{constants.MorphFieldNames.OldGenes: gene},
+	}}
+	opts := options.FindOne().SetProjection(bson.M{constants.MorphFieldNames.ObjId: 1})
+	err = collection.FindOne(context.Background(), filter, opts).Err()
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// sendImage renders the gene in the size and format of the query params and sends it
+func sendImage(c *fiber.Ctx, imageGenerator *metadata.ImageGenerator, configService *structs.ConfigService, gene string, cacheControl string) {
+	if imageGenerator == nil {
+		c.Status(503).Send("Image rendering is disabled")
+		return
+	}
+
+	size := metadata.DEFAULT_RENDER_SIZE
+	if c.Query("size") != "" {
+		var err error
+		size, err = strconv.Atoi(c.Query("size"))
+		if err != nil || !metadata.ValidRenderSize(size) {
+			c.Status(400).Send("Invalid size, supported sizes are ", metadata.RENDER_SIZES)
+			return
+		}
+	}
+
+	format := c.Query("format")
+	if format == "" {
+		format = metadata.IMAGE_FORMAT_JPEG
+	}
+	contentType, ok := metadata.ImageContentTypes[format]
+	if !ok {
+		c.Status(400).Send("Invalid format, supported formats are jpeg, png and webp")
+		return
+	}
+
+	genes, err := configService.Decoder.Paths(gene)
+	if err != nil {
+		c.Status(400).Send("Invalid gene")
+		return
+	}
+
+	image, err := imageGenerator.Render(genes, size, format)
+	if errors.Is(err, metadata.ErrRenderBusy) {
+		c.Set("Retry-After", strconv.Itoa(int(metadata.RENDER_SLOT_TIMEOUT.Seconds())))
+		c.Status(503).Send("Image rendering is busy, try again later")
+		return
+	}
+	if err != nil {
+		c.Status(500).Send(err)
+		return
+	}
+
+	c.Set("Content-Type", contentType)
+	c.Set("Cache-Control", cacheControl)
+	c.Send(image)
+}
+
+// GetCurrentGenes returns the current gene of every polymorph in the rarity collection
+func GetCurrentGenes(polymorphDBName string, rarityCollectionName string) ([]string, error) {
+	collection, err := db.GetMongoDbCollection(polymorphDBName, rarityCollectionName)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := options.Find().SetProjection(bson.M{constants.MorphFieldNames.CurrentGene: 1})
+	cur, err := collection.Find(context.Background(), bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.Background())
+
+	var genes []string
+	for cur.Next(context.Background()) {
+		var entity models.PolymorphEntity
+		if err := cur.Decode(&entity); err != nil {
+			return nil, err
+		}
+		genes = append(genes, entity.CurrentGene)
+	}
+	return genes, cur.Err()
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"rarity-backend/constants"
+	"rarity-backend/db"
+	"rarity-backend/helpers"
+	"rarity-backend/metadata"
+	"rarity-backend/models"
+	"rarity-backend/structs"
+	"strconv"
+	"sync"
+
+	"github.com/gofiber/fiber"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// polymorphWithCounters is a polymorph entity with the morph counters which are only incremented in the database
+type polymorphWithCounters struct {
+	models.PolymorphEntity `bson:",inline"`
+	Morphs                 int
+	Scrambles              int
+}
+
+type cachedMetadata struct {
+	gene     string
+	metadata structs.Metadata
+}
+
+var metadataCacheMutex sync.RWMutex
+var metadataCache = map[string]cachedMetadata{}
+
+// GetTokenMetadata endpoint accepts id of a single polymorph and returns marketplace compatible token metadata.
+//
+// The gene metadata is cached per token and regenerated when the current gene of the polymorph changes. Rarity score, rank, set and morph information is added as extra attributes.
+//
+// Returns 404 if no polymorph is found
+func GetTokenMetadata(c *fiber.Ctx) {
+	collection, ok := getCollection(c)
+	if !ok {
+		c.Status(404).Send("Unknown collection")
+		return
+	}
+
+	tokenId, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		c.Status(400).Send("Invalid token id")
+		return
+	}
+
+	rarityCollection, err := db.GetMongoDbCollection(collection.DBInfo.PolymorphDBName, collection.DBInfo.RarityCollectionName)
+	if err != nil {
+		c.Status(500).Send(err)
+		return
+	}
+
+	var entity polymorphWithCounters
+	err = rarityCollection.FindOne(context.Background(), bson.M{constants.MorphFieldNames.TokenId: tokenId}).Decode(&entity)
+	if err != nil {
+		c.Status(404).Send("Polymorph not found")
+		return
+	}
+
+	total, err := rarityCollection.EstimatedDocumentCount(context.Background())
+	if err != nil {
+		c.Status(500).Send(err)
+		return
+	}
+
+	geneMetadata := getGeneMetadata(collection, entity.TokenId, entity.CurrentGene)
+	tokenMetadata := helpers.CreateTokenMetadata(geneMetadata, entity.PolymorphEntity, entity.Morphs, entity.Scrambles, total)
+
+	json, _ := json.Marshal(tokenMetadata)
+	c.Set("Content-Type", "application/json")
+	c.Send(json)
+}
+
+// getGeneMetadata returns the cached metadata of the token or regenerates it if the gene has changed
+func getGeneMetadata(collection structs.Collection, tokenId int, gene string) structs.Metadata {
+	key := collection.Name + ":" + strconv.Itoa(tokenId)
+
+	metadataCacheMutex.RLock()
+	cached, ok := metadataCache[key]
+	metadataCacheMutex.RUnlock()
+	if ok && cached.gene == gene {
+		return cached.metadata
+	}
+
+	g := metadata.Genome(gene)
+	m := (&g).Metadata(strconv.Itoa(tokenId), getConfigService(collection))
+
+	metadataCacheMutex.Lock()
+	metadataCache[key] = cachedMetadata{gene: gene, metadata: m}
+	metadataCacheMutex.Unlock()
+
+	return m
+}
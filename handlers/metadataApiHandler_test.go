@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"rarity-backend/config"
+	"rarity-backend/structs"
+	"reflect"
+	"testing"
+)
+
+func TestGetGeneMetadata(t *testing.T) {
+	collection := structs.Collection{Name: "metadata-test", ConfigService: config.NewConfigService("../config.json")}
+	gene, morphedGene := "123456789012345678", "123406789012345678"
+
+	first := getGeneMetadata(collection, 1, gene)
+	if first.Name == "" || len(first.Attributes) == 0 {
+		t.Fatalf("Got %+v, expected the metadata of gene %v", first, gene)
+	}
+
+	// The cached metadata is returned while the gene doesn't change
+	key := collection.Name + ":1"
+	metadataCacheMutex.Lock()
+	cached := metadataCache[key]
+	cached.metadata.Name = "cached"
+	metadataCache[key] = cached
+	metadataCacheMutex.Unlock()
+	if m := getGeneMetadata(collection, 1, gene); m.Name != "cached" {
+		t.Errorf("Got name %v, expected the cached metadata", m.Name)
+	}
+
+	morphed := getGeneMetadata(collection, 1, morphedGene)
+	if morphed.Name == "cached" || reflect.DeepEqual(morphed.Attributes, first.Attributes) {
+		t.Errorf("Got %+v after the gene changed, expected the metadata of gene %v", morphed, morphedGene)
+	}
+	if m := getGeneMetadata(collection, 1, morphedGene); !reflect.DeepEqual(m, morphed) {
+		t.Errorf("Got %+v, expected the regenerated metadata to be cached", m)
+	}
+
+	// Tokens and collections are cached separately
+	other := structs.Collection{Name: "metadata-test-other", ConfigService: collection.ConfigService}
+	if m := getGeneMetadata(other, 1, gene); m.Name == "cached" || !reflect.DeepEqual(m, first) {
+		t.Errorf("Got %+v for another collection, expected the metadata of gene %v", m, gene)
+	}
+	if m := getGeneMetadata(collection, 2, gene); m.Name == "cached" {
+		t.Errorf("Got the cached metadata of token 1 for token 2")
+	}
+}
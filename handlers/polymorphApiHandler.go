@@ -3,183 +3,386 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
-	"os"
+	"rarity-backend/cache"
 	"rarity-backend/config"
 	"rarity-backend/constants"
 	"rarity-backend/db"
 	"rarity-backend/helpers"
 	"rarity-backend/structs"
+	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/gofiber/fiber"
-	"github.com/joho/godotenv"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // GetPolymorphs endpoints returns polymorphs based on different filters that can be applied.
 //
-//If no polymorph is found returns empty response
+// Returns a page with the total number of matching polymorphs, the cursor of the next page, the applied filters and the results
 //
 //	Accepted query parameters:
 //
-// 		Take - int - Sets the number of results that should be returned
+//		Take - int - Sets the number of results that should be returned. Default is config.DEFAULT_PAGE_SIZE, at most config.RESULTS_LIMIT
 //
-// 		Page - int - skips ((page - 1) * take) results
+//		Cursor - string - nextCursor of the previous page. Cursors are bound to the sort they were created with
 //
-// 		SortField - string - sets field on which the results will be sorted. Default is polymorph id
+//		Page - int - skips ((page - 1) * take) results. It's ignored if a cursor is passed and slower for deep pages
 //
-// 		SortDir  - asc/desc - sets the sort direction of the results. Default is ascending
+//		SortField - string - sets field on which the results will be sorted. Default is polymorph id. Only sortable fields of config.MORPH_FIELDS are accepted
 //
-// 		Search - string - the string will be searched in different fields.
+//		SortDir  - asc/desc - sets the sort direction of the results. Default is ascending
+//
+//		Search - string - numbers are matched against token id, rank and rarity score. Other searches use the text index over the trait and set names,
+//
+//		words match every trait word they are a prefix of and tolerate typos. Results are sorted by relevance unless a sort field is passed.
 //
 //		Searchable fields can be found in "apiConfig.go".
 //
-//		Filter - string - this query param requires special syntax in order to work.
+//		Select - string - comma separated list of public fields to return, e.g. "tokenid,rank,rarityscore,imageurl". Token id and the sort field are always returned.
 //
-//		See helpers.ParseFilterQueryString() for more information.
+//		Facets - string - comma separated list of facetable fields or "all". The response contains the number of matching polymorphs per value of each field.
 //
-//		Example filter query: "rarityscore_gte_13.2_and_lte_20;isvirgin_eq_true;"
-func GetPolymorphs(c *fiber.Ctx) {
-	godotenv.Load()
-	polymorphDBName := os.Getenv("POLYMORPH_DB")
-	rarityCollectionName := os.Getenv("RARITY_COLLECTION")
-	collection, err := db.GetMongoDbCollection(polymorphDBName, rarityCollectionName)
-	if err != nil {
-		c.Status(500).Send(err)
-		return
-	}
+//		Filter - string - filter expression, see helpers.ParseFilterQueryString() for the syntax.
+//
+//		Example filter query: "rarityscore >= 13.2 and rarityscore <= 20; isvirgin = true"
+//
+// Pages are cached by their normalized query params until any polymorph of the collection changes.
+//
+// Returns 400 if the filter, select, facets or sort params are invalid
+func GetPolymorphs(responseCache *cache.ResponseCache) func(*fiber.Ctx) {
+	return func(c *fiber.Ctx) {
+		collection, ok := getCollection(c)
+		if !ok {
+			c.Status(404).Send("Unknown collection")
+			return
+		}
 
-	queryParams := structs.QueryParams{}
-	if err := c.QueryParser(&queryParams); err != nil {
-		log.Println(err)
-	}
-	var filters, searchFilters, aggrFilters = bson.M{}, bson.M{}, bson.M{}
+		queryParams := structs.QueryParams{}
+		if err := c.QueryParser(&queryParams); err != nil {
+			log.Println(err)
+		}
 
-	if queryParams.Search != "" {
-		searchFilters = helpers.ParseSearchQueryString(queryParams.Search)
-		for k, v := range searchFilters {
-			aggrFilters[k] = v
+		namespace := cache.Namespace(collection.DBInfo)
+		key := responseCache.Key(namespace+"|morphs|"+polymorphsCacheKey(queryParams), cache.ListTag(namespace))
+		if body, ok := responseCache.Get(key); ok {
+			sendCachedJSON(c, body)
+			return
 		}
 
+		page, err := findPolymorphs(collection, queryParams)
+		if _, ok := err.(*invalidQueryError); ok {
+			c.Status(400).Send(err.Error())
+			return
+		} else if err != nil {
+			c.Status(500).Send(err)
+			return
+		}
+
+		json, _ := json.Marshal(page)
+		responseCache.Set(key, json)
+		c.Set("Content-Type", "application/json")
+		c.Send(json)
 	}
+}
 
-	if queryParams.Filter != "" {
-		filters = helpers.ParseFilterQueryString(queryParams.Filter)
-		for k, v := range filters {
-			aggrFilters[k] = v
+// polymorphsCacheKey normalizes the query params, so queries which only differ in case, whitespace or the order of the listed fields share a cache entry
+func polymorphsCacheKey(queryParams structs.QueryParams) string {
+	normalizeList := func(s string) string {
+		var fields []string
+		for _, field := range strings.Split(strings.ToLower(s), ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				fields = append(fields, field)
+			}
 		}
+		sort.Strings(fields)
+		return strings.Join(fields, ",")
 	}
 
-	var findOptions options.FindOptions
+	normalized := structs.QueryParams{
+		Take:      strings.TrimSpace(queryParams.Take),
+		Page:      strings.TrimSpace(queryParams.Page),
+		Cursor:    strings.TrimSpace(queryParams.Cursor),
+		SortField: strings.ToLower(strings.TrimSpace(queryParams.SortField)),
+		SortDir:   strings.ToLower(strings.TrimSpace(queryParams.SortDir)),
+		Search:    strings.ToLower(strings.TrimSpace(queryParams.Search)),
+		Filter:    strings.TrimSpace(queryParams.Filter),
+		Select:    normalizeList(queryParams.Select),
+		Facets:    normalizeList(queryParams.Facets),
+	}
+	if normalized.SortDir == "asc" {
+		normalized.SortDir = ""
+	}
+	key, _ := json.Marshal(normalized)
+	return string(key)
+}
 
-	removePrivateFields(&findOptions)
+// sendCachedJSON sends a cached json response
+func sendCachedJSON(c *fiber.Ctx, body []byte) {
+	c.Set("Content-Type", "application/json")
+	c.Set("X-Cache", "HIT")
+	c.Send(body)
+}
 
-	take, err := strconv.ParseInt(queryParams.Take, 10, 64)
-	if err != nil || take > config.RESULTS_LIMIT {
-		take = config.RESULTS_LIMIT
-	}
-	findOptions.SetLimit(take)
+// invalidQueryError is returned by findPolymorphs if the query params are invalid
+type invalidQueryError struct {
+	message string
+}
 
-	page, err := strconv.ParseInt(queryParams.Page, 10, 64)
+func (e *invalidQueryError) Error() string {
+	return e.message
+}
+
+// findPolymorphs returns the page of polymorphs of the collection matching the query params. It's shared by the REST and GraphQL APIs.
+//
+// Returns *invalidQueryError if the filter, select, facets, sort or paging params are invalid
+func findPolymorphs(collectionInfo structs.Collection, queryParams structs.QueryParams) (structs.PolymorphsPage, error) {
+	dbInfo := collectionInfo.DBInfo
+	collection, err := db.GetMongoDbCollection(dbInfo.PolymorphDBName, dbInfo.RarityCollectionName)
 	if err != nil {
-		page = 1
+		return structs.PolymorphsPage{}, err
+	}
+
+	// Search and filter queries both can have top level operators, so they are combined with $and
+	queries := bson.A{}
+
+	isTextSearch := false
+	if queryParams.Search != "" {
+		vocabulary := helpers.GetSearchVocabulary(getConfigService(collectionInfo))
+		var search bson.M
+		search, isTextSearch = helpers.ParseSearchQueryString(queryParams.Search, vocabulary)
+		queries = append(queries, search)
+	}
+
+	if queryParams.Filter != "" {
+		filters, err := helpers.ParseFilterQueryString(queryParams.Filter)
+		if err != nil {
+			return structs.PolymorphsPage{}, &invalidQueryError{err.Error()}
+		}
+		if len(filters) > 0 {
+			queries = append(queries, filters)
+		}
 	}
 
-	findOptions.SetSkip((page - 1) * take)
+	aggrFilters := bson.M{}
+	if len(queries) == 1 {
+		aggrFilters = queries[0].(bson.M)
+	} else if len(queries) > 1 {
+		aggrFilters["$and"] = queries
+	}
 
 	sortDir := 1
+	sortDirName := "asc"
 
-	if queryParams.SortDir == "desc" {
+	switch strings.ToLower(queryParams.SortDir) {
+	case "", "asc":
+	case "desc":
 		sortDir = -1
+		sortDirName = "desc"
+	default:
+		return structs.PolymorphsPage{}, &invalidQueryError{"Invalid sort direction, expected asc or desc"}
 	}
 
-	if queryParams.SortField != "" {
-		findOptions.SetSort(bson.D{{queryParams.SortField, sortDir}, {constants.MorphFieldNames.TokenId, 1}})
+	var findOptions options.FindOptions
+
+	// Text searches without a sort field are sorted by relevance, most relevant first
+	sortByRelevance := isTextSearch && queryParams.SortField == ""
+	sortFieldName := constants.MorphFieldNames.TokenId
+	if sortByRelevance {
+		sortFieldName = config.SEARCH_SCORE_FIELD
+		sortDir = -1
+		sortDirName = "desc"
+	} else if queryParams.SortField != "" {
+		sortField, ok := config.GetMorphField(strings.ToLower(queryParams.SortField))
+		if !ok || !sortField.Sortable {
+			return structs.PolymorphsPage{}, &invalidQueryError{fmt.Sprintf("Invalid sort field %q", queryParams.SortField)}
+		}
+		sortFieldName = sortField.Name
+	}
+
+	projection := privateFieldsProjection()
+	if queryParams.Select != "" {
+		// The cursor of the next page is created from the token id and sort field of the last result
+		required := []string{constants.MorphFieldNames.TokenId}
+		if !sortByRelevance {
+			required = append(required, sortFieldName)
+		}
+		projection, err = helpers.ParseSelectQueryString(queryParams.Select, required...)
+		if err != nil {
+			return structs.PolymorphsPage{}, &invalidQueryError{err.Error()}
+		}
+	}
+	if sortByRelevance {
+		textScore := bson.M{"$meta": "textScore"}
+		projection[config.SEARCH_SCORE_FIELD] = textScore
+		findOptions.SetSort(bson.D{{Key: config.SEARCH_SCORE_FIELD, Value: textScore}, {Key: constants.MorphFieldNames.TokenId, Value: 1}})
+	} else if sortFieldName != constants.MorphFieldNames.TokenId {
+		findOptions.SetSort(bson.D{{Key: sortFieldName, Value: sortDir}, {Key: constants.MorphFieldNames.TokenId, Value: 1}})
 	} else {
 		findOptions.SetSort(bson.M{constants.MorphFieldNames.TokenId: sortDir})
 	}
+	findOptions.SetProjection(projection)
 
-	curr, err := collection.Find(context.Background(), aggrFilters, &findOptions)
-	if err != nil {
-		c.Status(500).Send(err)
+	var facetFields []string
+	if queryParams.Facets != "" {
+		facetFields, err = helpers.ParseFacetsQueryString(queryParams.Facets)
+		if err != nil {
+			return structs.PolymorphsPage{}, &invalidQueryError{err.Error()}
+		}
 	}
 
-	defer curr.Close(context.Background())
-
-	if err != nil {
-		c.Status(500).Send(err)
-		return
+	take := config.DEFAULT_PAGE_SIZE
+	if queryParams.Take != "" {
+		take, err = strconv.ParseInt(queryParams.Take, 10, 64)
+		if err != nil || take < 1 {
+			return structs.PolymorphsPage{}, &invalidQueryError{"Invalid take"}
+		}
+		if take > config.RESULTS_LIMIT {
+			take = config.RESULTS_LIMIT
+		}
 	}
+	findOptions.SetLimit(take)
 
-	var results []bson.M
-	curr.All(context.Background(), &results)
-	if results == nil {
-		c.Send(results)
-		return
+	// The cursor is only applied to the page query, the total counts all matching polymorphs.
+	// Relevance scores can't be filtered on, so cursors of relevance sorted pages skip the previous results
+	pageFilters := aggrFilters
+	var skip int64
+	if queryParams.Cursor != "" {
+		cursor, err := helpers.DecodeCursor(queryParams.Cursor, sortFieldName, sortDir)
+		if err != nil {
+			return structs.PolymorphsPage{}, &invalidQueryError{err.Error()}
+		}
+		if sortByRelevance {
+			skip = cursor.Offset
+		} else {
+			pageFilters = bson.M{"$and": bson.A{aggrFilters, helpers.CursorFilter(cursor)}}
+		}
+	} else if queryParams.Page != "" {
+		page, err := strconv.ParseInt(queryParams.Page, 10, 64)
+		if err != nil || page < 1 {
+			return structs.PolymorphsPage{}, &invalidQueryError{"Invalid page"}
+		}
+		skip = (page - 1) * take
 	}
+	findOptions.SetSkip(skip)
 
-	json, _ := json.Marshal(results)
-	c.Send(json)
-}
-
-// GetPolymorphById endpoints accepts id of a single polymorph and information for a single polymorph.
-//
-// If no polymorph is found returns empty response
-func GetPolymorphById(c *fiber.Ctx) {
-	godotenv.Load()
-
-	polymorphDBName := os.Getenv("POLYMORPH_DB")
-	rarityCollectionName := os.Getenv("RARITY_COLLECTION")
+	total, err := collection.CountDocuments(context.Background(), aggrFilters)
+	if err != nil {
+		return structs.PolymorphsPage{}, err
+	}
 
-	collection, err := db.GetMongoDbCollection(polymorphDBName, rarityCollectionName)
+	curr, err := collection.Find(context.Background(), pageFilters, &findOptions)
 	if err != nil {
-		c.Status(500).Send(err)
-		return
+		return structs.PolymorphsPage{}, err
 	}
 
-	findOptions := options.FindOneOptions{}
-	removePrivateFieldsSingle(&findOptions)
+	defer curr.Close(context.Background())
 
-	var filter bson.M = bson.M{}
-	if c.Params("id") != "" {
-		id := c.Params("id")
-		filter = bson.M{constants.MorphFieldNames.TokenId: id}
+	results := []bson.M{}
+	if err := curr.All(context.Background(), &results); err != nil {
+		return structs.PolymorphsPage{}, err
 	}
 
-	var result bson.M
-	curr := collection.FindOne(context.Background(), filter, &findOptions)
+	page := structs.PolymorphsPage{
+		Total: total,
+		Filters: structs.AppliedFilters{
+			Filter:    queryParams.Filter,
+			Search:    queryParams.Search,
+			Select:    queryParams.Select,
+			SortField: sortFieldName,
+			SortDir:   sortDirName,
+		},
+		Results: results,
+	}
+	if len(facetFields) > 0 {
+		page.Facets, err = getFacets(collection, aggrFilters, facetFields)
+		if err != nil {
+			return structs.PolymorphsPage{}, err
+		}
+	}
 
-	curr.Decode(&result)
+	if int64(len(results)) == take && sortByRelevance {
+		page.NextCursor = helpers.EncodeCursor(helpers.PageCursor{SortField: sortFieldName, SortDir: sortDir, Offset: skip + take})
+	} else if int64(len(results)) == take {
+		page.NextCursor, err = helpers.NextCursor(results[len(results)-1], sortFieldName, sortDir)
+		if err != nil {
+			return structs.PolymorphsPage{}, err
+		}
+	}
+
+	return page, nil
+}
 
-	if result == nil {
-		c.Send(result)
-		return
+// getFacets counts the polymorphs matching the filters per value of the requested facet fields
+func getFacets(collection *mongo.Collection, filters bson.M, fields []string) (map[string][]structs.FacetCount, error) {
+	curr, err := collection.Aggregate(context.Background(), helpers.BuildFacetPipeline(filters, fields))
+	if err != nil {
+		return nil, err
 	}
+	defer curr.Close(context.Background())
 
-	json, _ := json.Marshal(result)
-	c.Send(json)
+	var result []map[string][]structs.FacetCount
+	if err := curr.All(context.Background(), &result); err != nil {
+		return nil, err
+	}
+	if len(result) == 0 {
+		return nil, nil
+	}
+	return result[0], nil
 }
 
-// removePrivateFields removes internal fields that are of no interest to the users of the API.
+// GetPolymorphById endpoints accepts id of a single polymorph and information for a single polymorph.
 //
-// Configuration of these fields can be found in helpers.apiConfig.go
-func removePrivateFields(findOptions *options.FindOptions) {
-	noProjectionFields := bson.M{}
-	for _, field := range config.MORPHS_NO_PROJECTION_FIELDS {
-		noProjectionFields[field] = 0
+// Responses are cached until the polymorph changes. If no polymorph is found returns empty response. Returns 400 if the id isn't a number
+func GetPolymorphById(responseCache *cache.ResponseCache) func(*fiber.Ctx) {
+	return func(c *fiber.Ctx) {
+		dbInfo, ok := getDBInfo(c)
+		if !ok {
+			c.Status(404).Send("Unknown collection")
+			return
+		}
+
+		id, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			c.Status(400).Send("Invalid token id")
+			return
+		}
+
+		namespace := cache.Namespace(dbInfo)
+		key := responseCache.Key(namespace+"|morph|"+strconv.Itoa(id), cache.TokenTag(namespace, id))
+		if body, ok := responseCache.Get(key); ok {
+			sendCachedJSON(c, body)
+			return
+		}
+
+		result, err := findPolymorph(dbInfo, id)
+		if err != nil {
+			c.Status(500).Send(err)
+			return
+		}
+
+		if result == nil {
+			c.Send(result)
+			return
+		}
+
+		json, _ := json.Marshal(result)
+		responseCache.Set(key, json)
+		c.Send(json)
 	}
-	findOptions.SetProjection(noProjectionFields)
 }
 
-// removePrivateFieldsSingle removes internal fields that are of no interest to the users of the API.
+// privateFieldsProjection returns the projection which removes internal fields that are of no interest to the users of the API.
 //
 // Configuration of these fields can be found in helpers.apiConfig.go
-func removePrivateFieldsSingle(findOptions *options.FindOneOptions) {
+func privateFieldsProjection() bson.M {
 	noProjectionFields := bson.M{}
 	for _, field := range config.MORPHS_NO_PROJECTION_FIELDS {
 		noProjectionFields[field] = 0
 	}
-	findOptions.SetProjection(noProjectionFields)
+	return noProjectionFields
 }
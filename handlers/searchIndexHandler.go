@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"context"
+	"rarity-backend/config"
+	"rarity-backend/db"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// EnsureSearchIndex creates the text index used by the search of the polymorphs endpoint.
+//
+// The index covers the fields of config.SEARCH_TEXT_FIELDS with their weights. Stemming and stop words are disabled, so trait names are indexed as they are.
+// Creating an index which already exists with the same options does nothing
+func EnsureSearchIndex(polymorphDBName string, rarityCollectionName string) error {
+	collection, err := db.GetMongoDbCollection(polymorphDBName, rarityCollectionName)
+	if err != nil {
+		return err
+	}
+
+	keys := bson.D{}
+	weights := bson.D{}
+	for _, field := range searchTextFieldNames() {
+		keys = append(keys, bson.E{Key: field, Value: "text"})
+		weights = append(weights, bson.E{Key: field, Value: config.SEARCH_TEXT_FIELDS[field]})
+	}
+
+	index := mongo.IndexModel{
+		Keys: keys,
+		Options: options.Index().
+			SetName(config.SEARCH_INDEX_NAME).
+			SetWeights(weights).
+			SetDefaultLanguage("none"),
+	}
+	_, err = collection.Indexes().CreateOne(context.Background(), index)
+	return err
+}
+
+// searchTextFieldNames returns the text fields in a stable order, so the index definition doesn't change between restarts
+func searchTextFieldNames() []string {
+	var fields []string
+	for field := range config.SEARCH_TEXT_FIELDS {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
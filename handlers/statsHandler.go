@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"context"
+	"rarity-backend/constants"
+	"rarity-backend/db"
+	"rarity-backend/structs"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// getCollectionStats aggregates the statistics of all polymorphs of the collection. An empty collection returns zero stats
+func getCollectionStats(dbInfo structs.DBInfo) (structs.CollectionStats, error) {
+	var stats structs.CollectionStats
+	collection, err := db.GetMongoDbCollection(dbInfo.PolymorphDBName, dbInfo.RarityCollectionName)
+	if err != nil {
+		return stats, err
+	}
+
+	countIf := func(field string) bson.M {
+		return bson.M{"$sum": bson.M{"$cond": bson.A{"$" + field, 1, 0}}}
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{
+			"_id":                nil,
+			"total":              bson.M{"$sum": 1},
+			"virgins":            countIf(constants.MorphFieldNames.IsVirgin),
+			"completedsets":      countIf(constants.MorphFieldNames.HasCompletedSet),
+			"averagerarityscore": bson.M{"$avg": "$" + constants.MorphFieldNames.RarityScore},
+			"minrarityscore":     bson.M{"$min": "$" + constants.MorphFieldNames.RarityScore},
+			"maxrarityscore":     bson.M{"$max": "$" + constants.MorphFieldNames.RarityScore},
+			"morphs":             bson.M{"$sum": "$" + constants.MorphFieldNames.Morphs},
+			"scrambles":          bson.M{"$sum": "$" + constants.MorphFieldNames.Scrambles},
+		}}},
+	}
+	curr, err := collection.Aggregate(context.Background(), pipeline)
+	if err != nil {
+		return stats, err
+	}
+	defer curr.Close(context.Background())
+
+	if curr.Next(context.Background()) {
+		err = curr.Decode(&stats)
+	}
+	return stats, err
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"encoding/json"
+	"rarity-backend/config"
+	"rarity-backend/helpers"
+	"rarity-backend/structs"
+	"strconv"
+
+	"github.com/gofiber/fiber"
+)
+
+const DEFAULT_SUGGESTIONS_LIMIT = 10
+const MAX_SUGGESTIONS_LIMIT = 50
+
+// GetTraitCatalog endpoint returns every trait type with its values and sets, and every set with its size and matching hands.
+//
+// The catalog is built from the config and rarity model the indexer uses for the collection
+func GetTraitCatalog(c *fiber.Ctx) {
+	collection, ok := getCollection(c)
+	if !ok {
+		c.Status(404).Send("Unknown collection")
+		return
+	}
+
+	catalog := helpers.BuildTraitCatalog(getConfigService(collection), getRarityModel(collection))
+	json, _ := json.Marshal(catalog)
+	c.Set("Content-Type", "application/json")
+	c.Send(json)
+}
+
+// GetTraitSuggestions endpoint autocompletes trait values and set names.
+//
+//	Accepted query parameters:
+//
+//		Q - string - prefix of the trait value or set name or of one of their words, case insensitive
+//
+//		Limit - int - maximum number of suggestions. Default is DEFAULT_SUGGESTIONS_LIMIT, at most MAX_SUGGESTIONS_LIMIT
+//
+// Returns 400 if the limit is invalid
+func GetTraitSuggestions(c *fiber.Ctx) {
+	collection, ok := getCollection(c)
+	if !ok {
+		c.Status(404).Send("Unknown collection")
+		return
+	}
+
+	limit := DEFAULT_SUGGESTIONS_LIMIT
+	if c.Query("limit") != "" {
+		parsed, err := strconv.Atoi(c.Query("limit"))
+		if err != nil || parsed < 1 {
+			c.Status(400).Send("Invalid limit")
+			return
+		}
+		limit = parsed
+		if limit > MAX_SUGGESTIONS_LIMIT {
+			limit = MAX_SUGGESTIONS_LIMIT
+		}
+	}
+
+	catalog := helpers.BuildTraitCatalog(getConfigService(collection), getRarityModel(collection))
+	json, _ := json.Marshal(helpers.SuggestTraits(catalog, c.Query("q"), limit))
+	c.Set("Content-Type", "application/json")
+	c.Send(json)
+}
+
+// getRarityModel returns the rarity model of the collection. Collections without a rarity model use the default one
+func getRarityModel(collection structs.Collection) structs.RarityModel {
+	if model, ok := config.RarityModels[collection.RarityModel]; ok {
+		return model
+	}
+	return config.RarityModels[config.DEFAULT_RARITY_MODEL]
+}
@@ -0,0 +1,358 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"rarity-backend/config"
+	"rarity-backend/constants"
+	"rarity-backend/db"
+	"rarity-backend/events"
+	"rarity-backend/models"
+	"rarity-backend/structs"
+	"rarity-backend/webhooks"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gofiber/fiber"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// WEBHOOK_SECRET_HEADER authenticates the requests which read or delete a webhook
+const WEBHOOK_SECRET_HEADER = "X-Webhook-Secret"
+
+// WEBHOOK_API_KEY_HEADER authenticates the requests which register a webhook
+const WEBHOOK_API_KEY_HEADER = "X-Api-Key"
+
+const WEBHOOK_MIN_SECRET_LENGTH = 16
+const WEBHOOK_DELIVERIES_LIMIT = 100
+
+// WebhookStore stores the webhooks and their deliveries in the database. It implements webhooks.Store
+type WebhookStore struct {
+	PolymorphDBName             string
+	WebhooksCollection          string
+	WebhookDeliveriesCollection string
+}
+
+// Matching returns the morph webhooks of the morphed token, the set webhooks of the completed set and the rank webhooks of the owner which watch a smaller rank change
+func (s WebhookStore) Matching(event structs.FeedEvent) ([]models.Webhook, error) {
+	queries := bson.A{}
+	switch event.Type {
+	case events.MORPH, events.SCRAMBLE:
+		queries = append(queries, bson.M{
+			constants.WebhookFieldNames.Type:    webhooks.TYPE_MORPH,
+			constants.WebhookFieldNames.TokenId: event.TokenId,
+		})
+		if event.CompletedSet != "" {
+			queries = append(queries, bson.M{
+				constants.WebhookFieldNames.Type:    webhooks.TYPE_SET,
+				constants.WebhookFieldNames.SetName: event.CompletedSet,
+			})
+		}
+	case events.RANK_CHANGE:
+		moved := event.NewRank - event.OldRank
+		if moved < 0 {
+			moved = -moved
+		}
+		if event.Owner != "" {
+			queries = append(queries, bson.M{
+				constants.WebhookFieldNames.Type:          webhooks.TYPE_RANK,
+				constants.WebhookFieldNames.Wallet:        strings.ToLower(event.Owner),
+				constants.WebhookFieldNames.MinRankChange: bson.M{"$lte": moved},
+			})
+		}
+	}
+	if len(queries) == 0 {
+		return nil, nil
+	}
+
+	collection, err := db.GetMongoDbCollection(s.PolymorphDBName, s.WebhooksCollection)
+	if err != nil {
+		return nil, err
+	}
+	curr, err := collection.Find(context.Background(), bson.M{"$or": queries})
+	if err != nil {
+		return nil, err
+	}
+	var result []models.Webhook
+	err = curr.All(context.Background(), &result)
+	return result, err
+}
+
+// MinRankChange returns the smallest rank change watched by a rank webhook
+func (s WebhookStore) MinRankChange() (int, bool, error) {
+	collection, err := db.GetMongoDbCollection(s.PolymorphDBName, s.WebhooksCollection)
+	if err != nil {
+		return 0, false, err
+	}
+
+	var webhook models.Webhook
+	opts := options.FindOne().SetSort(bson.M{constants.WebhookFieldNames.MinRankChange: 1})
+	err = collection.FindOne(context.Background(), bson.M{constants.WebhookFieldNames.Type: webhooks.TYPE_RANK}, opts).Decode(&webhook)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, err
+	}
+	return webhook.MinRankChange, true, nil
+}
+
+// RecordDelivery inserts the delivery in the delivery log. Deliveries aren't recorded if the collection isn't configured
+func (s WebhookStore) RecordDelivery(delivery models.WebhookDelivery) error {
+	if s.WebhookDeliveriesCollection == "" {
+		return nil
+	}
+	collection, err := db.GetMongoDbCollection(s.PolymorphDBName, s.WebhookDeliveriesCollection)
+	if err != nil {
+		return err
+	}
+	_, err = collection.InsertOne(context.Background(), delivery)
+	return err
+}
+
+// findWebhook returns the webhook with the id if the secret matches
+func (s WebhookStore) findWebhook(id string, secret string) (models.Webhook, bool, error) {
+	var webhook models.Webhook
+	collection, err := db.GetMongoDbCollection(s.PolymorphDBName, s.WebhooksCollection)
+	if err != nil {
+		return webhook, false, err
+	}
+	err = collection.FindOne(context.Background(), bson.M{constants.WebhookFieldNames.Id: id}).Decode(&webhook)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return webhook, false, nil
+	} else if err != nil {
+		return webhook, false, err
+	}
+	return webhook, hmac.Equal([]byte(webhook.Secret), []byte(secret)), nil
+}
+
+// getWebhookStore returns the webhook store of the requested collection. Returns false if webhooks aren't enabled for it
+func getWebhookStore(c *fiber.Ctx) (WebhookStore, bool) {
+	dbInfo, ok := getDBInfo(c)
+	if !ok || dbInfo.WebhooksCollectionName == "" {
+		return WebhookStore{}, false
+	}
+	return WebhookStore{
+		PolymorphDBName:             dbInfo.PolymorphDBName,
+		WebhooksCollection:          dbInfo.WebhooksCollectionName,
+		WebhookDeliveriesCollection: dbInfo.WebhookDeliveriesCollectionName,
+	}, true
+}
+
+// countTargetWebhooks returns the number of webhooks watching the same token, wallet or set as the webhook
+func (s WebhookStore) countTargetWebhooks(webhook models.Webhook) (int64, error) {
+	collection, err := db.GetMongoDbCollection(s.PolymorphDBName, s.WebhooksCollection)
+	if err != nil {
+		return 0, err
+	}
+	query := bson.M{constants.WebhookFieldNames.Type: webhook.Type}
+	switch webhook.Type {
+	case webhooks.TYPE_MORPH:
+		query[constants.WebhookFieldNames.TokenId] = webhook.TokenId
+	case webhooks.TYPE_RANK:
+		query[constants.WebhookFieldNames.Wallet] = webhook.Wallet
+	case webhooks.TYPE_SET:
+		query[constants.WebhookFieldNames.SetName] = webhook.SetName
+	}
+	return collection.CountDocuments(context.Background(), query)
+}
+
+// CreateWebhook endpoint registers a webhook. The body is a json webhook, see models.Webhook for the types and the fields they need.
+//
+// Registration needs one of the API keys in the X-Api-Key header, it's disabled if no keys are configured. Only config.WEBHOOK_MAX_PER_TARGET webhooks can watch the same token, wallet or set.
+//
+// The secret is generated if it isn't passed. It signs the webhook requests and is needed to read the deliveries or delete the webhook, so it's only returned by this endpoint.
+//
+// Returns 201 with the webhook, 400 if the webhook is invalid, 401 if the API key is wrong or 409 if the token, wallet or set has too many webhooks
+func CreateWebhook(apiKeys []string) func(*fiber.Ctx) {
+	return func(c *fiber.Ctx) {
+		store, ok := getWebhookStore(c)
+		if !ok || len(apiKeys) == 0 {
+			c.Status(404).Send("Webhooks aren't enabled")
+			return
+		}
+		if !validApiKey(apiKeys, c.Get(WEBHOOK_API_KEY_HEADER)) {
+			c.Status(401).Send("Invalid API key")
+			return
+		}
+		collectionInfo, _ := getCollection(c)
+
+		var webhook models.Webhook
+		if err := json.Unmarshal([]byte(c.Body()), &webhook); err != nil {
+			c.Status(400).Send("Invalid webhook")
+			return
+		}
+		if err := validateWebhook(&webhook, getRarityModel(collectionInfo)); err != nil {
+			c.Status(400).Send(err.Error())
+			return
+		}
+		count, err := store.countTargetWebhooks(webhook)
+		if err != nil {
+			c.Status(500).Send(err)
+			return
+		}
+		if count >= config.WEBHOOK_MAX_PER_TARGET {
+			c.Status(409).Send(fmt.Sprintf("Only %v webhooks can watch the same %v", config.WEBHOOK_MAX_PER_TARGET, webhookTarget(webhook.Type)))
+			return
+		}
+		webhook.Id = webhooks.NewToken()
+		webhook.CreatedAt = time.Now().UTC()
+		if webhook.Secret == "" {
+			webhook.Secret = webhooks.NewToken()
+		}
+
+		collection, err := db.GetMongoDbCollection(store.PolymorphDBName, store.WebhooksCollection)
+		if err != nil {
+			c.Status(500).Send(err)
+			return
+		}
+		if _, err := collection.InsertOne(context.Background(), webhook); err != nil {
+			c.Status(500).Send(err)
+			return
+		}
+
+		json, _ := json.Marshal(webhook)
+		c.Set("Content-Type", "application/json")
+		c.Status(201).Send(json)
+	}
+}
+
+// validApiKey compares the key with every API key in constant time
+func validApiKey(apiKeys []string, key string) bool {
+	valid := false
+	for _, apiKey := range apiKeys {
+		if hmac.Equal([]byte(apiKey), []byte(key)) {
+			valid = true
+		}
+	}
+	return valid
+}
+
+func webhookTarget(webhookType string) string {
+	switch webhookType {
+	case webhooks.TYPE_RANK:
+		return "wallet"
+	case webhooks.TYPE_SET:
+		return "set"
+	}
+	return "token"
+}
+
+// validateWebhook checks the fields needed by the webhook type and normalizes the wallet
+func validateWebhook(webhook *models.Webhook, rarityModel structs.RarityModel) error {
+	parsed, err := url.Parse(webhook.Url)
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+		return errors.New("Invalid url, expected an http or https url")
+	}
+	// Hosts are checked again when the webhook is called, after they are resolved
+	host := strings.ToLower(strings.TrimSuffix(parsed.Hostname(), "."))
+	if ip := net.ParseIP(host); (ip != nil && !webhooks.IsPublicIP(ip)) || host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return errors.New("Invalid url, expected a public address")
+	}
+	if webhook.Secret != "" && len(webhook.Secret) < WEBHOOK_MIN_SECRET_LENGTH {
+		return errors.New("Secret must have at least 16 characters")
+	}
+
+	switch webhook.Type {
+	case webhooks.TYPE_MORPH:
+		if webhook.TokenId < 0 {
+			return errors.New("Invalid token id")
+		}
+	case webhooks.TYPE_RANK:
+		if !common.IsHexAddress(webhook.Wallet) {
+			return errors.New("Invalid wallet")
+		}
+		if webhook.MinRankChange < 1 {
+			return errors.New("Minimum rank change must be at least 1")
+		}
+		webhook.Wallet = strings.ToLower(common.HexToAddress(webhook.Wallet).Hex())
+	case webhooks.TYPE_SET:
+		if _, ok := rarityModel.CombosMap[webhook.SetName]; !ok {
+			return errors.New("Unknown set")
+		}
+	default:
+		return errors.New("Invalid type, expected morph, rank or set")
+	}
+	return nil
+}
+
+// GetWebhookDeliveries endpoint returns the latest deliveries of the webhook, newest first.
+//
+// Returns 404 if the webhook doesn't exist or the X-Webhook-Secret header doesn't match its secret
+func GetWebhookDeliveries(c *fiber.Ctx) {
+	store, ok := getWebhookStore(c)
+	if !ok {
+		c.Status(404).Send("Webhooks aren't enabled")
+		return
+	}
+	_, ok, err := store.findWebhook(c.Params("id"), c.Get(WEBHOOK_SECRET_HEADER))
+	if err != nil {
+		c.Status(500).Send(err)
+		return
+	}
+	if !ok {
+		c.Status(404).Send("Unknown webhook")
+		return
+	}
+
+	deliveries := []models.WebhookDelivery{}
+	if store.WebhookDeliveriesCollection != "" {
+		collection, err := db.GetMongoDbCollection(store.PolymorphDBName, store.WebhookDeliveriesCollection)
+		if err != nil {
+			c.Status(500).Send(err)
+			return
+		}
+		opts := options.Find().SetSort(bson.M{constants.WebhookDeliveryFieldNames.CreatedAt: -1}).SetLimit(WEBHOOK_DELIVERIES_LIMIT)
+		curr, err := collection.Find(context.Background(), bson.M{constants.WebhookDeliveryFieldNames.WebhookId: c.Params("id")}, opts)
+		if err != nil {
+			c.Status(500).Send(err)
+			return
+		}
+		if err := curr.All(context.Background(), &deliveries); err != nil {
+			c.Status(500).Send(err)
+			return
+		}
+	}
+
+	json, _ := json.Marshal(deliveries)
+	c.Set("Content-Type", "application/json")
+	c.Send(json)
+}
+
+// DeleteWebhook endpoint deletes the webhook.
+//
+// Returns 404 if the webhook doesn't exist or the X-Webhook-Secret header doesn't match its secret
+func DeleteWebhook(c *fiber.Ctx) {
+	store, ok := getWebhookStore(c)
+	if !ok {
+		c.Status(404).Send("Webhooks aren't enabled")
+		return
+	}
+	_, ok, err := store.findWebhook(c.Params("id"), c.Get(WEBHOOK_SECRET_HEADER))
+	if err != nil {
+		c.Status(500).Send(err)
+		return
+	}
+	if !ok {
+		c.Status(404).Send("Unknown webhook")
+		return
+	}
+
+	collection, err := db.GetMongoDbCollection(store.PolymorphDBName, store.WebhooksCollection)
+	if err != nil {
+		c.Status(500).Send(err)
+		return
+	}
+	if _, err := collection.DeleteOne(context.Background(), bson.M{constants.WebhookFieldNames.Id: c.Params("id")}); err != nil {
+		c.Status(500).Send(err)
+		return
+	}
+	c.Status(204)
+}
@@ -0,0 +1,151 @@
+package helpers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"rarity-backend/constants"
+	"rarity-backend/structs"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ParseActivityFilter builds the mongodb filter of the history snapshots matching the filter params of the activity endpoint.
+//
+// Type is Morph or Scramble. Attribute and character are matched exactly, ignoring case.
+// From and to are RFC3339 times or dates, a date in to includes the whole day. Prices are inclusive
+func ParseActivityFilter(filter structs.ActivityQueryParams) (bson.M, error) {
+	query := bson.M{}
+
+	if filter.Type != "" {
+		switch strings.ToLower(strings.TrimSpace(filter.Type)) {
+		case strings.ToLower(constants.HISTORY_TYPE_MORPH):
+			query[constants.HistoryFieldNames.Type] = constants.HISTORY_TYPE_MORPH
+		case strings.ToLower(constants.HISTORY_TYPE_SCRAMBLE):
+			query[constants.HistoryFieldNames.Type] = constants.HISTORY_TYPE_SCRAMBLE
+		default:
+			return nil, fmt.Errorf("Invalid type %q, expected %v or %v", filter.Type, constants.HISTORY_TYPE_MORPH, constants.HISTORY_TYPE_SCRAMBLE)
+		}
+	}
+	if filter.Attribute != "" {
+		query[constants.HistoryFieldNames.AttributeChanged] = equalsIgnoreCase(filter.Attribute)
+	}
+	if filter.Character != "" {
+		query[constants.HistoryFieldNames.Character] = equalsIgnoreCase(filter.Character)
+	}
+
+	// Snapshots store their time as a RFC3339 UTC string, which sorts chronologically
+	dateRange := bson.M{}
+	if filter.From != "" {
+		from, _, err := parseActivityTime(filter.From)
+		if err != nil {
+			return nil, err
+		}
+		dateRange["$gte"] = from.Format(time.RFC3339)
+	}
+	if filter.To != "" {
+		to, isDate, err := parseActivityTime(filter.To)
+		if err != nil {
+			return nil, err
+		}
+		if isDate {
+			dateRange["$lt"] = to.AddDate(0, 0, 1).Format(time.RFC3339)
+		} else {
+			dateRange["$lte"] = to.Format(time.RFC3339)
+		}
+	}
+	if len(dateRange) > 0 {
+		query[constants.HistoryFieldNames.DateTime] = dateRange
+	}
+
+	priceRange := bson.M{}
+	if filter.MinPrice != "" {
+		price, err := parseActivityPrice(filter.MinPrice)
+		if err != nil {
+			return nil, err
+		}
+		priceRange["$gte"] = price
+	}
+	if filter.MaxPrice != "" {
+		price, err := parseActivityPrice(filter.MaxPrice)
+		if err != nil {
+			return nil, err
+		}
+		priceRange["$lte"] = price
+	}
+	if len(priceRange) > 0 {
+		query[constants.HistoryFieldNames.Price] = priceRange
+	}
+
+	return query, nil
+}
+
+// parseActivityTime parses a RFC3339 time or a date. Returns true if it's a date
+func parseActivityTime(s string) (time.Time, bool, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.UTC(), false, nil
+	}
+	if t, err := time.Parse(constants.DAY_LAYOUT, s); err == nil {
+		return t, true, nil
+	}
+	return time.Time{}, false, fmt.Errorf("Invalid date %q, expected YYYY-MM-DD or RFC3339", s)
+}
+
+func parseActivityPrice(s string) (float64, error) {
+	price, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || math.IsNaN(price) || math.IsInf(price, 0) || price < 0 {
+		return 0, fmt.Errorf("Invalid price %q", s)
+	}
+	return price, nil
+}
+
+func equalsIgnoreCase(s string) primitive.Regex {
+	return primitive.Regex{Pattern: "^" + regexp.QuoteMeta(strings.TrimSpace(s)) + "$", Options: "i"}
+}
+
+// ActivityCursor points after the last snapshot of an activity page. Snapshots are sorted by time and then by id, both descending
+type ActivityCursor struct {
+	DateTime string `json:"t"`
+	Id       string `json:"id"`
+}
+
+// NextActivityCursor creates the cursor pointing after the passed snapshot
+func NextActivityCursor(last bson.M) (string, error) {
+	id, ok := last[constants.HistoryFieldNames.ObjId].(primitive.ObjectID)
+	if !ok {
+		return "", errors.New("Snapshot has no id")
+	}
+	dateTime, _ := last[constants.HistoryFieldNames.DateTime].(string)
+
+	data, _ := json.Marshal(ActivityCursor{DateTime: dateTime, Id: id.Hex()})
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// ActivityCursorFilter decodes a cursor created by NextActivityCursor and returns the filter matching the snapshots after it
+func ActivityCursorFilter(s string) (bson.M, error) {
+	var cursor ActivityCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("Invalid cursor")
+	}
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, errors.New("Invalid cursor")
+	}
+	id, err := primitive.ObjectIDFromHex(cursor.Id)
+	if err != nil {
+		return nil, errors.New("Invalid cursor")
+	}
+
+	return bson.M{"$or": bson.A{
+		bson.M{constants.HistoryFieldNames.DateTime: bson.M{"$lt": cursor.DateTime}},
+		bson.M{constants.HistoryFieldNames.DateTime: cursor.DateTime, constants.HistoryFieldNames.ObjId: bson.M{"$lt": id}},
+	}}, nil
+}
@@ -0,0 +1,127 @@
+package helpers
+
+import (
+	"rarity-backend/constants"
+	"rarity-backend/structs"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestParseActivityFilterDates(t *testing.T) {
+	tests := []struct {
+		name string
+		from string
+		to   string
+		want bson.M
+	}{
+		{"date", "2021-07-01", "", bson.M{"$gte": "2021-07-01T00:00:00Z"}},
+		{"to date includes the whole day", "", "2021-07-01", bson.M{"$lt": "2021-07-02T00:00:00Z"}},
+		{"to date at the end of the month", "", "2021-06-30", bson.M{"$lt": "2021-07-01T00:00:00Z"}},
+		{"time", "2021-07-01T10:30:00Z", "", bson.M{"$gte": "2021-07-01T10:30:00Z"}},
+		{"to time is inclusive", "", "2021-07-01T10:30:00Z", bson.M{"$lte": "2021-07-01T10:30:00Z"}},
+		{"time zones are converted to UTC", "2021-07-01T02:00:00+03:00", "", bson.M{"$gte": "2021-06-30T23:00:00Z"}},
+		{"range", " 2021-07-01 ", "2021-07-03", bson.M{"$gte": "2021-07-01T00:00:00Z", "$lt": "2021-07-04T00:00:00Z"}},
+	}
+
+	for _, test := range tests {
+		query, err := ParseActivityFilter(structs.ActivityQueryParams{From: test.from, To: test.to})
+		if err != nil {
+			t.Errorf("%v: failed with %v", test.name, err)
+			continue
+		}
+		if got := query[constants.HistoryFieldNames.DateTime]; !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: got %v, expected %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestParseActivityFilterPrices(t *testing.T) {
+	tests := []struct {
+		min  string
+		max  string
+		want bson.M
+	}{
+		{"0", "", bson.M{"$gte": 0.0}},
+		{"", "0.5", bson.M{"$lte": 0.5}},
+		{" 0.01 ", "1e1", bson.M{"$gte": 0.01, "$lte": 10.0}},
+	}
+
+	for _, test := range tests {
+		query, err := ParseActivityFilter(structs.ActivityQueryParams{MinPrice: test.min, MaxPrice: test.max})
+		if err != nil {
+			t.Errorf("%q-%q: failed with %v", test.min, test.max, err)
+			continue
+		}
+		if got := query[constants.HistoryFieldNames.Price]; !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q-%q: got %v, expected %v", test.min, test.max, got, test.want)
+		}
+	}
+}
+
+func TestParseActivityFilterErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter structs.ActivityQueryParams
+		err    string
+	}{
+		{"type", structs.ActivityQueryParams{Type: "mint"}, "Invalid type"},
+		{"date format", structs.ActivityQueryParams{From: "01/07/2021"}, "Invalid date"},
+		{"invalid day", structs.ActivityQueryParams{To: "2021-02-30"}, "Invalid date"},
+		{"time without zone", structs.ActivityQueryParams{From: "2021-07-01T10:30:00"}, "Invalid date"},
+		{"price", structs.ActivityQueryParams{MinPrice: "cheap"}, "Invalid price"},
+		{"negative price", structs.ActivityQueryParams{MaxPrice: "-1"}, "Invalid price"},
+		{"NaN price", structs.ActivityQueryParams{MinPrice: "NaN"}, "Invalid price"},
+		{"infinite price", structs.ActivityQueryParams{MaxPrice: "Inf"}, "Invalid price"},
+	}
+
+	for _, test := range tests {
+		_, err := ParseActivityFilter(test.filter)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%v: got %v, expected %q", test.name, err, test.err)
+		}
+	}
+}
+
+func TestParseActivityFilterFields(t *testing.T) {
+	query, err := ParseActivityFilter(structs.ActivityQueryParams{Type: " scramble", Attribute: "Head.wear", Character: "Zombie"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := bson.M{
+		constants.HistoryFieldNames.Type:             constants.HISTORY_TYPE_SCRAMBLE,
+		constants.HistoryFieldNames.AttributeChanged: primitive.Regex{Pattern: `^Head\.wear$`, Options: "i"},
+		constants.HistoryFieldNames.Character:        primitive.Regex{Pattern: "^Zombie$", Options: "i"},
+	}
+	if !reflect.DeepEqual(query, want) {
+		t.Errorf("Got %v, expected %v", query, want)
+	}
+}
+
+func TestActivityCursor(t *testing.T) {
+	id := primitive.NewObjectID()
+	cursor, err := NextActivityCursor(bson.M{constants.HistoryFieldNames.ObjId: id, constants.HistoryFieldNames.DateTime: "2021-07-01T10:30:00Z"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter, err := ActivityCursorFilter(cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := bson.M{"$or": bson.A{
+		bson.M{constants.HistoryFieldNames.DateTime: bson.M{"$lt": "2021-07-01T10:30:00Z"}},
+		bson.M{constants.HistoryFieldNames.DateTime: "2021-07-01T10:30:00Z", constants.HistoryFieldNames.ObjId: bson.M{"$lt": id}},
+	}}
+	if !reflect.DeepEqual(filter, want) {
+		t.Errorf("Got %v, expected %v", filter, want)
+	}
+
+	for _, invalid := range []string{"", "not base64!", "bm90IGpzb24", "eyJ0IjoiIiwiaWQiOiJ4In0"} {
+		if _, err := ActivityCursorFilter(invalid); err == nil {
+			t.Errorf("Expected cursor %q to be invalid", invalid)
+		}
+	}
+}
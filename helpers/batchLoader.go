@@ -0,0 +1,83 @@
+package helpers
+
+import "sync"
+
+// BatchLoadFn loads the values of the keys in one batch. Keys without a value resolve to nil, keys without an error resolve without one
+type BatchLoadFn func(keys []int) (map[int]interface{}, map[int]error)
+
+// BatchLoader collects the keys requested by GraphQL resolvers and loads them together the first time one of them is resolved.
+//
+// Loaded values are cached, so a key requested twice is loaded once. A loader lives for a single request
+type BatchLoader struct {
+	mutex   sync.Mutex
+	load    BatchLoadFn
+	pending []int
+	queued  map[int]bool
+	values  map[int]interface{}
+	errors  map[int]error
+}
+
+func NewBatchLoader(load BatchLoadFn) *BatchLoader {
+	return &BatchLoader{
+		load:   load,
+		queued: make(map[int]bool),
+		values: make(map[int]interface{}),
+		errors: make(map[int]error),
+	}
+}
+
+// Load queues the key and returns a thunk which resolves its value. GraphQL resolvers return the thunk, so the executor resolves it after every sibling queued its key
+func (l *BatchLoader) Load(key int) func() (interface{}, error) {
+	l.mutex.Lock()
+	if !l.queued[key] {
+		l.queued[key] = true
+		l.pending = append(l.pending, key)
+	}
+	l.mutex.Unlock()
+
+	return func() (interface{}, error) {
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+		if len(l.pending) > 0 {
+			keys := l.pending
+			l.pending = nil
+			values, errs := l.load(keys)
+			for _, k := range keys {
+				l.values[k] = values[k]
+				l.errors[k] = errs[k]
+			}
+		}
+		return l.values[key], l.errors[key]
+	}
+}
+
+// BatchErrors returns the error for every key, for batches which fail as a whole
+func BatchErrors(keys []int, err error) map[int]error {
+	errs := make(map[int]error)
+	for _, key := range keys {
+		errs[key] = err
+	}
+	return errs
+}
+
+// BatchLoaders holds the loaders of a request by name
+type BatchLoaders struct {
+	mutex   sync.Mutex
+	loaders map[string]*BatchLoader
+}
+
+func NewBatchLoaders() *BatchLoaders {
+	return &BatchLoaders{loaders: make(map[string]*BatchLoader)}
+}
+
+// Get returns the loader of the name, creating it with the load function the first time
+func (l *BatchLoaders) Get(name string, load BatchLoadFn) *BatchLoader {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	loader, ok := l.loaders[name]
+	if !ok {
+		loader = NewBatchLoader(load)
+		l.loaders[name] = loader
+	}
+	return loader
+}
@@ -0,0 +1,65 @@
+package helpers
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestBatchLoader(t *testing.T) {
+	var batches [][]int
+	loader := NewBatchLoader(func(keys []int) (map[int]interface{}, map[int]error) {
+		batches = append(batches, keys)
+		values := make(map[int]interface{})
+		errs := make(map[int]error)
+		for _, key := range keys {
+			if key < 0 {
+				errs[key] = errors.New("negative key")
+				continue
+			}
+			values[key] = key * 10
+		}
+		return values, errs
+	})
+
+	first := []func() (interface{}, error){loader.Load(1), loader.Load(2), loader.Load(1), loader.Load(-1)}
+	tests := []struct {
+		value interface{}
+		err   bool
+	}{{10, false}, {20, false}, {10, false}, {nil, true}}
+	for i, thunk := range first {
+		value, err := thunk()
+		if value != tests[i].value || (err != nil) != tests[i].err {
+			t.Errorf("Key %v: got %v %v, expected %v", i, value, err, tests[i].value)
+		}
+	}
+
+	second := loader.Load(3)
+	if value, _ := loader.Load(2)(); value != 20 {
+		t.Errorf("Got %v, expected the cached 20", value)
+	}
+	if value, _ := second(); value != 30 {
+		t.Errorf("Got %v, expected 30", value)
+	}
+
+	expected := [][]int{{1, 2, -1}, {3}}
+	if !reflect.DeepEqual(batches, expected) {
+		t.Errorf("Got batches %v, expected %v", batches, expected)
+	}
+}
+
+func TestBatchLoaders(t *testing.T) {
+	loaders := NewBatchLoaders()
+	load := func(keys []int) (map[int]interface{}, map[int]error) {
+		return nil, BatchErrors(keys, errors.New("failed"))
+	}
+	if loaders.Get("owner", load) != loaders.Get("owner", load) {
+		t.Errorf("Expected the same loader for the same name")
+	}
+	if loaders.Get("owner", load) == loaders.Get("history", load) {
+		t.Errorf("Expected different loaders for different names")
+	}
+	if _, err := loaders.Get("history", load).Load(1)(); err == nil {
+		t.Errorf("Expected the batch error")
+	}
+}
@@ -0,0 +1,164 @@
+package helpers
+
+import (
+	"fmt"
+	"rarity-backend/constants"
+	"rarity-backend/structs"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Checks of the consistency verifier
+const CHECK_GENE = "gene"
+const CHECK_HISTORY_CHAIN = "history chain"
+const CHECK_LATEST_SNAPSHOT = "latest snapshot"
+const CHECK_MORPHS = "morphs"
+const CHECK_SCRAMBLES = "scrambles"
+
+// DocumentTokenId returns the token id of a rarity entity or history snapshot
+func DocumentTokenId(doc bson.M) (int, bool) {
+	return toInt(doc[constants.MorphFieldNames.TokenId])
+}
+
+// GroupSnapshotsByToken groups the history snapshots by token id, each group in chronological order.
+//
+// Snapshots saved for the same block have the same time and are saved concurrently, so they are ordered by following the genes from one snapshot to the next
+func GroupSnapshotsByToken(snapshots []bson.M) map[int][]bson.M {
+	groups := make(map[int][]bson.M)
+	for _, snapshot := range snapshots {
+		if tokenId, ok := DocumentTokenId(snapshot); ok {
+			groups[tokenId] = append(groups[tokenId], snapshot)
+		}
+	}
+	for tokenId, group := range groups {
+		groups[tokenId] = orderSnapshots(group)
+	}
+	return groups
+}
+
+func orderSnapshots(snapshots []bson.M) []bson.M {
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		a, b := snapshotString(snapshots[i], constants.HistoryFieldNames.DateTime), snapshotString(snapshots[j], constants.HistoryFieldNames.DateTime)
+		if a != b {
+			return a < b
+		}
+		return snapshotId(snapshots[i]) < snapshotId(snapshots[j])
+	})
+
+	ordered := make([]bson.M, 0, len(snapshots))
+	for start := 0; start < len(snapshots); {
+		end := start
+		dateTime := snapshotString(snapshots[start], constants.HistoryFieldNames.DateTime)
+		for end < len(snapshots) && snapshotString(snapshots[end], constants.HistoryFieldNames.DateTime) == dateTime {
+			end++
+		}
+
+		remaining := append([]bson.M{}, snapshots[start:end]...)
+		for len(remaining) > 0 {
+			next := nextSnapshot(ordered, remaining)
+			ordered = append(ordered, remaining[next])
+			remaining = append(remaining[:next], remaining[next+1:]...)
+		}
+		start = end
+	}
+	return ordered
+}
+
+// nextSnapshot returns the index of the remaining snapshot which continues the ordered ones.
+// The first snapshot of a polymorph is the one which doesn't continue any of the remaining ones. If no snapshot fits, the earliest saved one is next
+func nextSnapshot(ordered []bson.M, remaining []bson.M) int {
+	if len(ordered) > 0 {
+		previousGene := snapshotString(ordered[len(ordered)-1], constants.HistoryFieldNames.NewGene)
+		for i, snapshot := range remaining {
+			if snapshotString(snapshot, constants.HistoryFieldNames.OldGene) == previousGene {
+				return i
+			}
+		}
+		return 0
+	}
+
+	for i, snapshot := range remaining {
+		oldGene := snapshotString(snapshot, constants.HistoryFieldNames.OldGene)
+		continues := false
+		for j, other := range remaining {
+			continues = continues || (i != j && snapshotString(other, constants.HistoryFieldNames.NewGene) == oldGene)
+		}
+		if !continues {
+			return i
+		}
+	}
+	return 0
+}
+
+// CheckHistory checks that the old gene of every snapshot of the polymorph is the new gene of the previous one, that the latest snapshot has the current gene
+// and that the morph and scramble counters of the entity match the number of snapshots.
+//
+// The snapshots must be in chronological order
+func CheckHistory(entity bson.M, snapshots []bson.M) []structs.VerifyMismatch {
+	tokenId, _ := DocumentTokenId(entity)
+	var mismatches []structs.VerifyMismatch
+
+	for i, snapshot := range snapshots {
+		if i == 0 {
+			continue
+		}
+		previousGene := snapshotString(snapshots[i-1], constants.HistoryFieldNames.NewGene)
+		oldGene := snapshotString(snapshot, constants.HistoryFieldNames.OldGene)
+		if oldGene != previousGene {
+			mismatches = append(mismatches, structs.VerifyMismatch{
+				TokenId:  tokenId,
+				Check:    CHECK_HISTORY_CHAIN,
+				Stored:   fmt.Sprintf("snapshot %v old gene %v", i+1, oldGene),
+				Expected: previousGene,
+			})
+		}
+	}
+
+	currentGene := snapshotString(entity, constants.MorphFieldNames.CurrentGene)
+	if len(snapshots) > 0 {
+		latestGene := snapshotString(snapshots[len(snapshots)-1], constants.HistoryFieldNames.NewGene)
+		if latestGene != currentGene {
+			mismatches = append(mismatches, structs.VerifyMismatch{TokenId: tokenId, Check: CHECK_LATEST_SNAPSHOT, Stored: latestGene, Expected: currentGene})
+		}
+	}
+
+	morphs, scrambles := CountSnapshots(snapshots)
+	storedMorphs, _ := toInt(entity[constants.MorphFieldNames.Morphs])
+	if storedMorphs != morphs {
+		mismatches = append(mismatches, structs.VerifyMismatch{TokenId: tokenId, Check: CHECK_MORPHS, Stored: fmt.Sprint(storedMorphs), Expected: fmt.Sprint(morphs)})
+	}
+	storedScrambles, _ := toInt(entity[constants.MorphFieldNames.Scrambles])
+	if storedScrambles != scrambles {
+		mismatches = append(mismatches, structs.VerifyMismatch{TokenId: tokenId, Check: CHECK_SCRAMBLES, Stored: fmt.Sprint(storedScrambles), Expected: fmt.Sprint(scrambles)})
+	}
+	return mismatches
+}
+
+// CountSnapshots returns the number of snapshots which incremented the morph and scramble counters.
+//
+// The counters follow the gene differences like SaveMorph does: 1 or 2 differences are a morph and more are a scramble.
+// Snapshots without differences are labeled as morphs but don't increment any counter
+func CountSnapshots(snapshots []bson.M) (int, int) {
+	morphs, scrambles := 0, 0
+	for _, snapshot := range snapshots {
+		_, differences := DetectGeneDifferences(snapshotString(snapshot, constants.HistoryFieldNames.OldGene), snapshotString(snapshot, constants.HistoryFieldNames.NewGene))
+		if differences > 0 && differences <= 2 {
+			morphs++
+		} else if differences > 2 {
+			scrambles++
+		}
+	}
+	return morphs, scrambles
+}
+
+func snapshotString(doc bson.M, field string) string {
+	s, _ := doc[field].(string)
+	return s
+}
+
+func snapshotId(doc bson.M) string {
+	id, _ := doc[constants.HistoryFieldNames.ObjId].(primitive.ObjectID)
+	return id.Hex()
+}
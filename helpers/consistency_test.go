@@ -0,0 +1,141 @@
+package helpers
+
+import (
+	"rarity-backend/structs"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func testSnapshot(dateTime string, oldGene string, newGene string, snapshotType string) bson.M {
+	return bson.M{
+		"_id":      primitive.NewObjectID(),
+		"tokenid":  int32(1),
+		"datetime": dateTime,
+		"oldgene":  oldGene,
+		"newgene":  newGene,
+		"type":     snapshotType,
+	}
+}
+
+func snapshotGenes(snapshots []bson.M) []string {
+	genes := []string{}
+	for _, snapshot := range snapshots {
+		genes = append(genes, snapshot["newgene"].(string))
+	}
+	return genes
+}
+
+func TestOrderSnapshots(t *testing.T) {
+	tests := []struct {
+		name      string
+		snapshots []bson.M
+		want      []string
+	}{
+		{"by time", []bson.M{
+			testSnapshot("2021-07-02T00:00:00Z", "1200", "1230", "Morph"),
+			testSnapshot("2021-07-01T00:00:00Z", "1000", "1200", "Morph"),
+		}, []string{"1200", "1230"}},
+		{"same time follows the genes", []bson.M{
+			testSnapshot("2021-07-01T00:00:00Z", "1230", "1234", "Morph"),
+			testSnapshot("2021-07-01T00:00:00Z", "1000", "1200", "Morph"),
+			testSnapshot("2021-07-01T00:00:00Z", "1200", "1230", "Morph"),
+		}, []string{"1200", "1230", "1234"}},
+		{"same time continues the earlier snapshots", []bson.M{
+			testSnapshot("2021-07-02T00:00:00Z", "1300", "1340", "Morph"),
+			testSnapshot("2021-07-02T00:00:00Z", "1200", "1300", "Morph"),
+			testSnapshot("2021-07-01T00:00:00Z", "1000", "1200", "Morph"),
+		}, []string{"1200", "1300", "1340"}},
+		{"broken chain keeps the saved order", []bson.M{
+			testSnapshot("2021-07-01T00:00:00Z", "5000", "5100", "Morph"),
+			testSnapshot("2021-07-01T00:00:00Z", "1000", "1200", "Morph"),
+		}, []string{"5100", "1200"}},
+	}
+
+	for _, test := range tests {
+		if got := snapshotGenes(orderSnapshots(test.snapshots)); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: got %v, expected %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestNextSnapshot(t *testing.T) {
+	first := testSnapshot("2021-07-01T00:00:00Z", "1000", "1200", "Morph")
+	second := testSnapshot("2021-07-01T00:00:00Z", "1200", "1230", "Morph")
+	unrelated := testSnapshot("2021-07-01T00:00:00Z", "9000", "9100", "Morph")
+
+	tests := []struct {
+		name      string
+		ordered   []bson.M
+		remaining []bson.M
+		want      int
+	}{
+		{"first doesn't continue another snapshot", nil, []bson.M{second, first}, 1},
+		{"continues the previous gene", []bson.M{first}, []bson.M{unrelated, second}, 1},
+		{"earliest saved if nothing continues", []bson.M{second}, []bson.M{unrelated, first}, 0},
+		{"earliest saved if all continue each other", nil, []bson.M{
+			testSnapshot("2021-07-01T00:00:00Z", "2000", "3000", "Morph"),
+			testSnapshot("2021-07-01T00:00:00Z", "3000", "2000", "Morph"),
+		}, 0},
+	}
+
+	for _, test := range tests {
+		if got := nextSnapshot(test.ordered, test.remaining); got != test.want {
+			t.Errorf("%v: got %v, expected %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestCountSnapshots(t *testing.T) {
+	snapshots := []bson.M{
+		testSnapshot("2021-07-01T00:00:00Z", "1000", "1200", "Morph"),
+		testSnapshot("2021-07-02T00:00:00Z", "1200", "1234", "Morph"),
+		// Morphing to the same trait doesn't change the gene and isn't counted by SaveMorph
+		testSnapshot("2021-07-03T00:00:00Z", "1234", "1234", "Morph"),
+		testSnapshot("2021-07-04T00:00:00Z", "1234", "5678", "Scramble"),
+	}
+	if morphs, scrambles := CountSnapshots(snapshots); morphs != 2 || scrambles != 1 {
+		t.Errorf("Got %v morphs and %v scrambles, expected 2 and 1", morphs, scrambles)
+	}
+}
+
+func TestCheckHistory(t *testing.T) {
+	entity := func(gene string, morphs int, scrambles int) bson.M {
+		return bson.M{"tokenid": int32(1), "currentgene": gene, "morphs": int32(morphs), "scrambles": int32(scrambles)}
+	}
+	history := []bson.M{
+		testSnapshot("2021-07-01T00:00:00Z", "1000", "1200", "Morph"),
+		testSnapshot("2021-07-02T00:00:00Z", "1200", "1200", "Morph"),
+		testSnapshot("2021-07-03T00:00:00Z", "1200", "5678", "Scramble"),
+	}
+
+	tests := []struct {
+		name      string
+		entity    bson.M
+		snapshots []bson.M
+		want      []structs.VerifyMismatch
+	}{
+		{"consistent", entity("5678", 1, 1), history, nil},
+		{"no history", entity("1000", 0, 0), nil, nil},
+		{"counters start missing", bson.M{"tokenid": int32(1), "currentgene": "1000"}, nil, nil},
+		{"broken chain", entity("5678", 1, 1), []bson.M{history[0], testSnapshot("2021-07-02T00:00:00Z", "1300", "5678", "Scramble")},
+			[]structs.VerifyMismatch{
+				{TokenId: 1, Check: CHECK_HISTORY_CHAIN, Stored: "snapshot 2 old gene 1300", Expected: "1200"},
+			}},
+		{"outdated latest snapshot", entity("9999", 1, 1), history,
+			[]structs.VerifyMismatch{{TokenId: 1, Check: CHECK_LATEST_SNAPSHOT, Stored: "5678", Expected: "9999"}}},
+		{"counters", entity("5678", 2, 0), history,
+			[]structs.VerifyMismatch{
+				{TokenId: 1, Check: CHECK_MORPHS, Stored: "2", Expected: "1"},
+				{TokenId: 1, Check: CHECK_SCRAMBLES, Stored: "0", Expected: "1"},
+			}},
+	}
+
+	for _, test := range tests {
+		if got := CheckHistory(test.entity, test.snapshots); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: got %+v, expected %+v", test.name, got, test.want)
+		}
+	}
+}
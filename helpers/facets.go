@@ -0,0 +1,61 @@
+package helpers
+
+import (
+	"fmt"
+	"rarity-backend/config"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ParseFacetsQueryString accepts a comma separated list of facetable fields or "all" for every facetable field of config.MORPH_FIELDS
+//
+// Returns an error if a field isn't facetable
+func ParseFacetsQueryString(facets string) ([]string, error) {
+	if strings.EqualFold(strings.TrimSpace(facets), "all") {
+		var fields []string
+		for name, spec := range config.MORPH_FIELDS {
+			if spec.Public && spec.Facetable {
+				fields = append(fields, name)
+			}
+		}
+		sort.Strings(fields)
+		return fields, nil
+	}
+
+	var fields []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(facets, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		spec, ok := config.GetMorphField(name)
+		if !ok || !spec.Facetable {
+			return nil, fmt.Errorf("Invalid facet field %q", name)
+		}
+		seen[name] = true
+		fields = append(fields, name)
+	}
+	return fields, nil
+}
+
+// BuildFacetPipeline creates an aggregation which counts the polymorphs matching the filter per value of each field.
+//
+// Counts are sorted from the most common value
+func BuildFacetPipeline(filter bson.M, fields []string) mongo.Pipeline {
+	facets := bson.M{}
+	for _, field := range fields {
+		facets[field] = bson.A{
+			bson.M{"$group": bson.M{"_id": "$" + field, "count": bson.M{"$sum": 1}}},
+			bson.M{"$sort": bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}},
+		}
+	}
+
+	return mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$facet", Value: facets}},
+	}
+}
@@ -0,0 +1,49 @@
+package helpers
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestParseFacetsQueryString(t *testing.T) {
+	tests := []struct {
+		facets string
+		want   []string
+	}{
+		{"character, Background,character", []string{"character", "background"}},
+		{"", nil},
+		{" ALL ", []string{"background", "character", "eyewear", "footwear", "hascompletedset", "headwear", "isvirgin", "lefthand", "mainsetname", "pants", "righthand", "secsetname", "torso"}},
+	}
+
+	for _, test := range tests {
+		got, err := ParseFacetsQueryString(test.facets)
+		if err != nil || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: got %v and %v, expected %v", test.facets, got, err, test.want)
+		}
+	}
+
+	// Fields which aren't facetable, private or unknown are rejected
+	for _, facets := range []string{"rarityscore", "character,oldgenes", "color"} {
+		if _, err := ParseFacetsQueryString(facets); err == nil {
+			t.Errorf("%q: expected an error", facets)
+		}
+	}
+}
+
+func TestBuildFacetPipeline(t *testing.T) {
+	filter := bson.M{"isvirgin": true}
+	got := BuildFacetPipeline(filter, []string{"character"})
+	want := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$facet", Value: bson.M{"character": bson.A{
+			bson.M{"$group": bson.M{"_id": "$character", "count": bson.M{"$sum": 1}}},
+			bson.M{"$sort": bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}},
+		}}}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Got %v, expected %v", got, want)
+	}
+}
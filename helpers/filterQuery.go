@@ -1,138 +1,509 @@
 package helpers
 
 import (
-	"log"
+	"fmt"
+	"rarity-backend/config"
+	"rarity-backend/structs"
+	"regexp"
 	"strconv"
 	"strings"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+const FILTER_MAX_LENGTH = 2000
+const FILTER_MAX_DEPTH = 20
+
+// FilterSyntaxError is returned if a filter can't be parsed. Pos is the byte offset of the error in the filter
+type FilterSyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *FilterSyntaxError) Error() string {
+	return fmt.Sprintf("invalid filter at position %d: %s", e.Pos+1, e.Msg)
+}
+
+type filterTokenKind int
+
 const (
-	paramSeparator = ";"
-	expSeparator   = "_"
+	tokenEOF filterTokenKind = iota
+	tokenWord
+	tokenString
+	tokenOperator
+	tokenLParen
+	tokenRParen
+	tokenLBracket
+	tokenRBracket
+	tokenComma
+	tokenSemicolon
+	tokenNot
+	tokenAnd
+	tokenOr
 )
 
-type Expression struct {
-	Field     string
-	Operator  string
-	Value     string
-	Join      string
-	Operator2 string
-	Value2    string
+type filterToken struct {
+	kind filterTokenKind
+	text string
+	pos  int
+}
+
+// filterValue is a value of a condition. value is the text converted to the type of the field
+type filterValue struct {
+	text  string
+	value interface{}
+	pos   int
+}
+
+// filterNode is a node of a parsed filter expression
+type filterNode interface {
+	toBson() bson.M
+}
+
+type filterCondition struct {
+	field    string
+	operator string
+	values   []filterValue
+	pos      int
+}
+
+type filterAnd struct {
+	nodes []filterNode
+}
+
+type filterOr struct {
+	nodes []filterNode
 }
 
-// ParseFilterQueryString accepts a special syntax string and tries to parse it to a mongo db filter query
+type filterNot struct {
+	node filterNode
+}
+
+var filterFieldPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// filterSymbolicOperators maps the symbolic comparison operators to the named ones
+var filterSymbolicOperators = map[string]string{
+	"=":  "eq",
+	"==": "eq",
+	"!=": "ne",
+	"<":  "lt",
+	"<=": "lte",
+	">":  "gt",
+	">=": "gte",
+}
+
+// ParseFilterQueryString parses a filter expression and translates it to a mongo db filter query.
+//
+// A filter consists of conditions combined with and, or, not and parentheses. and binds stronger than or. && and ; can be used instead of and, || instead of or and ! instead of not.
 //
-// The syntax can accept many expressions separated with the expSeparator.
+// Conditions:
 //
-// Each part of the expression is separated by the paramSeparator
-// Each expresion can be short and long one
+//	field op value - op is one of =, ==, !=, <, <=, >, >= or eq, ne, lt, lte, gt, gte
 //
-// Short expressions consist of 3 parts: field, operator, value
+//	field in (value, ...) / field nin (value, ...) - square brackets can be used as well
 //
-// Example: mainsetname_eq_Spartan
+//	field exists
 //
-// Long expressions consist of 6 parts: field, operator, value, join operator, operator 2, value 2
+//	field contains value - case insensitive substring match
 //
-// Example: rarityscore_gte_10.2_and_lte_12.4
+// Values can be quoted with single or double quotes, which is required for values containing spaces or special characters.
 //
-// Supported fields: any field from contants.PolymorphFields.go
+// Only public fields of config.MORPH_FIELDS can be used. Values are converted to the type of the field and operators which the field type doesn't support are rejected.
 //
-// Supported operators: eq, lt, lte, gt, gte
+// Example: (rarityscore >= 10.2 and rarityscore <= 12.4) or mainsetname in ("Spartan", 'Golden Suit'); not isvirgin = false
 //
-// Supported join operators: and, or
-func ParseFilterQueryString(filter string) bson.M {
-	// rarityscore_gte_10.2_and_lte_12.4;mainsetname_eq_Spartan;isvirgin_eq_false
-	expressions := strings.Split(filter, paramSeparator)
-	expArray := []Expression{}
-
-	for _, expression := range expressions {
-		exParts := strings.Split(expression, expSeparator)
-		if len(exParts) == 3 {
-			field, operator, value := strings.ToLower(exParts[0]), exParts[1], exParts[2]
-
-			expArray = append(expArray, Expression{
-				Field:    field,
-				Operator: operator,
-				Value:    value,
-			})
-		} else if len(exParts) == 6 {
-			field, operator, value, join, operator2, value2 := strings.ToLower(exParts[0]), exParts[1], exParts[2], exParts[3], exParts[4], exParts[5]
-
-			expArray = append(expArray, Expression{
-				Field:     field,
-				Operator:  operator,
-				Value:     value,
-				Join:      join,
-				Operator2: operator2,
-				Value2:    value2,
-			})
+// Returns a *FilterSyntaxError with the position of the error if the filter is invalid
+func ParseFilterQueryString(filter string) (bson.M, error) {
+	if len(filter) > FILTER_MAX_LENGTH {
+		return nil, &FilterSyntaxError{Pos: FILTER_MAX_LENGTH, Msg: fmt.Sprintf("filter is longer than %d characters", FILTER_MAX_LENGTH)}
+	}
 
-		}
+	node, err := parseFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return bson.M{}, nil
+	}
+	return node.toBson(), nil
+}
+
+// parseFilter parses the filter into an expression tree. Returns nil if the filter is empty
+func parseFilter(filter string) (filterNode, error) {
+	tokens, err := lexFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	if tokens[0].kind == tokenEOF {
+		return nil, nil
+	}
+
+	p := &filterParser{tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
 	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, &FilterSyntaxError{Pos: t.pos, Msg: fmt.Sprintf("unexpected %q", t.text)}
+	}
+	return node, nil
+}
 
-	filters := buildFilter(expArray)
-	return filters
-}
-
-// buildFilter iterates over each parsed expression, parses it, creates a mongodb query and appends it to global filter query
-func buildFilter(expressions []Expression) bson.M {
-	filter := bson.M{}
-	for _, exp := range expressions {
-		switch exp.Join {
-		case "":
-			switch exp.Operator {
-			case "eq":
-				currBson := createEqBson(exp.Field, exp.Value)
-				for k, v := range currBson {
-					filter[k] = v
-				}
-			case "lt", "lte", "gt", "gte":
-				currBson := createCompareBson(exp.Field, exp.Operator, exp.Value)
-				for k, v := range currBson {
-					filter[k] = v
-				}
+// lexFilter splits the filter into tokens. The last token is always tokenEOF
+func lexFilter(filter string) ([]filterToken, error) {
+	var tokens []filterToken
+	i := 0
+	for i < len(filter) {
+		c := filter[i]
+		start := i
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+		case c == '(':
+			tokens = append(tokens, filterToken{tokenLParen, "(", start})
+			i++
+		case c == ')':
+			tokens = append(tokens, filterToken{tokenRParen, ")", start})
+			i++
+		case c == '[':
+			tokens = append(tokens, filterToken{tokenLBracket, "[", start})
+			i++
+		case c == ']':
+			tokens = append(tokens, filterToken{tokenRBracket, "]", start})
+			i++
+		case c == ',':
+			tokens = append(tokens, filterToken{tokenComma, ",", start})
+			i++
+		case c == ';':
+			tokens = append(tokens, filterToken{tokenSemicolon, ";", start})
+			i++
+		case c == '!' || c == '=' || c == '<' || c == '>':
+			i++
+			if i < len(filter) && filter[i] == '=' {
+				i++
+			}
+			op := filter[start:i]
+			if op == "!" {
+				tokens = append(tokens, filterToken{tokenNot, op, start})
+			} else {
+				tokens = append(tokens, filterToken{tokenOperator, op, start})
+			}
+		case c == '&' || c == '|':
+			if i+1 >= len(filter) || filter[i+1] != c {
+				return nil, &FilterSyntaxError{Pos: start, Msg: fmt.Sprintf("expected %q", string([]byte{c, c}))}
+			}
+			i += 2
+			if c == '&' {
+				tokens = append(tokens, filterToken{tokenAnd, "&&", start})
+			} else {
+				tokens = append(tokens, filterToken{tokenOr, "||", start})
+			}
+		case c == '"' || c == '\'':
+			value, end, err := lexQuoted(filter, start)
+			if err != nil {
+				return nil, err
 			}
-		case "and", "or":
-			var bson1, bson2 bson.M
-			bson1 = createCompareBson(exp.Field, exp.Operator, exp.Value)
-			bson2 = createCompareBson(exp.Field, exp.Operator2, exp.Value2)
+			tokens = append(tokens, filterToken{tokenString, value, start})
+			i = end
+		default:
+			for i < len(filter) && !strings.ContainsRune(" \t\n\r()[],;!=<>&|\"'", rune(filter[i])) {
+				i++
+			}
+			tokens = append(tokens, filterToken{tokenWord, filter[start:i], start})
+		}
+	}
+	return append(tokens, filterToken{tokenEOF, "end of filter", len(filter)}), nil
+}
 
-			aBson := bson.A{bson1, bson2}
-			filter["$"+exp.Join] = aBson
+// lexQuoted reads the quoted string starting at start. A backslash escapes the next character
+func lexQuoted(filter string, start int) (string, int, error) {
+	quote := filter[start]
+	var value strings.Builder
+	for i := start + 1; i < len(filter); i++ {
+		switch filter[i] {
+		case '\\':
+			if i+1 < len(filter) {
+				i++
+				value.WriteByte(filter[i])
+			}
+		case quote:
+			return value.String(), i + 1, nil
+		default:
+			value.WriteByte(filter[i])
 		}
 	}
+	return "", 0, &FilterSyntaxError{Pos: start, Msg: "unterminated string"}
+}
+
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+	depth  int
+}
+
+func (p *filterParser) peek() filterToken {
+	return p.tokens[p.pos]
+}
+
+func (p *filterParser) next() filterToken {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func isFilterKeyword(t filterToken, keyword string) bool {
+	return t.kind == tokenWord && strings.EqualFold(t.text, keyword)
+}
 
-	return filter
+func (p *filterParser) parseOr() (filterNode, error) {
+	node, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	nodes := []filterNode{node}
+	for t := p.peek(); t.kind == tokenOr || isFilterKeyword(t, "or"); t = p.peek() {
+		p.next()
+		node, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return filterOr{nodes}, nil
 }
 
-// createEqBson creates a mongodb filter if the operator is "eq"
-func createEqBson(field string, value string) bson.M {
-	returnBson := bson.M{}
-	if value == "true" || value == "false" {
-		boolValue, err := strconv.ParseBool(value)
+func (p *filterParser) parseAnd() (filterNode, error) {
+	node, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	nodes := []filterNode{node}
+	for t := p.peek(); t.kind == tokenAnd || t.kind == tokenSemicolon || isFilterKeyword(t, "and"); t = p.peek() {
+		p.next()
+		// A trailing semicolon is allowed
+		if t.kind == tokenSemicolon && (p.peek().kind == tokenEOF || p.peek().kind == tokenRParen) {
+			break
+		}
+		node, err := p.parseNot()
 		if err != nil {
-			log.Println(err)
-		} else {
-			returnBson[field] = boolValue
+			return nil, err
 		}
-	} else {
-		returnBson[field] = value
+		nodes = append(nodes, node)
 	}
-	return returnBson
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return filterAnd{nodes}, nil
+}
+
+func (p *filterParser) parseNot() (filterNode, error) {
+	t := p.peek()
+	if t.kind != tokenNot && !isFilterKeyword(t, "not") {
+		return p.parsePrimary()
+	}
+
+	p.next()
+	if err := p.enter(t); err != nil {
+		return nil, err
+	}
+	node, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	p.depth--
+	return filterNot{node}, nil
 }
 
-// createCompareBson creates a mongodb filter if the operator is lt, lte, gt, gte
-func createCompareBson(field string, operator string, value string) bson.M {
-	returnBson := bson.M{}
+func (p *filterParser) parsePrimary() (filterNode, error) {
+	t := p.peek()
+	if t.kind != tokenLParen {
+		return p.parseCondition()
+	}
 
-	floatValue, err := strconv.ParseFloat(value, 64)
+	p.next()
+	if err := p.enter(t); err != nil {
+		return nil, err
+	}
+	node, err := p.parseOr()
 	if err != nil {
-		log.Println(err)
-	} else {
-		nestedBson := bson.M{"$" + operator: floatValue}
-		returnBson[field] = nestedBson
+		return nil, err
+	}
+	if closing := p.next(); closing.kind != tokenRParen {
+		return nil, &FilterSyntaxError{Pos: closing.pos, Msg: fmt.Sprintf("expected \")\" to close \"(\" at position %d, got %q", t.pos+1, closing.text)}
+	}
+	p.depth--
+	return node, nil
+}
+
+// enter increments the nesting depth. Deeply nested filters are rejected because every level makes the mongo query more expensive
+func (p *filterParser) enter(t filterToken) error {
+	p.depth++
+	if p.depth > FILTER_MAX_DEPTH {
+		return &FilterSyntaxError{Pos: t.pos, Msg: fmt.Sprintf("filter is nested deeper than %d levels", FILTER_MAX_DEPTH)}
+	}
+	return nil
+}
+
+func (p *filterParser) parseCondition() (filterNode, error) {
+	field := p.next()
+	if field.kind != tokenWord || !filterFieldPattern.MatchString(field.text) {
+		return nil, &FilterSyntaxError{Pos: field.pos, Msg: fmt.Sprintf("expected field name, got %q", field.text)}
+	}
+	condition := filterCondition{field: strings.ToLower(field.text), pos: field.pos}
+
+	op := p.next()
+	if op.kind == tokenOperator {
+		condition.operator = filterSymbolicOperators[op.text]
+	} else if op.kind == tokenWord {
+		condition.operator = strings.ToLower(op.text)
+	}
+
+	switch condition.operator {
+	case "eq", "ne", "lt", "lte", "gt", "gte", "contains":
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		condition.values = []filterValue{value}
+	case "in", "nin":
+		values, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		condition.values = values
+	case "exists":
+	default:
+		return nil, &FilterSyntaxError{Pos: op.pos, Msg: fmt.Sprintf("expected operator after %q, got %q", field.text, op.text)}
+	}
+
+	spec, ok := config.GetMorphField(condition.field)
+	if !ok {
+		return nil, &FilterSyntaxError{Pos: field.pos, Msg: fmt.Sprintf("unknown field %q", field.text)}
+	}
+	if !spec.SupportsOperator(condition.operator) {
+		return nil, &FilterSyntaxError{Pos: op.pos, Msg: fmt.Sprintf("operator %v isn't supported by %v field %q", condition.operator, spec.Type, field.text)}
+	}
+	for i := range condition.values {
+		if err := coerceFilterValue(&condition.values[i], spec); err != nil {
+			return nil, err
+		}
+	}
+	return condition, nil
+}
+
+// coerceFilterValue converts the text of the value to the type of the field. Quoting doesn't change the type, e.g. tokenid = '5' matches token 5
+func coerceFilterValue(v *filterValue, spec structs.FieldSpec) error {
+	switch spec.Type {
+	case config.FIELD_TYPE_INT:
+		n, err := strconv.ParseInt(v.text, 10, 64)
+		if err != nil {
+			return &FilterSyntaxError{Pos: v.pos, Msg: fmt.Sprintf("%v expects an integer, got %q", spec.Name, v.text)}
+		}
+		v.value = n
+	case config.FIELD_TYPE_FLOAT:
+		f, err := strconv.ParseFloat(v.text, 64)
+		if err != nil {
+			return &FilterSyntaxError{Pos: v.pos, Msg: fmt.Sprintf("%v expects a number, got %q", spec.Name, v.text)}
+		}
+		v.value = f
+	case config.FIELD_TYPE_BOOL:
+		switch strings.ToLower(v.text) {
+		case "true":
+			v.value = true
+		case "false":
+			v.value = false
+		default:
+			return &FilterSyntaxError{Pos: v.pos, Msg: fmt.Sprintf("%v expects true or false, got %q", spec.Name, v.text)}
+		}
+	default:
+		v.value = v.text
+	}
+	return nil
+}
+
+func (p *filterParser) parseValue() (filterValue, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenString:
+		fallthrough
+	case tokenWord:
+		return filterValue{text: t.text, pos: t.pos}, nil
+	}
+	return filterValue{}, &FilterSyntaxError{Pos: t.pos, Msg: fmt.Sprintf("expected value, got %q", t.text)}
+}
+
+// parseList parses a comma separated list of values in parentheses or square brackets
+func (p *filterParser) parseList() ([]filterValue, error) {
+	open := p.next()
+	var closeKind filterTokenKind
+	switch open.kind {
+	case tokenLParen:
+		closeKind = tokenRParen
+	case tokenLBracket:
+		closeKind = tokenRBracket
+	default:
+		return nil, &FilterSyntaxError{Pos: open.pos, Msg: fmt.Sprintf("expected list of values, got %q", open.text)}
+	}
+
+	var values []filterValue
+	for {
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+
+		t := p.next()
+		if t.kind == closeKind {
+			return values, nil
+		}
+		if t.kind != tokenComma {
+			return nil, &FilterSyntaxError{Pos: t.pos, Msg: fmt.Sprintf("expected \",\" or end of list, got %q", t.text)}
+		}
+	}
+}
+
+func (c filterCondition) toBson() bson.M {
+	switch c.operator {
+	case "in", "nin":
+		values := bson.A{}
+		for _, v := range c.values {
+			values = append(values, v.value)
+		}
+		return bson.M{c.field: bson.M{"$" + c.operator: values}}
+	case "exists":
+		return bson.M{c.field: bson.M{"$exists": true}}
+	case "contains":
+		regex := primitive.Regex{Pattern: regexp.QuoteMeta(c.values[0].text), Options: "i"}
+		return bson.M{c.field: bson.M{"$regex": regex}}
+	}
+	return bson.M{c.field: bson.M{"$" + c.operator: c.values[0].value}}
+}
+
+func (a filterAnd) toBson() bson.M {
+	return bson.M{"$and": filterNodesToBson(a.nodes)}
+}
+
+func (o filterOr) toBson() bson.M {
+	return bson.M{"$or": filterNodesToBson(o.nodes)}
+}
+
+// toBson uses $nor because $not can only negate operator expressions of a single field
+func (n filterNot) toBson() bson.M {
+	return bson.M{"$nor": bson.A{n.node.toBson()}}
+}
+
+func filterNodesToBson(nodes []filterNode) bson.A {
+	queries := bson.A{}
+	for _, node := range nodes {
+		queries = append(queries, node.toBson())
 	}
-	return returnBson
+	return queries
 }
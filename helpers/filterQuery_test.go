@@ -0,0 +1,216 @@
+package helpers
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestParseFilterQueryString(t *testing.T) {
+	tokenOne := bson.M{"tokenid": bson.M{"$eq": int64(1)}}
+	rankTwo := bson.M{"rank": bson.M{"$eq": int64(2)}}
+	virgin := bson.M{"isvirgin": bson.M{"$eq": true}}
+
+	tests := []struct {
+		name   string
+		filter string
+		want   bson.M
+	}{
+		{"empty", "", bson.M{}},
+		{"whitespace", " \t\n", bson.M{}},
+		{"symbolic operator", "rarityscore >= 10.5", bson.M{"rarityscore": bson.M{"$gte": 10.5}}},
+		{"named operator", "rank lt 5", bson.M{"rank": bson.M{"$lt": int64(5)}}},
+		{"double equals", "tokenid == 1", tokenOne},
+		{"not equal", "isvirgin != false", bson.M{"isvirgin": bson.M{"$ne": false}}},
+		{"case insensitive field and keywords", "TokenId = 1 AND Rank = 2", bson.M{"$and": bson.A{tokenOne, rankTwo}}},
+
+		{"and binds stronger than or", "tokenid = 1 or rank = 2 and isvirgin = true",
+			bson.M{"$or": bson.A{tokenOne, bson.M{"$and": bson.A{rankTwo, virgin}}}}},
+		{"and before or", "tokenid = 1 and rank = 2 or isvirgin = true",
+			bson.M{"$or": bson.A{bson.M{"$and": bson.A{tokenOne, rankTwo}}, virgin}}},
+		{"parentheses", "tokenid = 1 and (rank = 2 or isvirgin = true)",
+			bson.M{"$and": bson.A{tokenOne, bson.M{"$or": bson.A{rankTwo, virgin}}}}},
+		{"not binds stronger than and", "not tokenid = 1 and rank = 2",
+			bson.M{"$and": bson.A{bson.M{"$nor": bson.A{tokenOne}}, rankTwo}}},
+		{"not of parentheses", "!(tokenid = 1 || rank = 2)",
+			bson.M{"$nor": bson.A{bson.M{"$or": bson.A{tokenOne, rankTwo}}}}},
+		{"double not", "not not tokenid = 1", bson.M{"$nor": bson.A{bson.M{"$nor": bson.A{tokenOne}}}}},
+		{"symbolic and", "tokenid = 1 && rank = 2", bson.M{"$and": bson.A{tokenOne, rankTwo}}},
+		{"semicolon", "tokenid = 1; rank = 2", bson.M{"$and": bson.A{tokenOne, rankTwo}}},
+		{"trailing semicolon", "tokenid = 1;", tokenOne},
+		{"trailing semicolon in parentheses", "(tokenid = 1;) or rank = 2", bson.M{"$or": bson.A{tokenOne, rankTwo}}},
+		{"flattens chains", "tokenid = 1 or rank = 2 or isvirgin = true", bson.M{"$or": bson.A{tokenOne, rankTwo, virgin}}},
+
+		{"double quotes", `mainsetname = "Golden Suit"`, bson.M{"mainsetname": bson.M{"$eq": "Golden Suit"}}},
+		{"single quotes", `mainsetname = 'Golden Suit'`, bson.M{"mainsetname": bson.M{"$eq": "Golden Suit"}}},
+		{"escaped quotes", `mainsetname = "The \"Polymorph\" \\ 1"`, bson.M{"mainsetname": bson.M{"$eq": `The "Polymorph" \ 1`}}},
+		{"other quote inside", `mainsetname = "it's"`, bson.M{"mainsetname": bson.M{"$eq": "it's"}}},
+		{"special characters in quotes", `mainsetname = "a=(b), c;"`, bson.M{"mainsetname": bson.M{"$eq": "a=(b), c;"}}},
+		{"empty string", `mainsetname = ""`, bson.M{"mainsetname": bson.M{"$eq": ""}}},
+		{"quoted number keeps field type", `rarityscore < '12'`, bson.M{"rarityscore": bson.M{"$lt": float64(12)}}},
+
+		{"in", "tokenid in (1, 2)", bson.M{"tokenid": bson.M{"$in": bson.A{int64(1), int64(2)}}}},
+		{"in with brackets", "tokenid in [1]", bson.M{"tokenid": bson.M{"$in": bson.A{int64(1)}}}},
+		{"nin", `mainsetname nin ("Spartan", 'Golden Suit')`, bson.M{"mainsetname": bson.M{"$nin": bson.A{"Spartan", "Golden Suit"}}}},
+
+		{"exists", "secsetname exists", bson.M{"secsetname": bson.M{"$exists": true}}},
+		{"not exists", "not secsetname exists", bson.M{"$nor": bson.A{bson.M{"secsetname": bson.M{"$exists": true}}}}},
+
+		{"contains", "mainsetname contains morph", bson.M{"mainsetname": bson.M{"$regex": primitive.Regex{Pattern: "morph", Options: "i"}}}},
+		{"contains escapes regex", `mainsetname contains "a.b*(c)"`, bson.M{"mainsetname": bson.M{"$regex": primitive.Regex{Pattern: `a\.b\*\(c\)`, Options: "i"}}}},
+		{"contains array field", "mainmatchingtraits contains spartan", bson.M{"mainmatchingtraits": bson.M{"$regex": primitive.Regex{Pattern: "spartan", Options: "i"}}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseFilterQueryString(test.filter)
+			if err != nil {
+				t.Fatalf("Parsing %q failed: %v", test.filter, err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Parsing %q\ngot      %v\nexpected %v", test.filter, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseFilterQueryStringErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter string
+		pos    int
+		msg    string
+	}{
+		{"missing value", "tokenid = ", 10, "expected value"},
+		{"missing operator", "tokenid", 7, "expected operator"},
+		{"unknown operator", "tokenid like 1", 8, "expected operator"},
+		{"repeated operator", "tokenid >> 1", 9, "expected value"},
+		{"missing field", "= 1", 0, "expected field name"},
+		{"invalid field name", "1abc = 1", 0, "expected field name"},
+		{"unknown field", "rank = 1 and color = red", 13, `unknown field "color"`},
+		{"private field", `oldgenes = "1"`, 0, `unknown field "oldgenes"`},
+		{"unsupported operator", "isvirgin > true", 9, "operator gt isn't supported"},
+		{"contains on number", "rank contains 1", 5, "operator contains isn't supported"},
+		{"unclosed parenthesis", "(tokenid = 1", 12, `expected ")" to close "(" at position 1`},
+		{"unopened parenthesis", "tokenid = 1)", 11, `unexpected ")"`},
+		{"missing and", "tokenid = 1 rank = 2", 12, `unexpected "rank"`},
+		{"single ampersand", "tokenid = 1 & rank = 2", 12, `expected "&&"`},
+		{"single pipe", "tokenid = 1 | rank = 2", 12, `expected "||"`},
+		{"dangling or", "tokenid = 1 or", 14, "expected field name"},
+		{"unterminated string", `mainsetname = "abc`, 14, "unterminated string"},
+		{"empty list", "tokenid in ()", 12, "expected value"},
+		{"list without parentheses", "tokenid in 1", 11, "expected list of values"},
+		{"mismatched list brackets", "tokenid in (1]", 13, "expected \",\" or end of list"},
+		{"missing comma", "tokenid in (1 2)", 14, "expected \",\" or end of list"},
+		{"invalid list value", "tokenid in (1, x)", 15, "expects an integer"},
+		{"invalid bool", "isvirgin = yes", 11, "expects true or false"},
+		{"invalid float", "rarityscore = high", 14, "expects a number"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseFilterQueryString(test.filter)
+			var syntaxErr *FilterSyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("Parsing %q: got %v, expected a FilterSyntaxError", test.filter, err)
+			}
+			if syntaxErr.Pos != test.pos || !strings.Contains(syntaxErr.Msg, test.msg) {
+				t.Errorf("Parsing %q: got %q at %v, expected %q at %v", test.filter, syntaxErr.Msg, syntaxErr.Pos, test.msg, test.pos)
+			}
+		})
+	}
+}
+
+func TestFilterSyntaxErrorPosition(t *testing.T) {
+	err := &FilterSyntaxError{Pos: 0, Msg: "expected field name"}
+	if err.Error() != "invalid filter at position 1: expected field name" {
+		t.Errorf("Got %q, expected positions to start at 1", err.Error())
+	}
+}
+
+func TestParseFilterQueryStringLimits(t *testing.T) {
+	condition := "tokenid = 1"
+
+	padded := condition + strings.Repeat(" ", FILTER_MAX_LENGTH-len(condition))
+	if _, err := ParseFilterQueryString(padded); err != nil {
+		t.Errorf("Expected a filter of %v characters to be accepted, got %v", FILTER_MAX_LENGTH, err)
+	}
+	var syntaxErr *FilterSyntaxError
+	_, err := ParseFilterQueryString(padded + " ")
+	if !errors.As(err, &syntaxErr) || syntaxErr.Pos != FILTER_MAX_LENGTH {
+		t.Errorf("Got %v, expected the filter to be too long", err)
+	}
+
+	nested := func(depth int, open string, close string) string {
+		return strings.Repeat(open, depth) + condition + strings.Repeat(close, depth)
+	}
+	tests := []struct {
+		name   string
+		filter string
+		valid  bool
+	}{
+		{"max parentheses", nested(FILTER_MAX_DEPTH, "(", ")"), true},
+		{"too many parentheses", nested(FILTER_MAX_DEPTH+1, "(", ")"), false},
+		{"max nots", nested(FILTER_MAX_DEPTH, "not ", ""), true},
+		{"too many nots", nested(FILTER_MAX_DEPTH+1, "!", ""), false},
+		{"parentheses and nots count together", nested(FILTER_MAX_DEPTH/2, "not (", ")") + " and " + condition, true},
+		{"too many parentheses and nots", nested(FILTER_MAX_DEPTH/2+1, "not (", ")"), false},
+		{"sibling parentheses don't add up", strings.Repeat(nested(FILTER_MAX_DEPTH, "(", ")")+" or ", 3) + condition, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseFilterQueryString(test.filter)
+			if test.valid && err != nil {
+				t.Errorf("Expected the filter to be accepted, got %v", err)
+			}
+			if !test.valid && (!errors.As(err, &syntaxErr) || !strings.Contains(syntaxErr.Msg, "nested deeper")) {
+				t.Errorf("Got %v, expected the filter to be nested too deep", err)
+			}
+		})
+	}
+}
+
+func TestFilterValueCoercion(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   interface{}
+		err    string
+	}{
+		{`tokenid = "5"`, int64(5), ""},
+		{"tokenid = -3", int64(-3), ""},
+		{"tokenid = 9223372036854775807", int64(9223372036854775807), ""},
+		{"tokenid = 9223372036854775808", nil, "expects an integer"},
+		{"tokenid = 1.5", nil, "expects an integer"},
+		{"tokenid = 1e3", nil, "expects an integer"},
+		{"rarityscore = 7", float64(7), ""},
+		{"rarityscore = '1.5e2'", 150.0, ""},
+		{"isvirgin = TRUE", true, ""},
+		{`isvirgin = "false"`, false, ""},
+		{"isvirgin = 1", nil, "expects true or false"},
+		{"mainsetname = 5", "5", ""},
+		{"headwear = true", "true", ""},
+	}
+
+	for _, test := range tests {
+		got, err := ParseFilterQueryString(test.filter)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("Parsing %q: got %v, expected error %q", test.filter, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parsing %q failed: %v", test.filter, err)
+			continue
+		}
+		for _, condition := range got {
+			if value := condition.(bson.M)["$eq"]; !reflect.DeepEqual(value, test.want) {
+				t.Errorf("Parsing %q: got %#v, expected %#v", test.filter, value, test.want)
+			}
+		}
+	}
+}
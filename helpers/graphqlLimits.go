@@ -0,0 +1,168 @@
+package helpers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+// GRAPHQL_TAKE_ARGUMENT is the argument which sets the size of list fields
+const GRAPHQL_TAKE_ARGUMENT = "take"
+
+// QueryLimits are the limits of a GraphQL query.
+//
+// Depth is the number of nested selections. Complexity counts every selected field once per item of the lists it's nested in.
+// Fields in FieldCosts count their cost instead of 1, e.g. fields which call the node for every item.
+// The size of a list is its take argument, at most MaxListSize, or DefaultListSize for the ListFields which don't pass one.
+// Take arguments set by variables use the default of the variable definition if the variable isn't passed.
+// Introspection fields aren't counted
+type QueryLimits struct {
+	MaxDepth        int
+	MaxComplexity   int
+	ListFields      map[string]bool
+	FieldCosts      map[string]int
+	DefaultListSize int
+	MaxListSize     int
+}
+
+// CheckQueryLimits parses the query and returns an error if one of its operations exceeds the limits.
+//
+// Syntax errors are left to the GraphQL executor, which reports them with their location
+func CheckQueryLimits(query string, variables map[string]interface{}, limits QueryLimits) error {
+	document, err := parser.Parse(parser.ParseParams{Source: query})
+	if err != nil {
+		return nil
+	}
+
+	fragments := make(map[string]*ast.FragmentDefinition)
+	for _, definition := range document.Definitions {
+		if fragment, ok := definition.(*ast.FragmentDefinition); ok {
+			fragments[fragment.Name.Value] = fragment
+		}
+	}
+
+	checker := queryLimitChecker{limits: limits, variables: variables, fragments: fragments}
+	for _, definition := range document.Definitions {
+		operation, ok := definition.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		checker.defaults = variableDefaults(operation)
+		depth, complexity := checker.selectionSet(operation.SelectionSet, map[string]bool{})
+		if depth > limits.MaxDepth {
+			return fmt.Errorf("query depth %v exceeds the maximum of %v", depth, limits.MaxDepth)
+		}
+		if complexity > limits.MaxComplexity {
+			return fmt.Errorf("query complexity %v exceeds the maximum of %v", complexity, limits.MaxComplexity)
+		}
+	}
+	return nil
+}
+
+type queryLimitChecker struct {
+	limits    QueryLimits
+	variables map[string]interface{}
+	fragments map[string]*ast.FragmentDefinition
+	// defaults are the default values of the variables of the checked operation
+	defaults map[string]ast.Value
+}
+
+// variableDefaults returns the default values of the variables defined by the operation
+func variableDefaults(operation *ast.OperationDefinition) map[string]ast.Value {
+	defaults := make(map[string]ast.Value)
+	for _, definition := range operation.VariableDefinitions {
+		if definition.DefaultValue != nil {
+			defaults[definition.Variable.Name.Value] = definition.DefaultValue
+		}
+	}
+	return defaults
+}
+
+// selectionSet returns the depth and complexity of the selections. Fragments already spread on the path are skipped, so cycles terminate
+func (q queryLimitChecker) selectionSet(set *ast.SelectionSet, spread map[string]bool) (int, int) {
+	if set == nil {
+		return 0, 0
+	}
+
+	depth, complexity := 0, 0
+	for _, selection := range set.Selections {
+		d, c := 0, 0
+		switch s := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(s.Name.Value, "__") {
+				continue
+			}
+			childDepth, childComplexity := q.selectionSet(s.SelectionSet, spread)
+			d = childDepth + 1
+			c = q.fieldCost(s) + q.listSize(s)*childComplexity
+		case *ast.InlineFragment:
+			d, c = q.selectionSet(s.SelectionSet, spread)
+		case *ast.FragmentSpread:
+			fragment, ok := q.fragments[s.Name.Value]
+			if !ok || spread[s.Name.Value] {
+				continue
+			}
+			spread[s.Name.Value] = true
+			d, c = q.selectionSet(fragment.SelectionSet, spread)
+			delete(spread, s.Name.Value)
+		}
+		if d > depth {
+			depth = d
+		}
+		complexity += c
+	}
+	return depth, complexity
+}
+
+// fieldCost returns the cost of resolving the field once. It's 1 for fields without a cost
+func (q queryLimitChecker) fieldCost(field *ast.Field) int {
+	if cost, ok := q.limits.FieldCosts[field.Name.Value]; ok {
+		return cost
+	}
+	return 1
+}
+
+// listSize returns the number of items the field returns. It's 1 for fields which aren't lists
+func (q queryLimitChecker) listSize(field *ast.Field) int {
+	size := q.takeArgument(field)
+	if size > q.limits.MaxListSize {
+		return q.limits.MaxListSize
+	}
+	return size
+}
+
+func (q queryLimitChecker) takeArgument(field *ast.Field) int {
+	for _, argument := range field.Arguments {
+		if argument.Name.Value != GRAPHQL_TAKE_ARGUMENT {
+			continue
+		}
+		if size, ok := q.intValue(argument.Value); ok && size > 0 {
+			return size
+		}
+	}
+	if q.limits.ListFields[field.Name.Value] {
+		return q.limits.DefaultListSize
+	}
+	return 1
+}
+
+// intValue returns the value of an int literal or of a variable, using the default of the variable if it isn't passed
+func (q queryLimitChecker) intValue(value ast.Value) (int, bool) {
+	switch value := value.(type) {
+	case *ast.IntValue:
+		size, err := strconv.Atoi(value.Value)
+		return size, err == nil
+	case *ast.Variable:
+		if passed, ok := q.variables[value.Name.Value]; ok {
+			size, ok := passed.(float64)
+			return int(size), ok
+		}
+		if defaultValue, ok := q.defaults[value.Name.Value]; ok {
+			return q.intValue(defaultValue)
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,52 @@
+package helpers
+
+import (
+	"strings"
+	"testing"
+)
+
+var testQueryLimits = QueryLimits{
+	MaxDepth:        4,
+	MaxComplexity:   1000,
+	ListFields:      map[string]bool{"polymorphs": true, "history": true},
+	FieldCosts:      map[string]int{"owner": 20},
+	DefaultListSize: 10,
+	MaxListSize:     100,
+}
+
+func TestCheckQueryLimits(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		variables map[string]interface{}
+		err       string
+	}{
+		{"default list size", `{ polymorphs { tokenid rank } }`, nil, ""},
+		{"take", `{ polymorphs(take: 50) { tokenid history(take: 9) { type } } }`, nil, ""},
+		{"nested lists multiply", `{ polymorphs(take: 50) { tokenid history(take: 20) { type } } }`, nil, "complexity 1101"},
+		{"take is capped", `{ polymorphs(take: 5000) { tokenid } }`, nil, ""},
+		{"missing take uses the default", `{ polymorphs(take: 100) { history { type newgene } } }`, nil, "complexity 2101"},
+		{"non-positive take uses the default", `{ polymorphs(take: 100) { history(take: 0) { type } } }`, nil, "complexity 1101"},
+		{"passed variable", `query($t: Int) { polymorphs(take: $t) { tokenid } }`, map[string]interface{}{"t": float64(100)}, ""},
+		{"passed variable exceeds", `query($t: Int) { polymorphs(take: 100) { history(take: $t) { type } } }`, map[string]interface{}{"t": float64(50)}, "complexity 5101"},
+		{"variable default", `query($t: Int = 1000) { polymorphs(take: $t) { history { type } } }`, nil, "complexity 1101"},
+		{"passed variable overrides the default", `query($t: Int = 1000) { polymorphs(take: $t) { tokenid } }`, map[string]interface{}{"t": float64(5)}, ""},
+		{"variable without default", `query($t: Int) { polymorphs(take: 100) { history(take: $t) { type } } }`, nil, "complexity 1101"},
+		{"fragments", `{ polymorphs(take: 100) { ...f } } fragment f on Polymorph { history(take: 10) { type } }`, nil, "complexity 1101"},
+		{"field cost", `{ polymorphs(take: 40) { tokenid owner } }`, nil, ""},
+		{"field cost multiplies", `{ polymorphs(take: 50) { tokenid owner } }`, nil, "complexity 1051"},
+		{"depth", `{ polymorphs { a { b { c { d } } } } }`, nil, "depth 5"},
+		{"introspection isn't counted", `{ __schema { types { name fields { name type { name } } } } }`, nil, ""},
+		{"syntax errors are left to the executor", `{ polymorphs(`, nil, ""},
+	}
+
+	for _, test := range tests {
+		err := CheckQueryLimits(test.query, test.variables, testQueryLimits)
+		if test.err == "" && err != nil {
+			t.Errorf("%v: expected the query to be accepted, got %v", test.name, err)
+		}
+		if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("%v: got %v, expected %q", test.name, err, test.err)
+		}
+	}
+}
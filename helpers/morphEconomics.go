@@ -0,0 +1,136 @@
+package helpers
+
+import (
+	"fmt"
+	"rarity-backend/constants"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ParseEconomicsDay checks that the string is a date in the YYYY-MM-DD format
+func ParseEconomicsDay(s string) (string, error) {
+	day, err := time.Parse(constants.DAY_LAYOUT, s)
+	if err != nil {
+		return "", fmt.Errorf("Invalid date %q, expected YYYY-MM-DD", s)
+	}
+	return day.Format(constants.DAY_LAYOUT), nil
+}
+
+// HistoryDayRange matches the history snapshots from the start of the from day until the end of the to day. Empty days aren't limited.
+//
+// Snapshots store their time as a RFC3339 UTC string, so days are compared as strings
+func HistoryDayRange(from string, to string) bson.M {
+	dateRange := bson.M{}
+	if from != "" {
+		dateRange["$gte"] = from
+	}
+	if to != "" {
+		day, _ := time.Parse(constants.DAY_LAYOUT, to)
+		dateRange["$lt"] = day.AddDate(0, 0, 1).Format(constants.DAY_LAYOUT)
+	}
+	if len(dateRange) == 0 {
+		return bson.M{}
+	}
+	return bson.M{constants.HistoryFieldNames.DateTime: dateRange}
+}
+
+// morphSpendFields are the $group accumulators of structs.MorphSpend
+func morphSpendFields() bson.M {
+	isMorph := bson.M{"$eq": bson.A{"$" + constants.HistoryFieldNames.Type, constants.HISTORY_TYPE_MORPH}}
+	isScramble := bson.M{"$eq": bson.A{"$" + constants.HistoryFieldNames.Type, constants.HISTORY_TYPE_SCRAMBLE}}
+	price := "$" + constants.HistoryFieldNames.Price
+
+	return bson.M{
+		"morphs":         bson.M{"$sum": bson.M{"$cond": bson.A{isMorph, 1, 0}}},
+		"scrambles":      bson.M{"$sum": bson.M{"$cond": bson.A{isScramble, 1, 0}}},
+		"morphvolume":    bson.M{"$sum": bson.M{"$cond": bson.A{isMorph, price, 0}}},
+		"scramblevolume": bson.M{"$sum": bson.M{"$cond": bson.A{isScramble, price, 0}}},
+		"volume":         bson.M{"$sum": price},
+	}
+}
+
+// spendGroup groups the snapshots by the id expression and counts the distinct tokens if countTokens is set
+func spendGroup(id interface{}, countTokens bool) mongo.Pipeline {
+	group := morphSpendFields()
+	group["_id"] = id
+	if !countTokens {
+		return mongo.Pipeline{{{Key: "$group", Value: group}}}
+	}
+
+	group["tokens"] = bson.M{"$addToSet": "$" + constants.HistoryFieldNames.TokenId}
+	return mongo.Pipeline{
+		{{Key: "$group", Value: group}},
+		{{Key: "$set", Value: bson.M{"tokens": bson.M{"$size": "$tokens"}}}},
+	}
+}
+
+// MorphSummaryPipeline returns the pipeline which aggregates the history into a single structs.MorphEconomicsSummary
+func MorphSummaryPipeline() mongo.Pipeline {
+	return spendGroup(nil, true)
+}
+
+// DailyMorphStatsPipeline returns the pipeline which aggregates the history snapshots between the days into structs.DailyMorphStats, oldest first
+func DailyMorphStatsPipeline(from string, to string) mongo.Pipeline {
+	day := bson.M{"$substrBytes": bson.A{"$" + constants.HistoryFieldNames.DateTime, 0, len(constants.DAY_LAYOUT)}}
+
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: HistoryDayRange(from, to)}}}
+	pipeline = append(pipeline, spendGroup(day, true)...)
+	return append(pipeline, bson.D{{Key: "$sort", Value: bson.M{"_id": 1}}})
+}
+
+// DailyMorphStatsRollupSince returns the first day which has to be rolled up again after the last rolled up day. Returns an empty day if nothing is rolled up yet.
+//
+// Snapshots are recorded in chronological order, so only the days since the day before the last rolled up day can change.
+// The day before covers the snapshots which were saved after the last rollup and before midnight
+func DailyMorphStatsRollupSince(lastDay string) (string, error) {
+	if lastDay == "" {
+		return "", nil
+	}
+	day, err := time.Parse(constants.DAY_LAYOUT, lastDay)
+	if err != nil {
+		return "", err
+	}
+	return day.AddDate(0, 0, -1).Format(constants.DAY_LAYOUT), nil
+}
+
+// DailyMorphStatsRollupPipeline returns the pipeline which writes the daily stats of the history snapshots since the day into the stats collection.
+//
+// Days which are already rolled up are replaced
+func DailyMorphStatsRollupPipeline(since string, statsCollectionName string) mongo.Pipeline {
+	return append(DailyMorphStatsPipeline(since, ""), bson.D{{Key: "$merge", Value: bson.M{
+		"into":           statsCollectionName,
+		"on":             "_id",
+		"whenMatched":    "replace",
+		"whenNotMatched": "insert",
+	}}})
+}
+
+// TopSpendersPipeline returns the pipeline which aggregates the spend per value of the history field, highest volume first.
+//
+// Snapshots without the field, like the ones recorded before wallets were tracked, are skipped
+func TopSpendersPipeline(field string, take int64) mongo.Pipeline {
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.M{field: bson.M{"$exists": true, "$ne": ""}}}}}
+	pipeline = append(pipeline, spendGroup("$"+field, false)...)
+	return append(pipeline,
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "volume", Value: -1}, {Key: "_id", Value: 1}}}},
+		bson.D{{Key: "$limit", Value: take}},
+	)
+}
+
+// TraitSlotMorphsPipeline returns the pipeline which counts the morphs per changed trait type, most changed first
+func TraitSlotMorphsPipeline() mongo.Pipeline {
+	return mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			constants.HistoryFieldNames.Type:             constants.HISTORY_TYPE_MORPH,
+			constants.HistoryFieldNames.AttributeChanged: bson.M{"$exists": true, "$ne": ""},
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":    "$" + constants.HistoryFieldNames.AttributeChanged,
+			"morphs": bson.M{"$sum": 1},
+			"volume": bson.M{"$sum": "$" + constants.HistoryFieldNames.Price},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "morphs", Value: -1}, {Key: "_id", Value: 1}}}},
+	}
+}
@@ -0,0 +1,83 @@
+package helpers
+
+import (
+	"rarity-backend/constants"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestHistoryDayRange(t *testing.T) {
+	tests := []struct {
+		name string
+		from string
+		to   string
+		want bson.M
+	}{
+		{"no limits", "", "", bson.M{}},
+		{"from", "2021-07-01", "", bson.M{constants.HistoryFieldNames.DateTime: bson.M{"$gte": "2021-07-01"}}},
+		// The snapshot times start with the day, so the day after is the first one not included
+		{"to includes the whole day", "", "2021-07-01", bson.M{constants.HistoryFieldNames.DateTime: bson.M{"$lt": "2021-07-02"}}},
+		{"to at the end of the year", "", "2021-12-31", bson.M{constants.HistoryFieldNames.DateTime: bson.M{"$lt": "2022-01-01"}}},
+		{"single day", "2021-07-01", "2021-07-01", bson.M{constants.HistoryFieldNames.DateTime: bson.M{"$gte": "2021-07-01", "$lt": "2021-07-02"}}},
+	}
+
+	for _, test := range tests {
+		if got := HistoryDayRange(test.from, test.to); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: got %v, expected %v", test.name, got, test.want)
+		}
+	}
+
+	// The last snapshot of the to day is matched and the first one of the next day isn't
+	dateRange := HistoryDayRange("", "2021-07-01")[constants.HistoryFieldNames.DateTime].(bson.M)
+	if end := dateRange["$lt"].(string); !("2021-07-01T23:59:59Z" < end) || !("2021-07-02T00:00:00Z" >= end) {
+		t.Errorf("Got end %v, expected it to include the whole day", end)
+	}
+}
+
+func TestParseEconomicsDay(t *testing.T) {
+	tests := []struct {
+		day   string
+		valid bool
+	}{
+		{"2021-07-01", true},
+		{"2021-7-1", false},
+		{"2021-02-30", false},
+		{"2021-07-01T00:00:00Z", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		day, err := ParseEconomicsDay(test.day)
+		if (err == nil) != test.valid || (test.valid && day != test.day) {
+			t.Errorf("%q: got %q and %v, expected valid %v", test.day, day, err, test.valid)
+		}
+	}
+}
+
+func TestDailyMorphStatsRollupSince(t *testing.T) {
+	tests := []struct {
+		lastDay string
+		want    string
+		valid   bool
+	}{
+		// Nothing rolled up yet, the whole history is aggregated
+		{"", "", true},
+		{"2021-07-15", "2021-07-14", true},
+		{"2021-07-01", "2021-06-30", true},
+		{"2021-01-01", "2020-12-31", true},
+		{"2020-03-01", "2020-02-29", true},
+		{"15.07.2021", "", false},
+	}
+
+	for _, test := range tests {
+		since, err := DailyMorphStatsRollupSince(test.lastDay)
+		if (err == nil) != test.valid {
+			t.Errorf("%q: got error %v, expected valid %v", test.lastDay, err, test.valid)
+		}
+		if since != test.want {
+			t.Errorf("%q: got %q, expected %q", test.lastDay, since, test.want)
+		}
+	}
+}
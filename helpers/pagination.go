@@ -0,0 +1,100 @@
+package helpers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"rarity-backend/constants"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// PageCursor points after the last result of a page. It's only valid for the sort it was created with
+type PageCursor struct {
+	SortField string      `json:"f"`
+	SortDir   int         `json:"d"`
+	Value     interface{} `json:"v,omitempty"`
+	TokenId   int         `json:"id"`
+	// Offset is the number of results to skip. It's only used for sorts which can't be filtered on, like text search relevance
+	Offset int64 `json:"o,omitempty"`
+}
+
+// EncodeCursor returns the cursor as an opaque url safe string
+func EncodeCursor(cursor PageCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a cursor created by EncodeCursor and checks that it was created for the passed sort
+func DecodeCursor(s string, sortField string, sortDir int) (PageCursor, error) {
+	var cursor PageCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor, errors.New("Invalid cursor")
+	}
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return cursor, errors.New("Invalid cursor")
+	}
+	if cursor.SortField != sortField || cursor.SortDir != sortDir {
+		return cursor, errors.New("Cursor was created for a different sort")
+	}
+	return cursor, nil
+}
+
+// NextCursor creates the cursor pointing after the passed result. Results must be sorted by the sort field and then by token id ascending
+func NextCursor(last bson.M, sortField string, sortDir int) (string, error) {
+	tokenId, ok := toInt(last[constants.MorphFieldNames.TokenId])
+	if !ok {
+		return "", fmt.Errorf("Result has no token id")
+	}
+
+	cursor := PageCursor{SortField: sortField, SortDir: sortDir, TokenId: tokenId}
+	if sortField != constants.MorphFieldNames.TokenId {
+		cursor.Value = last[sortField]
+	}
+	return EncodeCursor(cursor), nil
+}
+
+// CursorFilter returns the filter matching the results after the cursor.
+//
+// Results with the same sort value are ordered by token id, so the token id breaks ties.
+// MongoDB sorts missing and null values before any number, but $gt and $lt never match them, so they need their own branch
+func CursorFilter(cursor PageCursor) bson.M {
+	op := "$gt"
+	if cursor.SortDir < 0 {
+		op = "$lt"
+	}
+
+	if cursor.SortField == constants.MorphFieldNames.TokenId {
+		return bson.M{constants.MorphFieldNames.TokenId: bson.M{op: cursor.TokenId}}
+	}
+	ties := bson.M{cursor.SortField: cursor.Value, constants.MorphFieldNames.TokenId: bson.M{"$gt": cursor.TokenId}}
+	if cursor.Value == nil {
+		// Null values come first in ascending order, so every value follows them, and last in descending order
+		if cursor.SortDir < 0 {
+			return ties
+		}
+		return bson.M{"$or": bson.A{bson.M{cursor.SortField: bson.M{"$ne": nil}}, ties}}
+	}
+
+	branches := bson.A{bson.M{cursor.SortField: bson.M{op: cursor.Value}}, ties}
+	if cursor.SortDir < 0 {
+		branches = append(branches, bson.M{cursor.SortField: nil})
+	}
+	return bson.M{"$or": branches}
+}
+
+func toInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case int32:
+		return int(n), true
+	case int64:
+		return int(n), true
+	case float64:
+		return int(n), true
+	}
+	return 0, false
+}
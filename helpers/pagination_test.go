@@ -0,0 +1,201 @@
+package helpers
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	s, err := NextCursor(bson.M{"tokenid": int32(42), "rarityscore": 12.5}, "rarityscore", -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cursor, err := DecodeCursor(s, "rarityscore", -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := PageCursor{SortField: "rarityscore", SortDir: -1, Value: 12.5, TokenId: 42}
+	if !reflect.DeepEqual(cursor, want) {
+		t.Errorf("Got %+v, expected %+v", cursor, want)
+	}
+
+	// Sorting by token id doesn't need a sort value
+	s, _ = NextCursor(bson.M{"tokenid": int64(7), "rank": 3}, "tokenid", 1)
+	if cursor, _ := DecodeCursor(s, "tokenid", 1); cursor.Value != nil || cursor.TokenId != 7 {
+		t.Errorf("Got %+v, expected token id 7 without value", cursor)
+	}
+
+	offset := EncodeCursor(PageCursor{SortField: "score", SortDir: -1, Offset: 200})
+	if cursor, err := DecodeCursor(offset, "score", -1); err != nil || cursor.Offset != 200 {
+		t.Errorf("Got %+v and %v, expected offset 200", cursor, err)
+	}
+}
+
+func TestDecodeCursorErrors(t *testing.T) {
+	valid := EncodeCursor(PageCursor{SortField: "rank", SortDir: 1, Value: 3, TokenId: 5})
+	tests := []struct {
+		name      string
+		cursor    string
+		sortField string
+		sortDir   int
+	}{
+		{"not base64", "not a cursor!", "rank", 1},
+		{"not json", "bm90IGpzb24", "rank", 1},
+		{"other sort field", valid, "rarityscore", 1},
+		{"other sort direction", valid, "rank", -1},
+	}
+
+	for _, test := range tests {
+		if _, err := DecodeCursor(test.cursor, test.sortField, test.sortDir); err == nil {
+			t.Errorf("%v: expected an error", test.name)
+		}
+	}
+}
+
+func TestNextCursorWithoutTokenId(t *testing.T) {
+	if _, err := NextCursor(bson.M{"rank": 1}, "rank", 1); err == nil {
+		t.Error("Expected an error for a result without token id")
+	}
+}
+
+func TestCursorFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor PageCursor
+		want   bson.M
+	}{
+		{"token id ascending", PageCursor{SortField: "tokenid", SortDir: 1, TokenId: 10},
+			bson.M{"tokenid": bson.M{"$gt": 10}}},
+		{"token id descending", PageCursor{SortField: "tokenid", SortDir: -1, TokenId: 10},
+			bson.M{"tokenid": bson.M{"$lt": 10}}},
+		{"ascending breaks ties by token id", PageCursor{SortField: "rank", SortDir: 1, Value: 3.0, TokenId: 10},
+			bson.M{"$or": bson.A{
+				bson.M{"rank": bson.M{"$gt": 3.0}},
+				bson.M{"rank": 3.0, "tokenid": bson.M{"$gt": 10}},
+			}}},
+		{"descending breaks ties by ascending token id", PageCursor{SortField: "rarityscore", SortDir: -1, Value: 12.5, TokenId: 10},
+			bson.M{"$or": bson.A{
+				bson.M{"rarityscore": bson.M{"$lt": 12.5}},
+				bson.M{"rarityscore": 12.5, "tokenid": bson.M{"$gt": 10}},
+				bson.M{"rarityscore": nil},
+			}}},
+		{"ascending after null", PageCursor{SortField: "morphs", SortDir: 1, TokenId: 10},
+			bson.M{"$or": bson.A{
+				bson.M{"morphs": bson.M{"$ne": nil}},
+				bson.M{"morphs": nil, "tokenid": bson.M{"$gt": 10}},
+			}}},
+		{"descending after null", PageCursor{SortField: "morphs", SortDir: -1, TokenId: 10},
+			bson.M{"morphs": nil, "tokenid": bson.M{"$gt": 10}}},
+	}
+
+	for _, test := range tests {
+		if got := CursorFilter(test.cursor); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: got %v, expected %v", test.name, got, test.want)
+		}
+	}
+}
+
+// matchesCursor evaluates the cursor filters like MongoDB does: null matches missing and null values, $ne matches everything else and $gt and $lt only compare numbers
+func matchesCursor(doc bson.M, filter bson.M) bool {
+	for key, condition := range filter {
+		if key == "$or" {
+			matched := false
+			for _, branch := range condition.(bson.A) {
+				matched = matched || matchesCursor(doc, branch.(bson.M))
+			}
+			if !matched {
+				return false
+			}
+			continue
+		}
+		value, _ := numberValue(doc[key])
+		isNull := doc[key] == nil
+		operators, ok := condition.(bson.M)
+		if !ok {
+			operators = bson.M{"$eq": condition}
+		}
+		for op, operand := range operators {
+			expected, isNumber := numberValue(operand)
+			switch {
+			case op == "$eq" && operand == nil:
+				ok = isNull
+			case op == "$eq":
+				ok = !isNull && value == expected
+			case op == "$ne":
+				ok = !isNull
+			case op == "$gt":
+				ok = !isNull && isNumber && value > expected
+			case op == "$lt":
+				ok = !isNull && isNumber && value < expected
+			}
+			if !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func numberValue(v interface{}) (float64, bool) {
+	if f, ok := v.(float64); ok {
+		return f, true
+	}
+	n, ok := toInt(v)
+	return float64(n), ok
+}
+
+func TestCursorPagesWithNullValues(t *testing.T) {
+	// Polymorphs which were never morphed have no morphs counter
+	docs := []bson.M{
+		{"tokenid": 1, "morphs": 2},
+		{"tokenid": 2},
+		{"tokenid": 3, "morphs": 0},
+		{"tokenid": 4, "morphs": nil},
+		{"tokenid": 5, "morphs": 2},
+		{"tokenid": 6},
+		{"tokenid": 7, "morphs": 5},
+	}
+	// MongoDB sorts missing and null values before numbers
+	ascending := []int{2, 4, 6, 3, 1, 5, 7}
+	descending := []int{7, 1, 5, 3, 2, 4, 6}
+
+	for _, test := range []struct {
+		sortDir int
+		want    []int
+	}{{1, ascending}, {-1, descending}} {
+		for take := 1; take <= len(docs); take++ {
+			var got []int
+			filter := bson.M{}
+			for page := 0; page < len(docs); page++ {
+				var results []bson.M
+				for _, tokenId := range test.want {
+					doc := docs[tokenId-1]
+					if matchesCursor(doc, filter) && len(results) < take {
+						results = append(results, doc)
+					}
+				}
+				for _, result := range results {
+					got = append(got, result["tokenid"].(int))
+				}
+				if len(results) < take {
+					break
+				}
+				s, err := NextCursor(results[len(results)-1], "morphs", test.sortDir)
+				if err != nil {
+					t.Fatal(err)
+				}
+				cursor, err := DecodeCursor(s, "morphs", test.sortDir)
+				if err != nil {
+					t.Fatal(err)
+				}
+				filter = CursorFilter(cursor)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Direction %v with pages of %v: got %v, expected %v", test.sortDir, take, got, test.want)
+			}
+		}
+	}
+}
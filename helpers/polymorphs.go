@@ -96,20 +96,20 @@ func CreateMorphSnapshot(geneDiff int, tokenId string, newGene string, oldGene s
 	}
 
 	if geneDiff <= 2 {
-		changeType = "Morph"
+		changeType = constants.HISTORY_TYPE_MORPH
 		newAttrbiute = newAttr.Value
 		oldAttrubte = oldAttr.Value
 		newMorphCost = morphCost * 2
 	} else {
-		changeType = "Scramble"
+		changeType = constants.HISTORY_TYPE_SCRAMBLE
 		newAttrbiute = ""
 		oldAttrubte = ""
 		newMorphCost = config.SCRAMBLE_COST
 	}
 	morphCostMap[tokenId] = newMorphCost
 	g := metadata.Genome(newGene)
-	character := metadata.GetBaseGeneAttribute(newGene, configService)
-	genes := g.Genes()
+	character, _ := metadata.GetTraitAttribute(newGene, constants.MorphAttriutes.Character, configService)
+	genes := g.Genes(configService)
 	imageUrl := strings.Builder{}
 	imageUrl.WriteString(constants.POLYMORPH_IMAGE_URL)
 
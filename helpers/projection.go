@@ -0,0 +1,35 @@
+package helpers
+
+import (
+	"fmt"
+	"rarity-backend/config"
+	"rarity-backend/constants"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ParseSelectQueryString accepts a comma separated list of public polymorph fields and returns a projection which only includes them.
+//
+// The required fields are always included, e.g. the fields needed to create the page cursor.
+//
+// Example: tokenid,rank,rarityscore,imageurl
+//
+// Returns an error if a field isn't a public field of config.MORPH_FIELDS
+func ParseSelectQueryString(selectFields string, required ...string) (bson.M, error) {
+	projection := bson.M{constants.MorphFieldNames.ObjId: 0}
+	for _, name := range strings.Split(selectFields, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if _, ok := config.GetMorphField(name); !ok {
+			return nil, fmt.Errorf("Unknown select field %q", name)
+		}
+		projection[name] = 1
+	}
+	for _, name := range required {
+		projection[name] = 1
+	}
+	return projection, nil
+}
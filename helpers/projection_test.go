@@ -0,0 +1,41 @@
+package helpers
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestParseSelectQueryString(t *testing.T) {
+	tests := []struct {
+		name     string
+		fields   string
+		required []string
+		want     bson.M
+	}{
+		{"fields", "tokenid,rank", nil, bson.M{"_id": 0, "tokenid": 1, "rank": 1}},
+		{"spaces, case and empty entries", " TokenId , ,RarityScore,", nil, bson.M{"_id": 0, "tokenid": 1, "rarityscore": 1}},
+		{"empty", "", nil, bson.M{"_id": 0}},
+		{"required fields", "rank", []string{"tokenid", "rarityscore"}, bson.M{"_id": 0, "rank": 1, "tokenid": 1, "rarityscore": 1}},
+	}
+
+	for _, test := range tests {
+		got, err := ParseSelectQueryString(test.fields, test.required...)
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: got %v, expected %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestParseSelectQueryStringErrors(t *testing.T) {
+	for _, fields := range []string{"tokenid,color", "oldgenes", "_id", "rank;tokenid"} {
+		if _, err := ParseSelectQueryString(fields); err == nil {
+			t.Errorf("%q: expected an error", fields)
+		}
+	}
+}
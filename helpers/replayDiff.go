@@ -0,0 +1,156 @@
+package helpers
+
+import (
+	"fmt"
+	"rarity-backend/constants"
+	"rarity-backend/structs"
+	"reflect"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// DiffEntities compares every field but the rank of the live and replayed rarity entities with the same token id
+func DiffEntities(live []bson.M, replay []bson.M) structs.DocumentsDiff {
+	fields := func(doc bson.M) []string {
+		return fieldsExcept(doc, constants.MorphFieldNames.ObjId, constants.MorphFieldNames.Rank)
+	}
+	return diffDocuments(entitiesByTokenId(live), entitiesByTokenId(replay), fields)
+}
+
+// DiffRanks compares the ranks of the live and replayed rarity entities with the same token id
+func DiffRanks(live []bson.M, replay []bson.M) structs.DocumentsDiff {
+	fields := func(doc bson.M) []string {
+		return []string{constants.MorphFieldNames.Rank}
+	}
+	return diffDocuments(entitiesByTokenId(live), entitiesByTokenId(replay), fields)
+}
+
+// DiffHistory compares the live and replayed history snapshots by token id and time.
+//
+// Snapshots of the same token at the same time are matched in the order of their new gene.
+// Wallets aren't compared, because the snapshots recorded before wallets were tracked don't have one
+func DiffHistory(live []bson.M, replay []bson.M) structs.DocumentsDiff {
+	fields := func(doc bson.M) []string {
+		return fieldsExcept(doc, constants.HistoryFieldNames.ObjId, constants.HistoryFieldNames.Wallet)
+	}
+	return diffDocuments(snapshotsByTime(live), snapshotsByTime(replay), fields)
+}
+
+func entitiesByTokenId(docs []bson.M) map[string]bson.M {
+	result := make(map[string]bson.M, len(docs))
+	for _, doc := range docs {
+		result[fmt.Sprint(doc[constants.MorphFieldNames.TokenId])] = doc
+	}
+	return result
+}
+
+func snapshotsByTime(docs []bson.M) map[string]bson.M {
+	groups := make(map[string][]bson.M)
+	for _, doc := range docs {
+		key := fmt.Sprintf("%v@%v", doc[constants.HistoryFieldNames.TokenId], doc[constants.HistoryFieldNames.DateTime])
+		groups[key] = append(groups[key], doc)
+	}
+
+	result := make(map[string]bson.M, len(docs))
+	for key, group := range groups {
+		sort.SliceStable(group, func(i, j int) bool {
+			return fmt.Sprint(group[i][constants.HistoryFieldNames.NewGene]) < fmt.Sprint(group[j][constants.HistoryFieldNames.NewGene])
+		})
+		for i, doc := range group {
+			result[fmt.Sprintf("%v#%v", key, i+1)] = doc
+		}
+	}
+	return result
+}
+
+// diffDocuments compares the fields of the documents with the same key. The compared fields are the union of the fields of both documents
+func diffDocuments(live map[string]bson.M, replay map[string]bson.M, fields func(doc bson.M) []string) structs.DocumentsDiff {
+	diff := structs.DocumentsDiff{OnlyLive: []string{}, OnlyReplay: []string{}, Differences: []structs.FieldDifference{}}
+
+	for key := range live {
+		if _, ok := replay[key]; !ok {
+			diff.OnlyLive = append(diff.OnlyLive, key)
+		}
+	}
+	for key, replayDoc := range replay {
+		liveDoc, ok := live[key]
+		if !ok {
+			diff.OnlyReplay = append(diff.OnlyReplay, key)
+			continue
+		}
+
+		diff.Compared++
+		matching := true
+		for _, field := range unionFields(fields(liveDoc), fields(replayDoc)) {
+			if !valuesEqual(liveDoc[field], replayDoc[field]) {
+				matching = false
+				diff.Differences = append(diff.Differences, structs.FieldDifference{Key: key, Field: field, Live: liveDoc[field], Replay: replayDoc[field]})
+			}
+		}
+		if matching {
+			diff.Matching++
+		}
+	}
+
+	sort.Strings(diff.OnlyLive)
+	sort.Strings(diff.OnlyReplay)
+	sort.Slice(diff.Differences, func(i, j int) bool {
+		if diff.Differences[i].Key != diff.Differences[j].Key {
+			return diff.Differences[i].Key < diff.Differences[j].Key
+		}
+		return diff.Differences[i].Field < diff.Differences[j].Field
+	})
+	return diff
+}
+
+func fieldsExcept(doc bson.M, skipped ...string) []string {
+	var fields []string
+	for field := range doc {
+		skip := false
+		for _, s := range skipped {
+			skip = skip || field == s
+		}
+		if !skip {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+func unionFields(a []string, b []string) []string {
+	seen := make(map[string]bool)
+	var fields []string
+	for _, field := range append(a, b...) {
+		if !seen[field] {
+			seen[field] = true
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// valuesEqual compares numbers by value, so documents written with different integer sizes still match
+func valuesEqual(a interface{}, b interface{}) bool {
+	aNumber, aOk := toFloat(a)
+	bNumber, bOk := toFloat(b)
+	if aOk && bOk {
+		return aNumber == bNumber
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
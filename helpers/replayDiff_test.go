@@ -0,0 +1,116 @@
+package helpers
+
+import (
+	"rarity-backend/structs"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestDiffEntities(t *testing.T) {
+	tests := []struct {
+		name     string
+		live     []bson.M
+		replay   []bson.M
+		expected structs.DocumentsDiff
+	}{
+		{
+			"matching",
+			[]bson.M{{"_id": "a", "tokenid": int32(1), "rank": int32(3), "rarityscore": 10.5}},
+			[]bson.M{{"_id": "b", "tokenid": int64(1), "rank": int32(7), "rarityscore": 10.5}},
+			structs.DocumentsDiff{Compared: 1, Matching: 1, OnlyLive: []string{}, OnlyReplay: []string{}, Differences: []structs.FieldDifference{}},
+		},
+		{
+			"different and missing fields",
+			[]bson.M{{"tokenid": int32(1), "currentgene": "1", "morphs": int32(2)}},
+			[]bson.M{{"tokenid": int32(1), "currentgene": "2", "scrambles": int32(0)}},
+			structs.DocumentsDiff{Compared: 1, OnlyLive: []string{}, OnlyReplay: []string{}, Differences: []structs.FieldDifference{
+				{Key: "1", Field: "currentgene", Live: "1", Replay: "2"},
+				{Key: "1", Field: "morphs", Live: int32(2), Replay: nil},
+				{Key: "1", Field: "scrambles", Live: nil, Replay: int32(0)},
+			}},
+		},
+		{
+			"only in one side",
+			[]bson.M{{"tokenid": int32(2)}, {"tokenid": int32(1)}, {"tokenid": int32(3)}},
+			[]bson.M{{"tokenid": int32(3)}, {"tokenid": int32(4)}},
+			structs.DocumentsDiff{Compared: 1, Matching: 1, OnlyLive: []string{"1", "2"}, OnlyReplay: []string{"4"}, Differences: []structs.FieldDifference{}},
+		},
+		{
+			"arrays",
+			[]bson.M{{"tokenid": int32(1), "mainmatchingtraits": bson.A{"Hat", "Shoes"}}},
+			[]bson.M{{"tokenid": int32(1), "mainmatchingtraits": bson.A{"Hat"}}},
+			structs.DocumentsDiff{Compared: 1, OnlyLive: []string{}, OnlyReplay: []string{}, Differences: []structs.FieldDifference{
+				{Key: "1", Field: "mainmatchingtraits", Live: bson.A{"Hat", "Shoes"}, Replay: bson.A{"Hat"}},
+			}},
+		},
+	}
+
+	for _, test := range tests {
+		diff := DiffEntities(test.live, test.replay)
+		if !reflect.DeepEqual(diff, test.expected) {
+			t.Errorf("%v: got %+v, expected %+v", test.name, diff, test.expected)
+		}
+	}
+}
+
+func TestDiffRanks(t *testing.T) {
+	live := []bson.M{
+		{"tokenid": int32(1), "rank": int32(1), "rarityscore": 20.0},
+		{"tokenid": int32(2), "rank": int32(2), "rarityscore": 10.0},
+	}
+	replay := []bson.M{
+		{"tokenid": int32(1), "rank": int64(1), "rarityscore": 5.0},
+		{"tokenid": int32(2), "rank": int32(3), "rarityscore": 10.0},
+	}
+	expected := structs.DocumentsDiff{Compared: 2, Matching: 1, OnlyLive: []string{}, OnlyReplay: []string{}, Differences: []structs.FieldDifference{
+		{Key: "2", Field: "rank", Live: int32(2), Replay: int32(3)},
+	}}
+
+	diff := DiffRanks(live, replay)
+	if !reflect.DeepEqual(diff, expected) {
+		t.Errorf("Got %+v, expected %+v", diff, expected)
+	}
+}
+
+func TestDiffHistory(t *testing.T) {
+	snapshot := func(tokenId int32, datetime string, newGene string, price float64, wallet string) bson.M {
+		return bson.M{"_id": newGene + wallet, "tokenid": tokenId, "datetime": datetime, "newgene": newGene, "price": price, "wallet": wallet}
+	}
+
+	tests := []struct {
+		name     string
+		live     []bson.M
+		replay   []bson.M
+		expected structs.DocumentsDiff
+	}{
+		{
+			"wallets and ids aren't compared",
+			[]bson.M{snapshot(1, "t1", "11", 0.01, "")},
+			[]bson.M{snapshot(1, "t1", "11", 0.01, "0x1")},
+			structs.DocumentsDiff{Compared: 1, Matching: 1, OnlyLive: []string{}, OnlyReplay: []string{}, Differences: []structs.FieldDifference{}},
+		},
+		{
+			"snapshots at the same time are matched by new gene",
+			[]bson.M{snapshot(1, "t1", "22", 0.02, ""), snapshot(1, "t1", "11", 0.01, "")},
+			[]bson.M{snapshot(1, "t1", "11", 0.01, ""), snapshot(1, "t1", "22", 0.04, "")},
+			structs.DocumentsDiff{Compared: 2, Matching: 1, OnlyLive: []string{}, OnlyReplay: []string{}, Differences: []structs.FieldDifference{
+				{Key: "1@t1#2", Field: "price", Live: 0.02, Replay: 0.04},
+			}},
+		},
+		{
+			"extra snapshots",
+			[]bson.M{snapshot(1, "t1", "11", 0.01, ""), snapshot(2, "t1", "11", 0.01, "")},
+			[]bson.M{snapshot(1, "t1", "11", 0.01, ""), snapshot(1, "t1", "12", 0.01, ""), snapshot(1, "t2", "13", 0.01, "")},
+			structs.DocumentsDiff{Compared: 1, Matching: 1, OnlyLive: []string{"2@t1#1"}, OnlyReplay: []string{"1@t1#2", "1@t2#1"}, Differences: []structs.FieldDifference{}},
+		},
+	}
+
+	for _, test := range tests {
+		diff := DiffHistory(test.live, test.replay)
+		if !reflect.DeepEqual(diff, test.expected) {
+			t.Errorf("%v: got %+v, expected %+v", test.name, diff, test.expected)
+		}
+	}
+}
@@ -5,40 +5,60 @@ import (
 	"rarity-backend/config"
 	"rarity-backend/constants"
 	"strconv"
+	"strings"
 
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-// ParseSearchQueryString accepts search string as parameter and build a mongodb filter for each filter from the config
+// ParseSearchQueryString accepts search string as parameter and builds a mongodb filter for it.
+//
+// Numbers are matched exactly against the number fields from the config. Any other search is a $text query on the search index.
+// Every word of the search is expanded with the vocabulary into the trait and set words it's a prefix or a misspelling of.
+//
+// Returns true if the filter is a text query, which can be sorted by relevance. A search without any words matches nothing.
 //
 // Config can be found in config/apiConfig.go
-func ParseSearchQueryString(search string) bson.M {
-	queries := bson.A{}
+func ParseSearchQueryString(search string, vocabulary *SearchVocabulary) (bson.M, bool) {
+	search = strings.TrimSpace(search)
+	if query, ok := parseNumberSearch(search); ok {
+		return query, false
+	}
 
-	for _, field := range config.SEARCH_QUERY_FIELDS {
-		pattern := ""
+	var terms []string
+	seen := make(map[string]bool)
+	for _, word := range SearchWords(search) {
+		for _, term := range vocabulary.Expand(word) {
+			if !seen[term] {
+				seen[term] = true
+				terms = append(terms, term)
+			}
+		}
+	}
+	if len(terms) == 0 {
+		return bson.M{constants.MorphFieldNames.ObjId: bson.M{"$exists": false}}, false
+	}
+	// The words don't contain quotes or hyphens, so they can't be interpreted as phrases or negations by $text
+	return bson.M{"$text": bson.M{"$search": strings.Join(terms, " ")}}, true
+}
+
+// parseNumberSearch matches the search against the number fields. Returns false if the search isn't a number
+func parseNumberSearch(search string) (bson.M, bool) {
+	parsed, err := strconv.ParseFloat(search, 64)
+	if err != nil || math.IsNaN(parsed) || math.IsInf(parsed, 0) {
+		return nil, false
+	}
+
+	queries := bson.A{}
+	for _, field := range config.SEARCH_NUMBER_FIELDS {
 		switch field {
 		case constants.MorphFieldNames.TokenId,
 			constants.MorphFieldNames.Rank:
-			parsed, err := strconv.Atoi(search)
-			if err == nil {
-				queries = append(queries, bson.M{field: parsed})
+			if parsed == math.Trunc(parsed) {
+				queries = append(queries, bson.M{field: int64(parsed)})
 			}
 		case constants.MorphFieldNames.RarityScore:
-			parsed, err := strconv.ParseFloat(search, 64)
-			parsed = math.Floor(parsed*100) / 100
-			if err == nil {
-				queries = append(queries, bson.M{field: parsed})
-			}
-
-		default:
-			pattern = search
-			regex := primitive.Regex{Pattern: pattern, Options: "i"}
-			regexFilter := bson.M{"$regex": regex}
-			queries = append(queries, bson.M{field: regexFilter})
+			queries = append(queries, bson.M{field: math.Floor(parsed*100) / 100})
 		}
 	}
-	orQuery := bson.M{"$or": queries}
-	return orQuery
+	return bson.M{"$or": queries}, true
 }
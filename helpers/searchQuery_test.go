@@ -0,0 +1,79 @@
+package helpers
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var testVocabulary = NewSearchVocabulary([]string{"Golden Suit", "Spartan Helmet", "Golden Goggles", "Spartan Shield", "Ape"})
+
+func TestSearchVocabularyExpand(t *testing.T) {
+	tests := []struct {
+		word string
+		want []string
+	}{
+		{"golden", []string{"golden"}},
+		{"GOLDEN", []string{"golden"}},
+		{"spart", []string{"spartan"}},
+		{"go", []string{"goggles", "golden"}},
+		// Single letters are too short to be expanded as prefixes
+		{"s", []string{"s"}},
+		{"helmit", []string{"helmet"}},
+		{"spartin", []string{"spartan"}},
+		{"goldan", []string{"golden"}},
+		// A swap is two typos, more than a 7 letter word tolerates
+		{"spratan", []string{"spratan"}},
+		// Short words don't tolerate typos
+		{"apr", []string{"apr"}},
+		{"unknown", []string{"unknown"}},
+	}
+
+	for _, test := range tests {
+		if got := testVocabulary.Expand(test.word); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: got %v, expected %v", test.word, got, test.want)
+		}
+	}
+}
+
+func TestSearchVocabularyExpandLimit(t *testing.T) {
+	var phrases []string
+	for c := 'a'; c <= 'z'; c++ {
+		phrases = append(phrases, "set"+string(c))
+	}
+	if got := NewSearchVocabulary(phrases).Expand("set"); len(got) != SEARCH_MAX_EXPANSIONS {
+		t.Errorf("Got %v expansions, expected %v", len(got), SEARCH_MAX_EXPANSIONS)
+	}
+}
+
+func TestSearchWords(t *testing.T) {
+	got := SearchWords("Golden-Suit  (Spartan's) #7")
+	want := []string{"golden", "suit", "spartan", "s", "7"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Got %v, expected %v", got, want)
+	}
+}
+
+func TestParseSearchQueryString(t *testing.T) {
+	tests := []struct {
+		name   string
+		search string
+		want   bson.M
+		text   bool
+	}{
+		{"expands and deduplicates words", " spart golden Golden ", bson.M{"$text": bson.M{"$search": "spartan golden"}}, true},
+		{"splits on punctuation", `"golden"-suit`, bson.M{"$text": bson.M{"$search": "golden suit"}}, true},
+		{"integer", "42", bson.M{"$or": bson.A{bson.M{"rank": int64(42)}, bson.M{"tokenid": int64(42)}, bson.M{"rarityscore": float64(42)}}}, false},
+		{"decimal only matches the rarity score", "12.349", bson.M{"$or": bson.A{bson.M{"rarityscore": 12.34}}}, false},
+		{"no words matches nothing", "-!-", bson.M{"_id": bson.M{"$exists": false}}, false},
+		{"infinity is a word", "Inf", bson.M{"$text": bson.M{"$search": "inf"}}, true},
+	}
+
+	for _, test := range tests {
+		got, text := ParseSearchQueryString(test.search, testVocabulary)
+		if text != test.text || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: got %v (text %v), expected %v (text %v)", test.name, got, text, test.want, test.text)
+		}
+	}
+}
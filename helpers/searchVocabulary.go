@@ -0,0 +1,180 @@
+package helpers
+
+import (
+	"rarity-backend/structs"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const SEARCH_MAX_EXPANSIONS = 10
+const SEARCH_MIN_PREFIX_LENGTH = 2
+
+// SearchVocabulary contains every word of the trait names, set names and character names of a collection config.
+//
+// It expands partial and misspelled search words into words which exist in the text index
+type SearchVocabulary struct {
+	words []string
+	index map[string]bool
+}
+
+var vocabularyMutex sync.Mutex
+var vocabularies = map[*structs.ConfigService]*SearchVocabulary{}
+
+// GetSearchVocabulary returns the vocabulary of the config. It's built on first use
+func GetSearchVocabulary(configService *structs.ConfigService) *SearchVocabulary {
+	vocabularyMutex.Lock()
+	defer vocabularyMutex.Unlock()
+
+	if v, ok := vocabularies[configService]; ok {
+		return v
+	}
+
+	phrases := append([]string{}, configService.Character...)
+	for _, list := range configService.Traits {
+		for _, trait := range list {
+			phrases = append(phrases, trait.Name)
+			phrases = append(phrases, trait.Sets...)
+		}
+	}
+	v := NewSearchVocabulary(phrases)
+	vocabularies[configService] = v
+	return v
+}
+
+// NewSearchVocabulary creates a vocabulary of the words in the phrases
+func NewSearchVocabulary(phrases []string) *SearchVocabulary {
+	v := &SearchVocabulary{index: make(map[string]bool)}
+	for _, phrase := range phrases {
+		for _, word := range SearchWords(phrase) {
+			if !v.index[word] {
+				v.index[word] = true
+				v.words = append(v.words, word)
+			}
+		}
+	}
+	sort.Strings(v.words)
+	return v
+}
+
+// SearchWords splits the text into lower case words the same way the text index does, on every character which isn't a letter or digit
+func SearchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Expand returns the vocabulary words the search word can refer to.
+//
+// Words which exist are returned with every longer word they are a prefix of. Words which don't exist and aren't a prefix are matched to the closest words within the allowed number of typos.
+//
+// Returns the word itself if nothing matches
+func (v *SearchVocabulary) Expand(word string) []string {
+	word = strings.ToLower(word)
+	var matches []string
+	if v.index[word] {
+		matches = append(matches, word)
+	}
+
+	if len(word) >= SEARCH_MIN_PREFIX_LENGTH {
+		i := sort.SearchStrings(v.words, word)
+		for ; i < len(v.words) && strings.HasPrefix(v.words[i], word) && len(matches) < SEARCH_MAX_EXPANSIONS; i++ {
+			if v.words[i] != word {
+				matches = append(matches, v.words[i])
+			}
+		}
+	}
+	if len(matches) > 0 {
+		return matches
+	}
+
+	maxTypos := allowedTypos(word)
+	if maxTypos == 0 {
+		return []string{word}
+	}
+	type candidate struct {
+		word     string
+		distance int
+	}
+	var candidates []candidate
+	for _, w := range v.words {
+		if d := levenshtein(word, w, maxTypos); d <= maxTypos {
+			candidates = append(candidates, candidate{w, d})
+		}
+	}
+	if len(candidates) == 0 {
+		return []string{word}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].distance < candidates[j].distance })
+	for _, c := range candidates {
+		if len(matches) == SEARCH_MAX_EXPANSIONS {
+			break
+		}
+		matches = append(matches, c.word)
+	}
+	return matches
+}
+
+// allowedTypos returns the number of typos tolerated in a word. Short words would match too many unrelated words
+func allowedTypos(word string) int {
+	switch n := len([]rune(word)); {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// levenshtein returns the edit distance of the words. It stops early and returns max+1 once the distance exceeds max
+func levenshtein(a string, b string, max int) int {
+	ra, rb := []rune(a), []rune(b)
+	if abs(len(ra)-len(rb)) > max {
+		return max + 1
+	}
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		rowMin := curr[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = minInt(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			if curr[j] < rowMin {
+				rowMin = curr[j]
+			}
+		}
+		if rowMin > max {
+			return max + 1
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,53 @@
+package helpers
+
+import (
+	"rarity-backend/models"
+	"rarity-backend/structs"
+)
+
+// CreateTokenMetadata combines the gene metadata and the rarity information of the polymorph in marketplace compatible token metadata.
+//
+// Traits are string attributes, scores and counters are number attributes. Total is used as max value of the rank
+func CreateTokenMetadata(metadata structs.Metadata, entity models.PolymorphEntity, morphs int, scrambles int, total int64) structs.TokenMetadata {
+	attributes := make([]structs.TokenAttribute, 0, len(metadata.Attributes)+10)
+	for _, attr := range metadata.Attributes {
+		attributes = append(attributes, structs.TokenAttribute{TraitType: attr.TraitType, Value: attr.Value})
+	}
+
+	attributes = append(attributes,
+		structs.TokenAttribute{DisplayType: "number", TraitType: "Rarity Score", Value: entity.RarityScore},
+		structs.TokenAttribute{DisplayType: "number", TraitType: "Rank", Value: entity.Rank, MaxValue: total},
+		structs.TokenAttribute{TraitType: "Virgin", Value: yesNo(entity.IsVirgin)},
+		structs.TokenAttribute{TraitType: "Completed Set", Value: yesNo(entity.HasCompletedSet)},
+		structs.TokenAttribute{DisplayType: "number", TraitType: "Morphs", Value: morphs},
+		structs.TokenAttribute{DisplayType: "number", TraitType: "Scrambles", Value: scrambles},
+	)
+
+	if entity.MainSetName != "" {
+		attributes = append(attributes,
+			structs.TokenAttribute{TraitType: "Main Set", Value: entity.MainSetName},
+			structs.TokenAttribute{DisplayType: "number", TraitType: "Main Set Matching Traits", Value: len(entity.MainMatchingTraits)},
+		)
+	}
+	if entity.SecSetName != "" {
+		attributes = append(attributes,
+			structs.TokenAttribute{TraitType: "Secondary Set", Value: entity.SecSetName},
+			structs.TokenAttribute{DisplayType: "number", TraitType: "Secondary Set Matching Traits", Value: len(entity.SecMatchingTraits)},
+		)
+	}
+
+	return structs.TokenMetadata{
+		Name:        metadata.Name,
+		Description: metadata.Description,
+		Image:       metadata.Image,
+		ExternalUrl: metadata.ExternalUrl,
+		Attributes:  attributes,
+	}
+}
+
+func yesNo(value bool) string {
+	if value {
+		return "Yes"
+	}
+	return "No"
+}
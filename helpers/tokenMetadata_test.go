@@ -0,0 +1,86 @@
+package helpers
+
+import (
+	"rarity-backend/models"
+	"rarity-backend/structs"
+	"reflect"
+	"testing"
+)
+
+func TestCreateTokenMetadata(t *testing.T) {
+	metadata := structs.Metadata{
+		Name:        "Polymorph #7",
+		Description: "A polymorph",
+		Image:       "https://example.com/7.jpg",
+		ExternalUrl: "https://example.com/7",
+		Attributes: []structs.Attribute{
+			{TraitType: "Headwear", Value: "Golden Hat", Sets: []string{"Golden Suit"}},
+			{TraitType: "Character", Value: "Zombie"},
+		},
+	}
+	traits := []structs.TokenAttribute{
+		{TraitType: "Headwear", Value: "Golden Hat"},
+		{TraitType: "Character", Value: "Zombie"},
+	}
+
+	tests := []struct {
+		name       string
+		entity     models.PolymorphEntity
+		morphs     int
+		scrambles  int
+		attributes []structs.TokenAttribute
+	}{
+		{
+			"without sets",
+			models.PolymorphEntity{RarityScore: 12.5, Rank: 40, IsVirgin: true},
+			0, 0,
+			[]structs.TokenAttribute{
+				{DisplayType: "number", TraitType: "Rarity Score", Value: 12.5},
+				{DisplayType: "number", TraitType: "Rank", Value: 40, MaxValue: int64(10000)},
+				{TraitType: "Virgin", Value: "Yes"},
+				{TraitType: "Completed Set", Value: "No"},
+				{DisplayType: "number", TraitType: "Morphs", Value: 0},
+				{DisplayType: "number", TraitType: "Scrambles", Value: 0},
+			},
+		},
+		{
+			"with sets",
+			models.PolymorphEntity{
+				RarityScore:        240,
+				Rank:               1,
+				HasCompletedSet:    true,
+				MainSetName:        "Golden Suit",
+				MainMatchingTraits: []string{"Golden Hat", "Golden Shoes", "Golden Jacket"},
+				SecSetName:         "Party Degen",
+				SecMatchingTraits:  []string{"Bong"},
+			},
+			3, 2,
+			[]structs.TokenAttribute{
+				{DisplayType: "number", TraitType: "Rarity Score", Value: 240.0},
+				{DisplayType: "number", TraitType: "Rank", Value: 1, MaxValue: int64(10000)},
+				{TraitType: "Virgin", Value: "No"},
+				{TraitType: "Completed Set", Value: "Yes"},
+				{DisplayType: "number", TraitType: "Morphs", Value: 3},
+				{DisplayType: "number", TraitType: "Scrambles", Value: 2},
+				{TraitType: "Main Set", Value: "Golden Suit"},
+				{DisplayType: "number", TraitType: "Main Set Matching Traits", Value: 3},
+				{TraitType: "Secondary Set", Value: "Party Degen"},
+				{DisplayType: "number", TraitType: "Secondary Set Matching Traits", Value: 1},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		tokenMetadata := CreateTokenMetadata(metadata, test.entity, test.morphs, test.scrambles, 10000)
+		expected := structs.TokenMetadata{
+			Name:        metadata.Name,
+			Description: metadata.Description,
+			Image:       metadata.Image,
+			ExternalUrl: metadata.ExternalUrl,
+			Attributes:  append(append([]structs.TokenAttribute{}, traits...), test.attributes...),
+		}
+		if !reflect.DeepEqual(tokenMetadata, expected) {
+			t.Errorf("%v: got %+v, expected %+v", test.name, tokenMetadata, expected)
+		}
+	}
+}
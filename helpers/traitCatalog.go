@@ -0,0 +1,105 @@
+package helpers
+
+import (
+	"rarity-backend/structs"
+	"sort"
+	"strings"
+)
+
+const SUGGESTION_KIND_TRAIT = "trait"
+const SUGGESTION_KIND_SET = "set"
+
+// BuildTraitCatalog lists the values of every trait type of the genome layout and every set referenced by the traits or the rarity model.
+//
+// Only the first Count values of a list can be decoded from a gene, so the rest of the list isn't part of the catalog
+func BuildTraitCatalog(configService *structs.ConfigService, rarityModel structs.RarityModel) structs.TraitCatalog {
+	catalog := structs.TraitCatalog{Types: []structs.TraitTypeCatalog{}, Sets: []structs.SetCatalog{}}
+	setNames := make(map[string]bool)
+
+	for _, slot := range configService.Decoder.Layout() {
+		traitType := structs.TraitTypeCatalog{TraitType: slot.TraitType, Values: []structs.TraitValue{}}
+		seen := make(map[string]bool)
+		list := configService.Traits[slot.List]
+		for i := 0; i < slot.Count && i < len(list); i++ {
+			trait := list[i]
+			if seen[trait.Name] {
+				continue
+			}
+			seen[trait.Name] = true
+
+			sets := trait.Sets
+			if sets == nil {
+				sets = []string{}
+			}
+			traitType.Values = append(traitType.Values, structs.TraitValue{Value: trait.Name, Sets: sets})
+			for _, set := range sets {
+				setNames[set] = true
+			}
+		}
+		catalog.Types = append(catalog.Types, traitType)
+	}
+
+	for set := range rarityModel.CombosMap {
+		setNames[set] = true
+	}
+	for set := range setNames {
+		hands := rarityModel.HandsMap[set]
+		if hands == nil {
+			hands = []string{}
+		}
+		catalog.Sets = append(catalog.Sets, structs.SetCatalog{Name: set, Size: rarityModel.CombosMap[set], Hands: hands})
+	}
+	sort.Slice(catalog.Sets, func(i, j int) bool { return catalog.Sets[i].Name < catalog.Sets[j].Name })
+
+	return catalog
+}
+
+// SuggestTraits returns the trait values and sets of the catalog which have a word starting with the prefix.
+//
+// Names starting with the prefix come first, then the rest alphabetically. At most limit suggestions are returned
+func SuggestTraits(catalog structs.TraitCatalog, prefix string, limit int) []structs.TraitSuggestion {
+	prefix = strings.ToLower(strings.TrimSpace(prefix))
+	suggestions := []structs.TraitSuggestion{}
+	if prefix == "" {
+		return suggestions
+	}
+
+	for _, traitType := range catalog.Types {
+		for _, value := range traitType.Values {
+			if matchesPrefix(value.Value, prefix) {
+				suggestions = append(suggestions, structs.TraitSuggestion{Text: value.Value, Kind: SUGGESTION_KIND_TRAIT, TraitType: traitType.TraitType})
+			}
+		}
+	}
+	for _, set := range catalog.Sets {
+		if matchesPrefix(set.Name, prefix) {
+			suggestions = append(suggestions, structs.TraitSuggestion{Text: set.Name, Kind: SUGGESTION_KIND_SET})
+		}
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		a, b := strings.ToLower(suggestions[i].Text), strings.ToLower(suggestions[j].Text)
+		aStarts, bStarts := strings.HasPrefix(a, prefix), strings.HasPrefix(b, prefix)
+		if aStarts != bStarts {
+			return aStarts
+		}
+		return a < b
+	})
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions
+}
+
+// matchesPrefix checks if the name or one of its words starts with the lower case prefix
+func matchesPrefix(name string, prefix string) bool {
+	if strings.HasPrefix(strings.ToLower(name), prefix) {
+		return true
+	}
+	for _, word := range SearchWords(name) {
+		if strings.HasPrefix(word, prefix) {
+			return true
+		}
+	}
+	return false
+}
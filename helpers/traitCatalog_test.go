@@ -0,0 +1,52 @@
+package helpers
+
+import (
+	"rarity-backend/structs"
+	"reflect"
+	"testing"
+)
+
+var testCatalog = structs.TraitCatalog{
+	Types: []structs.TraitTypeCatalog{
+		{TraitType: "Headwear", Values: []structs.TraitValue{{Value: "Spartan Helmet"}, {Value: "Golden Crown"}, {Value: "Sombrero"}}},
+		{TraitType: "Torso", Values: []structs.TraitValue{{Value: "Golden Suit"}, {Value: "Silk Shirt"}}},
+	},
+	Sets: []structs.SetCatalog{{Name: "Golden Suit Set"}, {Name: "Spartan"}},
+}
+
+func TestSuggestTraits(t *testing.T) {
+	tests := []struct {
+		prefix string
+		limit  int
+		want   []string
+	}{
+		// Names starting with the prefix come before names with a later word starting with it
+		{"s", 10, []string{"Silk Shirt", "Sombrero", "Spartan", "Spartan Helmet", "Golden Suit", "Golden Suit Set"}},
+		{" GOLD ", 10, []string{"Golden Crown", "Golden Suit", "Golden Suit Set"}},
+		{"su", 10, []string{"Golden Suit", "Golden Suit Set"}},
+		{"s", 3, []string{"Silk Shirt", "Sombrero", "Spartan"}},
+		{"x", 10, []string{}},
+		{"", 10, []string{}},
+	}
+
+	for _, test := range tests {
+		got := []string{}
+		for _, suggestion := range SuggestTraits(testCatalog, test.prefix, test.limit) {
+			got = append(got, suggestion.Text)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: got %v, expected %v", test.prefix, got, test.want)
+		}
+	}
+}
+
+func TestSuggestTraitsKinds(t *testing.T) {
+	got := SuggestTraits(testCatalog, "spartan", 10)
+	want := []structs.TraitSuggestion{
+		{Text: "Spartan", Kind: SUGGESTION_KIND_SET},
+		{Text: "Spartan Helmet", Kind: SUGGESTION_KIND_TRAIT, TraitType: "Headwear"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Got %+v, expected %+v", got, want)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"rarity-backend/config"
+	"rarity-backend/handlers"
+	"rarity-backend/metadata"
+	"rarity-backend/structs"
+
+	"github.com/joho/godotenv"
+)
+
+// prerender renders the image variants of the current gene of every polymorph into the image cache dir of its collection.
+//
+// Usage: rarity-backend prerender [-sizes 256,512,1024] [-formats jpeg,png,webp]
+//
+// Variants which are already cached are skipped, so the command can be interrupted and run again. IMAGE_CACHE_DISK_MB must be large enough for all variants of a collection, otherwise the oldest ones are evicted.
+func prerender(args []string) {
+	flags := flag.NewFlagSet("prerender", flag.ExitOnError)
+	sizesFlag := flags.String("sizes", "256,512,1024", "comma separated image sizes")
+	formatsFlag := flags.String("formats", "jpeg,png,webp", "comma separated image formats")
+	flags.Parse(args)
+
+	err := godotenv.Load()
+	if err != nil {
+		log.Fatal("Error loading .env file: " + err.Error())
+	}
+	var sizes []int
+	for _, s := range strings.Split(*sizesFlag, ",") {
+		size, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || !metadata.ValidRenderSize(size) {
+			log.Fatalf("Invalid size %v, supported sizes are %v", s, metadata.RENDER_SIZES)
+		}
+		sizes = append(sizes, size)
+	}
+	formats := strings.Split(*formatsFlag, ",")
+	for i, format := range formats {
+		formats[i] = strings.TrimSpace(format)
+		if _, ok := metadata.ImageContentTypes[formats[i]]; !ok {
+			log.Fatalf("Invalid format %v, supported formats are jpeg, png and webp", format)
+		}
+	}
+
+	for _, collection := range config.NewCollectionsConfig() {
+		if collection.Images.CacheDir == "" {
+			log.Fatalf("The image cache dir of collection %v must be set to prerender images", collection.Name)
+		}
+		generator := newCommandImageGenerator(collection)
+		configService := config.NewConfigService(collection.ConfigPath)
+		genes, err := handlers.GetCurrentGenes(collection.DBInfo.PolymorphDBName, collection.DBInfo.RarityCollectionName)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		// Different genes can share the same image
+		seen := make(map[string]bool)
+		rendered, failed := 0, 0
+		for _, gene := range genes {
+			paths, err := configService.Decoder.Paths(gene)
+			if err != nil {
+				log.Printf("Skipping invalid gene %v: %v", gene, err)
+				failed++
+				continue
+			}
+			key := strings.Join(paths, "")
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			n, err := generator.Prerender(paths, sizes, formats)
+			rendered += n
+			if err != nil {
+				log.Printf("Failed to render gene %v: %v", gene, err)
+				failed++
+			}
+		}
+		log.Printf("Prerendered %v images of %v genes in collection %v, %v failed", rendered, len(seen), collection.Name, failed)
+	}
+}
+
+// replayRenders renders the images of the failed render queue of every collection again.
+//
+// Usage: rarity-backend replay-renders
+func replayRenders() {
+	err := godotenv.Load()
+	if err != nil {
+		log.Fatal("Error loading .env file: " + err.Error())
+	}
+
+	for _, collection := range config.NewCollectionsConfig() {
+		generator := newCommandImageGenerator(collection)
+		if !setFailedRenderQueue(generator, collection.DBInfo) {
+			log.Fatal("FAILED_RENDERS_COLLECTION must be set to replay renders")
+		}
+
+		rendered, failed, err := generator.ReplayFailedRenders()
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Rendered %v images from the failed render queue of collection %v, %v still failing", rendered, collection.Name, failed)
+	}
+}
+
+// newCommandImageGenerator creates the image generator of the collection for the image commands, which can't run with rendering disabled
+func newCommandImageGenerator(collection structs.Collection) *metadata.ImageGenerator {
+	generator, err := metadata.NewImageGeneratorFromEnv(collection.Images)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if generator == nil {
+		log.Fatal("IMAGE_STORAGE must be set to render images")
+	}
+	return generator
+}
+
+// setFailedRenderQueue records the failed renders of the generator in FAILED_RENDERS_COLLECTION of the collection's database.
+//
+// Returns false if rendering or the failed render queue is disabled
+func setFailedRenderQueue(generator *metadata.ImageGenerator, dbInfo structs.DBInfo) bool {
+	collectionName := os.Getenv("FAILED_RENDERS_COLLECTION")
+	if generator == nil || collectionName == "" {
+		return false
+	}
+
+	generator.SetFailedRenderQueue(handlers.FailedRenderQueue{
+		PolymorphDBName:         dbInfo.PolymorphDBName,
+		FailedRendersCollection: collectionName,
+	})
+	return true
+}
@@ -2,19 +2,26 @@ package main
 
 import (
 	"log"
+	"math/big"
 	"os"
 	"strings"
 
+	"rarity-backend/cache"
 	"rarity-backend/config"
 	"rarity-backend/dlt"
+	"rarity-backend/events"
 	"rarity-backend/handlers"
+	"rarity-backend/metadata"
+	"rarity-backend/repositories"
 	"rarity-backend/services"
 	"rarity-backend/store"
 	"rarity-backend/structs"
+	"rarity-backend/webhooks"
 
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/gofiber/fiber"
+	"github.com/graphql-go/graphql"
 	"github.com/jasonlvhit/gocron"
 	"github.com/joho/godotenv"
 )
@@ -34,10 +41,36 @@ func connectToEthereum() *dlt.EthereumClient {
 	return client
 }
 
-// initResources is a wrapper function which tries to initialize all .env variables, contract abi, new contract instance.
+// collectionResources contains everything the polling process needs in order to index a single collection
+type collectionResources struct {
+	collection    structs.Collection
+	chain         dlt.Chain
+	configService *structs.ConfigService
+	rarityModel   structs.RarityModel
+	repositories  repositories.Repositories
+	eventHub      *events.Hub
+	// imageGenerator renders the images of new genes. It's nil if IMAGE_STORAGE isn't set
+	imageGenerator *metadata.ImageGenerator
+	// webhookDispatcher is nil if webhooks aren't enabled for the collection
+	webhookDispatcher *webhooks.Dispatcher
+}
+
+// imageGenerators contains the image generator of each collection by collection name. It's only written by initResources and empty if IMAGE_STORAGE isn't set
+var imageGenerators = map[string]*metadata.ImageGenerator{}
+
+// responseCache caches the responses of the read endpoints. It's nil if RESPONSE_CACHE_ENTRIES is 0
+var responseCache *cache.ResponseCache
+
+// eventHubs publishes the live feed of each collection by collection name. It's only written by initResources
+var eventHubs = map[string]*events.Hub{}
+
+// chains contains the chain of each collection by collection name. It's only written by initResources
+var chains = map[string]dlt.Chain{}
+
+// initResources is a wrapper function which tries to initialize all .env variables, contract abi, new contract instance for each collection.
 //
 // It connects to the ethereum client and returns all information which will be needed at some point from the application
-func initResources() (*dlt.EthereumClient, abi.ABI, *store.Store, string, *structs.ConfigService, structs.DBInfo) {
+func initResources() (abi.ABI, []collectionResources) {
 	// Load env variables
 	err := godotenv.Load()
 	if err != nil {
@@ -46,108 +79,188 @@ func initResources() (*dlt.EthereumClient, abi.ABI, *store.Store, string, *struc
 
 	// Inital step: Recover to be up to date
 	ethClient := connectToEthereum()
-	polymorphDBName := os.Getenv("POLYMORPH_DB")
-	rarityCollectionName := os.Getenv("RARITY_COLLECTION")
-	blocksCollectionName := os.Getenv("BLOCKS_COLLECTION")
-	contractAddress := os.Getenv("CONTRACT_ADDRESS")
-	transactionsCollectionName := os.Getenv("TRANSACTIONS_COLLECTION")
-	historyCollectionName := os.Getenv("HISTORY_COLLECTION")
-	morphCostCollectionName := os.Getenv("MORPH_COST_COLLECTION")
-
-	if contractAddress == "" {
-		log.Fatal("Missing contract address in .env")
-	}
-	if polymorphDBName == "" {
-		log.Fatal("Missing polymorph db name in .env")
-	}
-	if rarityCollectionName == "" {
-		log.Fatal("Missing rarity collection name in .env")
-	}
-	if blocksCollectionName == "" {
-		log.Fatal("Missing block collection name in .env")
-	}
-	if transactionsCollectionName == "" {
-		log.Fatal("Missing transactions collection name in .env")
-	}
-	if historyCollectionName == "" {
-		log.Fatal("Missing morph history collection name in .env")
-	}
-	if morphCostCollectionName == "" {
-		log.Fatal("Missing morph cost collection name in .env")
-	}
 
 	contractAbi, err := abi.JSON(strings.NewReader(string(store.StoreABI)))
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	instance, err := store.NewStore(common.HexToAddress(contractAddress), ethClient.Client)
-	if err != nil {
-		log.Fatalln(err)
+	responseCache = cache.NewFromEnv()
+
+	var resources []collectionResources
+	for _, collection := range config.NewCollectionsConfig() {
+		chain, err := dlt.NewContractChain(ethClient.Client, common.HexToAddress(collection.ContractAddress))
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		imageGenerator, err := metadata.NewImageGeneratorFromEnv(collection.Images)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if imageGenerator != nil {
+			imageGenerators[collection.Name] = imageGenerator
+			setFailedRenderQueue(imageGenerator, collection.DBInfo)
+		}
+
+		collection.ConfigService = config.NewConfigService(collection.ConfigPath)
+		chains[collection.Name] = chain
+		eventHubs[collection.Name] = events.NewHub(config.FEED_HISTORY_SIZE, config.FEED_SUBSCRIBER_BUFFER)
+		config.RegisterCollection(collection)
+		resources = append(resources, collectionResources{
+			collection:        collection,
+			chain:             chain,
+			configService:     collection.ConfigService,
+			rarityModel:       config.RarityModels[collection.RarityModel],
+			repositories:      repositories.NewMongoRepositories(collection.DBInfo),
+			eventHub:          eventHubs[collection.Name],
+			imageGenerator:    imageGenerator,
+			webhookDispatcher: newWebhookDispatcher(collection.DBInfo),
+		})
 	}
 
-	configService := config.NewConfigService("./config.json")
-	dbInfo := structs.DBInfo{
-		PolymorphDBName:            polymorphDBName,
-		RarityCollectionName:       rarityCollectionName,
-		TransactionsCollectionName: transactionsCollectionName,
-		BlocksCollectionName:       blocksCollectionName,
-		HistoryCollectionName:      historyCollectionName,
-		MorphCostCollectionName:    morphCostCollectionName,
-	}
-	return ethClient, contractAbi, instance, contractAddress, configService, dbInfo
+	return contractAbi, resources
 }
 
 // main is the entry point of the application.
-// It fetches all configurations and starts 2 concurrent processes:
+// It fetches all configurations and starts concurrent processes:
 //
 // 1. API which handles GET requests
 //
-// 2. Polling process which processes mint and morph events and stores their metadata in the database
+// 2. Polling process for each collection which processes mint and morph events and stores their metadata in the database
+//
+// The prerender and replay-renders commands render images instead, see imageCommands.go.
+// The replay command reprocesses a block range into a scratch database, see replayCommand.go.
+// The verify command checks the stored polymorphs against the chain, see verifyCommand.go.
 func main() {
-	ethClient,
-		contractAbi,
-		instance,
-		contractAddress,
-		configService,
-		dbInfo := initResources()
-
-	go recoverAndPoll(
-		ethClient,
-		contractAbi,
-		instance,
-		contractAddress,
-		configService,
-		dbInfo)
+	if len(os.Args) > 1 && os.Args[1] == "prerender" {
+		prerender(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "replay-renders" {
+		replayRenders()
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		replay(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "verify" {
+		verify(os.Args[2:])
+		return
+	}
+
+	contractAbi, resources := initResources()
+
+	for _, res := range resources {
+		go recoverAndPoll(contractAbi, res)
+	}
 
 	startAPI()
 }
 
 // startAPI registers the endpoints for API and listens for requests
 // API has moved to a cloud function due to bad response times
+//
+// The routes without collection parameter serve the first configured collection.
 func startAPI() {
 	// Routine two: API -> Should start after deploy?
 	app := fiber.New()
-	// app.Get("/morphs/", handlers.GetPolymorphs)
-	// app.Get("/morphs/:id", handlers.GetPolymorphById)
-	// app.Get("/morphs/history/:id", handlers.GetPolymorphHistory)
+	schema, err := handlers.NewGraphQLSchema(ownerOf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	webhookApiKeys := config.WebhookApiKeysFromEnv()
+	registerRoutes(app, schema, webhookApiKeys)
+	registerRoutes(app.Group("/collections/:collection"), schema, webhookApiKeys)
+	ensureApiIndexes()
 	log.Fatal(app.Listen(8000))
 }
 
-// recoverAndPoll loads transactions and morph cost state in memory from the database and initiates polling mechanism.
+// ensureApiIndexes creates the indexes read by the search and activity endpoints for every collection served by the API
+func ensureApiIndexes() {
+	for _, collection := range config.GetCollections() {
+		if err := handlers.EnsureSearchIndex(collection.DBInfo.PolymorphDBName, collection.DBInfo.RarityCollectionName); err != nil {
+			log.Println("Could not create the search index: " + err.Error())
+		}
+		if err := handlers.EnsureActivityIndex(collection.DBInfo.PolymorphDBName, collection.DBInfo.HistoryCollectionName); err != nil {
+			log.Println("Could not create the activity index: " + err.Error())
+		}
+	}
+}
+
+// registerRoutes registers the polymorph endpoints to the passed router
+func registerRoutes(router fiber.Router, schema graphql.Schema, webhookApiKeys []string) {
+	router.Get("/morphs/", handlers.GetPolymorphs(responseCache))
+	router.Get("/morphs/:id", handlers.GetPolymorphById(responseCache))
+	router.Get("/morphs/history/:id", handlers.GetPolymorphHistory(responseCache))
+	router.Get("/activity", handlers.GetActivity)
+	router.Get("/economics", handlers.GetMorphEconomics(responseCache))
+	router.Get("/economics/daily", handlers.GetDailyMorphStats(responseCache))
+	router.Get("/economics/tokens", handlers.GetTopMorphTokens(responseCache))
+	router.Get("/economics/wallets", handlers.GetTopMorphWallets(responseCache))
+	router.Get("/economics/traits", handlers.GetTraitSlotMorphs(responseCache))
+	router.Get("/metadata/:id", handlers.GetTokenMetadata)
+	router.Get("/traits/", handlers.GetTraitCatalog)
+	router.Get("/traits/autocomplete", handlers.GetTraitSuggestions)
+	router.Get("/images/gene/:gene", handlers.GetGeneImage(imageGenerators))
+	router.Get("/images/:id", handlers.GetTokenImage(imageGenerators))
+	router.Get("/graphql", handlers.GraphQL(schema))
+	router.Post("/graphql", handlers.GraphQL(schema))
+	router.Get("/feed", handlers.GetFeed(eventHubs))
+	router.Post("/webhooks", handlers.CreateWebhook(webhookApiKeys))
+	router.Get("/webhooks/:id/deliveries", handlers.GetWebhookDeliveries)
+	router.Delete("/webhooks/:id", handlers.DeleteWebhook)
+}
+
+// newWebhookDispatcher creates the webhook dispatcher of the collection. Returns nil if webhooks aren't enabled for it
+func newWebhookDispatcher(dbInfo structs.DBInfo) *webhooks.Dispatcher {
+	if dbInfo.WebhooksCollectionName == "" {
+		return nil
+	}
+	return webhooks.NewDispatcher(handlers.WebhookStore{
+		PolymorphDBName:             dbInfo.PolymorphDBName,
+		WebhooksCollection:          dbInfo.WebhooksCollectionName,
+		WebhookDeliveriesCollection: dbInfo.WebhookDeliveriesCollectionName,
+	}, webhooks.DEFAULT_WORKERS)
+}
+
+// ownerOf looks up the owner of the token in the contract of the collection
+func ownerOf(collection structs.Collection, tokenId int) (string, error) {
+	chain, ok := chains[collection.Name]
+	if !ok {
+		return "", nil
+	}
+	owner, err := chain.OwnerOf(big.NewInt(int64(tokenId)))
+	if err != nil {
+		return "", err
+	}
+	return owner.Hex(), nil
+}
+
+// recoverAndPoll loads transactions and morph cost state in memory from the database and initiates polling mechanism for a single collection.
 //
 // Recovery function and polling function is the same.
 // Currently the polling timer doesn't wait for the previous one to finish before starting the new countdown
-func recoverAndPoll(ethClient *dlt.EthereumClient, contractAbi abi.ABI, store *store.Store, contractAddress string, configService *structs.ConfigService, dbInfo structs.DBInfo) {
+func recoverAndPoll(contractAbi abi.ABI, res collectionResources) {
+	dbInfo := res.collection.DBInfo
+	address := res.collection.ContractAddress
 	// Build transactions scramble transaction mapping from db
-	txMap := handlers.GetTransactionsMapping(dbInfo.PolymorphDBName, dbInfo.TransactionsCollectionName)
+	txMap, err := res.repositories.Transactions.Processed()
+	if err != nil {
+		log.Fatalln(err)
+	}
 	// Build polymorph cost mapping from db
-	morphCostMap := handlers.GetMorphPriceMapping(dbInfo.PolymorphDBName, dbInfo.HistoryCollectionName)
+	morphCostMap, err := res.repositories.MorphCosts.Prices()
+	if err != nil {
+		log.Fatalln(err)
+	}
 	// Recover immediately
-	services.RecoverProcess(ethClient, contractAbi, store, contractAddress, configService, dbInfo, txMap, morphCostMap)
-	// Routine one: Start polling after recovery
-	gocron.Every(15).Second().Do(services.RecoverProcess, ethClient, contractAbi, store, contractAddress, configService, dbInfo, txMap, morphCostMap)
-	<-gocron.Start()
+	services.RecoverProcess(res.chain, contractAbi, address, res.configService, res.rarityModel, dbInfo, res.repositories, txMap, morphCostMap, res.imageGenerator, responseCache, res.eventHub, res.webhookDispatcher)
+	// Routine one: Start polling after recovery. Each collection has its own scheduler
+	scheduler := gocron.NewScheduler()
+	scheduler.Every(15).Second().Do(services.RecoverProcess, res.chain, contractAbi, address, res.configService, res.rarityModel, dbInfo, res.repositories, txMap, morphCostMap, res.imageGenerator, responseCache, res.eventHub, res.webhookDispatcher)
+	<-scheduler.Start()
 }
 
 // func main() {
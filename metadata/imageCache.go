@@ -0,0 +1,204 @@
+package metadata
+
+import (
+	"container/list"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ImageCache keeps rendered image variants in memory and optionally on disk.
+//
+// Both caches evict the least recently used images once their size limit is exceeded. The disk cache restores its order from the modification times of the files on start
+type ImageCache struct {
+	dir          string
+	maxBytes     int
+	maxDiskBytes int64
+
+	mutex   sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+
+	diskSize    int64
+	diskOrder   *list.List
+	diskEntries map[string]*list.Element
+}
+
+type imageCacheEntry struct {
+	key  string
+	data []byte
+}
+
+type diskCacheEntry struct {
+	key  string
+	size int64
+}
+
+// NewImageCache creates the cache directory if it's set and indexes the images already stored in it. An empty dir disables the disk cache
+func NewImageCache(dir string, maxBytes int, maxDiskBytes int64) (*ImageCache, error) {
+	cache := &ImageCache{
+		dir:          dir,
+		maxBytes:     maxBytes,
+		maxDiskBytes: maxDiskBytes,
+		order:        list.New(),
+		entries:      make(map[string]*list.Element),
+		diskOrder:    list.New(),
+		diskEntries:  make(map[string]*list.Element),
+	}
+	if dir == "" {
+		return cache, nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].ModTime().Before(files[j].ModTime()) })
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		// Temporary files are left behind by interrupted writes
+		if strings.HasSuffix(file.Name(), ".tmp") {
+			os.Remove(filepath.Join(dir, file.Name()))
+			continue
+		}
+		cache.touchDisk(file.Name(), file.Size())
+	}
+	cache.removeFiles(cache.evictDisk())
+	return cache, nil
+}
+
+// Get returns the cached image. Images found only on disk are added to the memory cache
+func (c *ImageCache) Get(key string) ([]byte, bool) {
+	c.mutex.Lock()
+	if el, ok := c.entries[key]; ok {
+		c.order.MoveToFront(el)
+		c.mutex.Unlock()
+		return el.Value.(*imageCacheEntry).data, true
+	}
+	c.mutex.Unlock()
+
+	if c.dir == "" {
+		return nil, false
+	}
+	data, err := ioutil.ReadFile(filepath.Join(c.dir, key))
+	if err != nil {
+		return nil, false
+	}
+	// The image might have been written by another process, e.g. the prerender command
+	c.mutex.Lock()
+	c.touchDisk(key, int64(len(data)))
+	c.mutex.Unlock()
+
+	c.putMemory(key, data)
+	return data, true
+}
+
+// Put stores the image in memory and on disk. The disk file is written to a temporary file first so readers never see partial images
+func (c *ImageCache) Put(key string, data []byte) error {
+	c.putMemory(key, data)
+
+	if c.dir == "" {
+		return nil
+	}
+	tmp, err := ioutil.TempFile(c.dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err = os.Rename(tmp.Name(), filepath.Join(c.dir, key)); err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	c.touchDisk(key, int64(len(data)))
+	evicted := c.evictDisk()
+	c.mutex.Unlock()
+
+	c.removeFiles(evicted)
+	return nil
+}
+
+// Contains returns whether the image is cached without loading it from disk
+func (c *ImageCache) Contains(key string) bool {
+	c.mutex.Lock()
+	_, inMemory := c.entries[key]
+	_, onDisk := c.diskEntries[key]
+	c.mutex.Unlock()
+	if inMemory || onDisk || c.dir == "" {
+		return inMemory || onDisk
+	}
+	_, err := os.Stat(filepath.Join(c.dir, key))
+	return err == nil
+}
+
+func (c *ImageCache) putMemory(key string, data []byte) {
+	if len(data) > c.maxBytes {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.size -= len(el.Value.(*imageCacheEntry).data)
+		c.order.Remove(el)
+	}
+	c.entries[key] = c.order.PushFront(&imageCacheEntry{key: key, data: data})
+	c.size += len(data)
+
+	for c.size > c.maxBytes {
+		oldest := c.order.Back()
+		entry := oldest.Value.(*imageCacheEntry)
+		c.order.Remove(oldest)
+		delete(c.entries, entry.key)
+		c.size -= len(entry.data)
+	}
+}
+
+// touchDisk marks the disk file as most recently used. Must be called with the mutex held
+func (c *ImageCache) touchDisk(key string, size int64) {
+	if el, ok := c.diskEntries[key]; ok {
+		c.diskSize -= el.Value.(*diskCacheEntry).size
+		c.diskOrder.Remove(el)
+	}
+	c.diskEntries[key] = c.diskOrder.PushFront(&diskCacheEntry{key: key, size: size})
+	c.diskSize += size
+}
+
+// evictDisk removes the least recently used files from the index until the disk cache fits its limit and returns their keys.
+// Must be called with the mutex held, the files are removed by removeFiles
+func (c *ImageCache) evictDisk() []string {
+	var evicted []string
+	for c.diskSize > c.maxDiskBytes {
+		oldest := c.diskOrder.Back()
+		entry := oldest.Value.(*diskCacheEntry)
+		c.diskOrder.Remove(oldest)
+		delete(c.diskEntries, entry.key)
+		c.diskSize -= entry.size
+		evicted = append(evicted, entry.key)
+	}
+	return evicted
+}
+
+func (c *ImageCache) removeFiles(keys []string) {
+	for _, key := range keys {
+		os.Remove(filepath.Join(c.dir, key))
+	}
+}
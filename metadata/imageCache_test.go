@@ -0,0 +1,71 @@
+package metadata
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestImageCacheEvictsDisk(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := NewImageCache(dir, 0, 25)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"a.jpg", "b.jpg"} {
+		if err := cache.Put(key, bytes.Repeat([]byte{1}, 10)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Reading a makes b the least recently used image
+	if _, ok := cache.Get("a.jpg"); !ok {
+		t.Fatal("Expected a.jpg to be cached")
+	}
+	if err := cache.Put("c.jpg", bytes.Repeat([]byte{1}, 10)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "b.jpg")); !os.IsNotExist(err) {
+		t.Errorf("Expected b.jpg to be evicted, got %v", err)
+	}
+	for _, key := range []string{"a.jpg", "c.jpg"} {
+		if !cache.Contains(key) {
+			t.Errorf("Expected %v to stay cached", key)
+		}
+	}
+}
+
+func TestImageCacheIndexesExistingFiles(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	for i, key := range []string{"old.jpg", "new.jpg"} {
+		path := filepath.Join(dir, key)
+		if err := ioutil.WriteFile(path, bytes.Repeat([]byte{1}, 10), 0644); err != nil {
+			t.Fatal(err)
+		}
+		modTime := now.Add(time.Duration(i-2) * time.Hour)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "partial.jpg.123.tmp"), []byte{1}, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cache, err := NewImageCache(dir, 0, 15)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 || files[0].Name() != "new.jpg" {
+		t.Errorf("Got %v files, expected only new.jpg to be kept", len(files))
+	}
+	if !cache.Contains("new.jpg") || cache.Contains("old.jpg") {
+		t.Errorf("Expected only new.jpg to be cached")
+	}
+}
@@ -0,0 +1,81 @@
+package metadata
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+const STORAGE_RETRY_ATTEMPTS = 3
+const STORAGE_RETRY_DELAY = 500 * time.Millisecond
+
+// ErrNotFound is wrapped by the errors of the image storage when a layer or image doesn't exist
+var ErrNotFound = errors.New("not found")
+
+// ErrRenderBusy is returned by on-demand renders when all render slots stay busy
+var ErrRenderBusy = errors.New("all render slots are busy")
+
+// LayerNotFoundError is returned when a source layer of the image doesn't exist in the image storage
+type LayerNotFoundError struct {
+	Layer int
+	Gene  string
+	Path  string
+}
+
+func (e *LayerNotFoundError) Error() string {
+	return fmt.Sprintf("layer %v with gene %v not found at %v", e.Layer, e.Gene, e.Path)
+}
+
+func (e *LayerNotFoundError) Unwrap() error {
+	return ErrNotFound
+}
+
+// LayerDecodeError is returned when a source layer isn't a valid image
+type LayerDecodeError struct {
+	Path string
+	Err  error
+}
+
+func (e *LayerDecodeError) Error() string {
+	return fmt.Sprintf("failed to decode layer %v: %v", e.Path, e.Err)
+}
+
+func (e *LayerDecodeError) Unwrap() error {
+	return e.Err
+}
+
+// StorageError is returned when an image storage operation still fails after all retries
+type StorageError struct {
+	Op   string
+	Name string
+	Err  error
+}
+
+func (e *StorageError) Error() string {
+	return fmt.Sprintf("%v %v failed after %v attempts: %v", e.Op, e.Name, STORAGE_RETRY_ATTEMPTS, e.Err)
+}
+
+func (e *StorageError) Unwrap() error {
+	return e.Err
+}
+
+// retryStorage runs the storage operation until it succeeds or the attempts run out. The delay doubles after every attempt.
+//
+// Errors wrapping ErrNotFound are returned as they are because retrying won't help
+func retryStorage(op string, name string, fn func() error) error {
+	delay := STORAGE_RETRY_DELAY
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil || errors.Is(err, ErrNotFound) {
+			return err
+		}
+		if attempt == STORAGE_RETRY_ATTEMPTS {
+			return &StorageError{Op: op, Name: name, Err: err}
+		}
+		log.Printf("%v %v failed, retrying in %v: %v", op, name, delay, err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
@@ -1,141 +1,324 @@
 package metadata
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
+	"io/ioutil"
 	"log"
-	"net/http"
+	"os"
+	"rarity-backend/models"
+	"rarity-backend/structs"
+	"strconv"
 	"strings"
+	"time"
 
-	"cloud.google.com/go/storage"
 	"github.com/disintegration/imaging"
 )
 
 const IMG_SIZE = 4000
 const GCLOUD_UPLOAD_BUCKET_NAME = "polymorph-images"
 const GCLOUD_SOURCE_BUCKET_NAME = "polymorph-source-images"
+const LAYER_PATH_FORMAT = "./images/%v/%s.png"
+const RENDER_QUEUE_SIZE = 1000
 
-func CheckImageAndCreate(imageURL string, genes []string) {
-	imageExists := imageExists(imageURL)
-	if !imageExists {
-		generateAndSaveImage(genes)
-	}
+// RENDER_SLOT_TIMEOUT is the time on-demand renders wait for a render slot before they give up
+const RENDER_SLOT_TIMEOUT = 10 * time.Second
+
+// ImageGenerator composes polymorph images from the source layers in the image storage.
+//
+// Images of new genes are rendered one at a time by a background worker because each one takes a lot of memory.
+//
+// Renders of on-demand image variants share the render slots with the worker.
+type ImageGenerator struct {
+	storage       ImageStorage
+	cache         *ImageCache
+	queue         chan []string
+	renderSlots   chan struct{}
+	failedRenders FailedRenderQueue
 }
 
-func imageExists(imageURL string) bool {
-	resp, err := http.Get(imageURL)
-	if err != nil {
-		// log.Fatalln(err)
-		log.Println(err)
-		return false
-	}
+// FailedRenderQueue stores the renders of the render worker which failed so they can be replayed
+type FailedRenderQueue interface {
+	// Push adds the render to the queue or increments its attempts if it's already queued
+	Push(render models.FailedRender) error
+	All() ([]models.FailedRender, error)
+	Remove(image string) error
+}
 
-	return resp.StatusCode != 404
+// NewImageGenerator creates an image generator and starts its render worker. At most concurrency images are composed at the same time
+func NewImageGenerator(storage ImageStorage, cache *ImageCache, concurrency int) *ImageGenerator {
+	generator := &ImageGenerator{
+		storage:     storage,
+		cache:       cache,
+		queue:       make(chan []string, RENDER_QUEUE_SIZE),
+		renderSlots: make(chan struct{}, concurrency),
+	}
+	go generator.renderWorker()
+	return generator
 }
 
-func combineRemoteImages(bucket *storage.BucketHandle, basePath string, overlayPaths ...string) (*image.NRGBA, error) {
+// NewImageGeneratorFromEnv creates the image generator of a collection using the storage configured in .env and the locations of the collection images
+//
+// IMAGE_STORAGE can be "gcs" or "local". For local storage the source and output dirs of the images must be set. Empty buckets use the polymorph buckets.
+//
+// Rendered image variants are cached in memory (IMAGE_CACHE_MEMORY_MB) and in the cache dir of the images (up to IMAGE_CACHE_DISK_MB) if set. IMAGE_RENDER_CONCURRENCY limits the number of images composed at the same time.
+// The limits apply to the generator of every collection.
+//
+// Returns nil if IMAGE_STORAGE is empty which disables image rendering.
+func NewImageGeneratorFromEnv(images structs.Images) (*ImageGenerator, error) {
+	var storage ImageStorage
+	var err error
+
+	switch os.Getenv("IMAGE_STORAGE") {
+	case "":
+		return nil, nil
+	case "gcs":
+		sourceBucket, uploadBucket := images.SourceBucket, images.UploadBucket
+		if sourceBucket == "" {
+			sourceBucket = GCLOUD_SOURCE_BUCKET_NAME
+		}
+		if uploadBucket == "" {
+			uploadBucket = GCLOUD_UPLOAD_BUCKET_NAME
+		}
+		storage, err = NewGCloudImageStorage(context.Background(), sourceBucket, uploadBucket)
+	case "local":
+		if images.SourceDir == "" || images.OutputDir == "" {
+			return nil, fmt.Errorf("Missing image source or output dir")
+		}
+		storage, err = NewLocalImageStorage(images.SourceDir, images.OutputDir)
+	default:
+		return nil, fmt.Errorf("Unknown image storage %v", os.Getenv("IMAGE_STORAGE"))
+	}
 
-	ctx := context.Background()
+	if err != nil {
+		return nil, err
+	}
 
-	baseReader, err := bucket.Object(basePath).NewReader(ctx)
+	memoryMB, err := envInt("IMAGE_CACHE_MEMORY_MB", DEFAULT_CACHE_MEMORY_MB)
 	if err != nil {
-		// log.Fatalf("failed to open image: %v", err)
-		log.Printf("failed to open image: %v", err)
 		return nil, err
 	}
-	defer baseReader.Close()
-	base, err := imaging.Decode(baseReader)
+	diskMB, err := envInt("IMAGE_CACHE_DISK_MB", DEFAULT_CACHE_DISK_MB)
 	if err != nil {
-		// log.Fatalf("failed to decode image: %v", err)
-		log.Printf("failed to decode image: %v", err)
 		return nil, err
 	}
-	dst := imaging.New(IMG_SIZE, IMG_SIZE, color.NRGBA{0, 0, 0, 0})
-	dst = imaging.Paste(dst, base, image.Pt(0, 0))
+	concurrency, err := envInt("IMAGE_RENDER_CONCURRENCY", DEFAULT_RENDER_CONCURRENCY)
+	if err != nil {
+		return nil, err
+	}
+	if concurrency < 1 {
+		return nil, fmt.Errorf("IMAGE_RENDER_CONCURRENCY must be at least 1")
+	}
 
-	for _, op := range overlayPaths {
-		r, err := bucket.Object(op).NewReader(ctx)
-		if err != nil {
-			log.Fatalf("failed to open image: %v", err)
-		}
-		defer r.Close()
-		o, err := imaging.Decode(r)
-		if err != nil {
-			log.Fatalf("failed to open image: %v", err)
-		}
-		dst = imaging.Overlay(dst, o, image.Pt(0, 0), 1)
+	cache, err := NewImageCache(images.CacheDir, memoryMB<<20, int64(diskMB)<<20)
+	if err != nil {
+		return nil, err
 	}
+	return NewImageGenerator(storage, cache, concurrency), nil
+}
 
-	return dst, nil
+// envInt parses the integer env variable or returns the default value if it's empty
+func envInt(name string, defaultValue int) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid %v: %v", name, err)
+	}
+	return n, nil
 }
 
-func reverseGenesOrder(genes []string) []string {
-	res := make([]string, 0, len(genes))
-	for i := len(genes) - 1; i >= 0; i-- {
-		res = append(res, genes[i])
+// ImageName returns the name of the rendered image. It's the same name used in the polymorph image url
+func ImageName(genes []string) string {
+	return strings.Join(genes, "") + ".jpg"
+}
+
+// Enqueue schedules rendering of the image of the genes returned by Genome.Genes
+func (g *ImageGenerator) Enqueue(genes []string) {
+	select {
+	case g.queue <- genes:
+	default:
+		log.Printf("Render queue is full, skipping image %v", ImageName(genes))
 	}
-	return res
 }
 
-func saveToGCloud(i *image.NRGBA, name string) {
-	ctx := context.Background()
-	client, err := storage.NewClient(ctx)
+func (g *ImageGenerator) renderWorker() {
+	for genes := range g.queue {
+		if err := g.CheckImageAndCreate(genes); err != nil {
+			log.Printf("Failed to render image %v: %v", ImageName(genes), err)
+			g.recordFailedRender(genes, err)
+		}
+	}
+}
+
+// SetFailedRenderQueue sets the queue in which failed renders of the render worker are recorded. Without a queue they are only logged
+func (g *ImageGenerator) SetFailedRenderQueue(queue FailedRenderQueue) {
+	g.failedRenders = queue
+}
 
+// ReplayFailedRenders renders the images of the failed render queue again. Successful renders are removed from the queue, failed ones stay with an incremented attempts count.
+//
+// Returns the number of rendered and still failing images
+func (g *ImageGenerator) ReplayFailedRenders() (int, int, error) {
+	if g.failedRenders == nil {
+		return 0, 0, fmt.Errorf("No failed render queue configured")
+	}
+
+	renders, err := g.failedRenders.All()
 	if err != nil {
-		log.Println("storage.NewClient: %v", err)
+		return 0, 0, err
 	}
-	defer client.Close()
 
-	bucket := client.Bucket(GCLOUD_UPLOAD_BUCKET_NAME).Object(name).NewWriter(ctx)
-	// f, err := imaging.FormatFromFilename(name)
-	// if err != nil {
-	// 	log.Errorf("Format from filename: %v", err)
-	// }
-	err = imaging.Encode(bucket, i, imaging.JPEG, imaging.JPEGQuality(80))
+	rendered, failed := 0, 0
+	for _, render := range renders {
+		if err := g.CheckImageAndCreate(render.Genes); err != nil {
+			log.Printf("Failed to render image %v again: %v", render.Image, err)
+			g.recordFailedRender(render.Genes, err)
+			failed++
+			continue
+		}
+		if err := g.failedRenders.Remove(render.Image); err != nil {
+			return rendered, failed, err
+		}
+		rendered++
+	}
+	return rendered, failed, nil
+}
 
+func (g *ImageGenerator) recordFailedRender(genes []string, renderErr error) {
+	if g.failedRenders == nil {
+		return
+	}
+	err := g.failedRenders.Push(models.FailedRender{
+		Image:    ImageName(genes),
+		Genes:    genes,
+		Error:    renderErr.Error(),
+		FailedAt: time.Now(),
+	})
 	if err != nil {
-		log.Println("Upload: %v", err)
+		log.Printf("Failed to record failed render %v: %v", ImageName(genes), err)
 	}
+}
 
-	if err = bucket.Close(); err != nil {
-		log.Println("Writer.Close: %v", err)
+// CheckImageAndCreate renders and saves the image if it doesn't exist in the storage
+func (g *ImageGenerator) CheckImageAndCreate(genes []string) error {
+	name := ImageName(genes)
+	exists := false
+	err := retryStorage("check image", name, func() error {
+		var err error
+		exists, err = g.storage.ImageExists(context.Background(), name)
+		return err
+	})
+	if err != nil {
+		return err
 	}
-
+	if exists {
+		return nil
+	}
+	return g.generateAndSaveImage(genes)
 }
 
-func generateAndSaveImage(genes []string) {
-	// Reverse
-	revGenes := reverseGenesOrder(genes)
+// composeImage combines the layers of the genes into a full size image. It waits at most slotTimeout for a render slot, 0 waits until one is free.
+//
+// Returns ErrRenderBusy if the wait times out, a *LayerNotFoundError or *LayerDecodeError if a layer is missing or invalid and a *StorageError if the storage keeps failing
+func (g *ImageGenerator) composeImage(genes []string, slotTimeout time.Duration) (*image.NRGBA, error) {
+	if slotTimeout == 0 {
+		g.renderSlots <- struct{}{}
+	} else {
+		timer := time.NewTimer(slotTimeout)
+		select {
+		case g.renderSlots <- struct{}{}:
+			timer.Stop()
+		case <-timer.C:
+			return nil, ErrRenderBusy
+		}
+	}
+	defer func() { <-g.renderSlots }()
 
-	f := make([]string, len(genes))
+	dst := imaging.New(IMG_SIZE, IMG_SIZE, color.NRGBA{0, 0, 0, 0})
 
-	for i, gene := range revGenes {
-		f[i] = fmt.Sprintf("./images/%v/%s.png", i, gene)
+	// The base layer is the last gene
+	for i, gene := range reverseGenesOrder(genes) {
+		layer, err := g.openLayer(i, gene)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			dst = imaging.Paste(dst, layer, image.Pt(0, 0))
+		} else {
+			dst = imaging.Overlay(dst, layer, image.Pt(0, 0), 1)
+		}
 	}
 
-	ctx := context.Background()
-	client, err := storage.NewClient(ctx)
+	return dst, nil
+}
 
+// openLayer reads and decodes the source layer of the gene. Reading is retried, decoding isn't
+func (g *ImageGenerator) openLayer(layer int, gene string) (image.Image, error) {
+	path := fmt.Sprintf(LAYER_PATH_FORMAT, layer, gene)
+
+	var data []byte
+	err := retryStorage("read layer", path, func() error {
+		r, err := g.storage.OpenLayer(context.Background(), path)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		data, err = ioutil.ReadAll(r)
+		return err
+	})
+	if errors.Is(err, ErrNotFound) {
+		return nil, &LayerNotFoundError{Layer: layer, Gene: gene, Path: path}
+	}
 	if err != nil {
-		log.Println("storage.NewClient: %v", err)
+		return nil, err
 	}
-	defer client.Close()
-
-	bucket := client.Bucket(GCLOUD_SOURCE_BUCKET_NAME)
 
-	i, err := combineRemoteImages(bucket, f[0], f[1:]...)
+	img, err := imaging.Decode(bytes.NewReader(data))
 	if err != nil {
-		b := strings.Builder{}
+		return nil, &LayerDecodeError{Path: path, Err: err}
+	}
+	return img, nil
+}
 
-		for _, gene := range genes {
-			b.WriteString(gene)
-		}
+func reverseGenesOrder(genes []string) []string {
+	res := make([]string, 0, len(genes))
+	for i := len(genes) - 1; i >= 0; i-- {
+		res = append(res, genes[i])
+	}
+	return res
+}
 
-		b.WriteString(".jpg") // Finish with jpg extension
+// saveImage encodes the image once and retries the upload
+func (g *ImageGenerator) saveImage(i *image.NRGBA, name string) error {
+	var buf bytes.Buffer
+	if err := imaging.Encode(&buf, i, imaging.JPEG, imaging.JPEGQuality(80)); err != nil {
+		return err
+	}
 
-		saveToGCloud(i, b.String())
+	return retryStorage("save image", name, func() error {
+		w, err := g.storage.CreateImage(context.Background(), name)
+		if err != nil {
+			return err
+		}
+		if _, err = w.Write(buf.Bytes()); err != nil {
+			w.Close()
+			return err
+		}
+		return w.Close()
+	})
+}
 
+func (g *ImageGenerator) generateAndSaveImage(genes []string) error {
+	i, err := g.composeImage(genes, 0)
+	if err != nil {
+		return err
 	}
+	return g.saveImage(i, ImageName(genes))
 }
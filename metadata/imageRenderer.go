@@ -0,0 +1,142 @@
+package metadata
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"log"
+	"strings"
+
+	"github.com/chai2010/webp"
+	"github.com/disintegration/imaging"
+)
+
+const IMAGE_FORMAT_JPEG = "jpeg"
+const IMAGE_FORMAT_PNG = "png"
+const IMAGE_FORMAT_WEBP = "webp"
+const DEFAULT_RENDER_SIZE = 1024
+const DEFAULT_CACHE_MEMORY_MB = 256
+const DEFAULT_CACHE_DISK_MB = 10240
+const DEFAULT_RENDER_CONCURRENCY = 1
+
+// RENDER_SIZES are the supported widths of rendered images. Sizes are limited so the number of cached variants per gene stays small
+var RENDER_SIZES = []int{64, 128, 256, 512, 1024, 2048, IMG_SIZE}
+
+// ImageContentTypes maps the supported image formats to their content types
+var ImageContentTypes = map[string]string{
+	IMAGE_FORMAT_JPEG: "image/jpeg",
+	IMAGE_FORMAT_PNG:  "image/png",
+	IMAGE_FORMAT_WEBP: "image/webp",
+}
+
+var imageExtensions = map[string]string{
+	IMAGE_FORMAT_JPEG: "jpg",
+	IMAGE_FORMAT_PNG:  "png",
+	IMAGE_FORMAT_WEBP: "webp",
+}
+
+// ValidRenderSize returns whether the size is one of RENDER_SIZES
+func ValidRenderSize(size int) bool {
+	for _, s := range RENDER_SIZES {
+		if s == size {
+			return true
+		}
+	}
+	return false
+}
+
+// RenderCacheKey returns the cache key of an image variant, e.g. 020103040607050908_512.webp
+func RenderCacheKey(genes []string, size int, format string) string {
+	return fmt.Sprintf("%v_%v.%v", strings.Join(genes, ""), size, imageExtensions[format])
+}
+
+// Render returns the image of the genes in the passed size and format. Cached variants are returned without composing the layers again.
+//
+// Returns ErrRenderBusy if no render slot becomes free within RENDER_SLOT_TIMEOUT
+func (g *ImageGenerator) Render(genes []string, size int, format string) ([]byte, error) {
+	if err := validateVariant(size, format); err != nil {
+		return nil, err
+	}
+
+	key := RenderCacheKey(genes, size, format)
+	if data, ok := g.cache.Get(key); ok {
+		return data, nil
+	}
+
+	img, err := g.composeImage(genes, RENDER_SLOT_TIMEOUT)
+	if err != nil {
+		return nil, err
+	}
+	return g.renderVariant(img, key, size, format)
+}
+
+// Prerender renders and caches all missing variants of the genes. The layers are composed once for all variants.
+//
+// Returns the number of rendered variants
+func (g *ImageGenerator) Prerender(genes []string, sizes []int, formats []string) (int, error) {
+	var img *image.NRGBA
+	rendered := 0
+
+	for _, size := range sizes {
+		for _, format := range formats {
+			if err := validateVariant(size, format); err != nil {
+				return rendered, err
+			}
+			key := RenderCacheKey(genes, size, format)
+			if g.cache.Contains(key) {
+				continue
+			}
+
+			if img == nil {
+				var err error
+				if img, err = g.composeImage(genes, 0); err != nil {
+					return rendered, err
+				}
+			}
+			if _, err := g.renderVariant(img, key, size, format); err != nil {
+				return rendered, err
+			}
+			rendered++
+		}
+	}
+	return rendered, nil
+}
+
+// renderVariant resizes and encodes the full size image and stores the result in the cache
+func (g *ImageGenerator) renderVariant(img *image.NRGBA, key string, size int, format string) ([]byte, error) {
+	resized := img
+	if size != img.Bounds().Dx() {
+		resized = imaging.Resize(img, size, size, imaging.Lanczos)
+	}
+
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case IMAGE_FORMAT_JPEG:
+		err = imaging.Encode(&buf, resized, imaging.JPEG, imaging.JPEGQuality(80))
+	case IMAGE_FORMAT_PNG:
+		err = imaging.Encode(&buf, resized, imaging.PNG)
+	case IMAGE_FORMAT_WEBP:
+		err = webp.Encode(&buf, resized, &webp.Options{Lossless: true})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	data := buf.Bytes()
+	if err := g.cache.Put(key, data); err != nil {
+		// The image can still be served, it will be rendered again next time
+		log.Printf("Failed to cache image %v: %v", key, err)
+	}
+	return data, nil
+}
+
+func validateVariant(size int, format string) error {
+	if !ValidRenderSize(size) {
+		return fmt.Errorf("Unsupported image size %v", size)
+	}
+	if _, ok := ImageContentTypes[format]; !ok {
+		return fmt.Errorf("Unsupported image format %v", format)
+	}
+	return nil
+}
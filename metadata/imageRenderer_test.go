@@ -0,0 +1,49 @@
+package metadata
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/chai2010/webp"
+	"github.com/disintegration/imaging"
+)
+
+func TestRenderFormats(t *testing.T) {
+	generator, sourceDir, _ := newLocalGenerator(t)
+	red, blue := color.NRGBA{255, 0, 0, 255}, color.NRGBA{0, 0, 255, 255}
+	genes := []string{"07", "02"}
+	// The base layer covers the whole image, the top layer its top left quarter
+	writeLayer(t, sourceDir, 0, "02", IMG_SIZE, red)
+	writeLayer(t, sourceDir, 1, "07", IMG_SIZE/2, blue)
+
+	decoders := map[string]func(data []byte) (image.Image, error){
+		IMAGE_FORMAT_JPEG: func(data []byte) (image.Image, error) { return imaging.Decode(bytes.NewReader(data)) },
+		IMAGE_FORMAT_PNG:  func(data []byte) (image.Image, error) { return imaging.Decode(bytes.NewReader(data)) },
+		IMAGE_FORMAT_WEBP: func(data []byte) (image.Image, error) { return webp.Decode(bytes.NewReader(data)) },
+	}
+
+	for format, decode := range decoders {
+		data, err := generator.Render(genes, 64, format)
+		if err != nil {
+			t.Fatalf("%v: %v", format, err)
+		}
+		img, err := decode(data)
+		if err != nil {
+			t.Fatalf("%v: failed to decode: %v", format, err)
+		}
+		if img.Bounds() != image.Rect(0, 0, 64, 64) {
+			t.Errorf("%v: got bounds %v, expected 64x64", format, img.Bounds())
+		}
+		if got := img.At(8, 8); !closeTo(got, blue) {
+			t.Errorf("%v: got %v in the top layer, expected blue", format, got)
+		}
+		if got := img.At(48, 48); !closeTo(got, red) {
+			t.Errorf("%v: got %v in the base layer, expected red", format, got)
+		}
+		if cached, ok := generator.cache.Get(RenderCacheKey(genes, 64, format)); !ok || !bytes.Equal(cached, data) {
+			t.Errorf("%v: expected the variant to be cached", format)
+		}
+	}
+}
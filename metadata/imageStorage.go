@@ -0,0 +1,115 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"cloud.google.com/go/storage"
+)
+
+// ImageStorage reads the source layers of the polymorph images and stores the rendered images
+type ImageStorage interface {
+	// OpenLayer opens the source layer png at the passed path. Returns an error wrapping ErrNotFound if the layer doesn't exist
+	OpenLayer(ctx context.Context, path string) (io.ReadCloser, error)
+	// ImageExists returns whether a rendered image with the passed name is already stored
+	ImageExists(ctx context.Context, name string) (bool, error)
+	// CreateImage returns a writer for the rendered image. The image is stored when the writer is closed
+	CreateImage(ctx context.Context, name string) (io.WriteCloser, error)
+}
+
+// GCloudImageStorage reads layers from and writes images to Google Cloud Storage buckets
+type GCloudImageStorage struct {
+	client       *storage.Client
+	sourceBucket string
+	uploadBucket string
+}
+
+// NewGCloudImageStorage creates a storage client for the passed source and upload buckets
+func NewGCloudImageStorage(ctx context.Context, sourceBucket string, uploadBucket string) (*GCloudImageStorage, error) {
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GCloudImageStorage{
+		client:       client,
+		sourceBucket: sourceBucket,
+		uploadBucket: uploadBucket,
+	}, nil
+}
+
+func (s *GCloudImageStorage) OpenLayer(ctx context.Context, path string) (io.ReadCloser, error) {
+	r, err := s.client.Bucket(s.sourceBucket).Object(path).NewReader(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, fmt.Errorf("%v: %w", path, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (s *GCloudImageStorage) ImageExists(ctx context.Context, name string) (bool, error) {
+	_, err := s.client.Bucket(s.uploadBucket).Object(name).Attrs(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *GCloudImageStorage) CreateImage(ctx context.Context, name string) (io.WriteCloser, error) {
+	return s.client.Bucket(s.uploadBucket).Object(name).NewWriter(ctx), nil
+}
+
+// LocalImageStorage reads layers from and writes images to directories on the local filesystem.
+//
+// Layers are read from sourceDir using the same paths as the source bucket, e.g. sourceDir/images/0/02.png
+type LocalImageStorage struct {
+	sourceDir string
+	outputDir string
+}
+
+// NewLocalImageStorage creates the output directory if it doesn't exist
+func NewLocalImageStorage(sourceDir string, outputDir string) (*LocalImageStorage, error) {
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &LocalImageStorage{
+		sourceDir: sourceDir,
+		outputDir: outputDir,
+	}, nil
+}
+
+func (s *LocalImageStorage) OpenLayer(ctx context.Context, path string) (io.ReadCloser, error) {
+	f, err := os.Open(filepath.Join(s.sourceDir, filepath.FromSlash(path)))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%v: %w", path, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (s *LocalImageStorage) ImageExists(ctx context.Context, name string) (bool, error) {
+	_, err := os.Stat(filepath.Join(s.outputDir, name))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *LocalImageStorage) CreateImage(ctx context.Context, name string) (io.WriteCloser, error) {
+	return os.Create(filepath.Join(s.outputDir, name))
+}
@@ -0,0 +1,161 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+// writeLayer writes a square layer of the color to the path the generator reads the gene's layer from
+func writeLayer(t *testing.T, sourceDir string, layer int, gene string, size int, c color.NRGBA) {
+	t.Helper()
+	path := filepath.Join(sourceDir, filepath.FromSlash(fmt.Sprintf(LAYER_PATH_FORMAT, layer, gene)))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := imaging.Save(imaging.New(size, size, c), path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newLocalGenerator(t *testing.T) (*ImageGenerator, string, string) {
+	t.Helper()
+	sourceDir, outputDir := t.TempDir(), filepath.Join(t.TempDir(), "output")
+	storage, err := NewLocalImageStorage(sourceDir, outputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache, err := NewImageCache("", 1<<20, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewImageGenerator(storage, cache, 1), sourceDir, outputDir
+}
+
+// closeTo compares the colors with a tolerance for the jpeg compression
+func closeTo(got color.Color, want color.NRGBA) bool {
+	r, g, b, _ := got.RGBA()
+	diff := func(a uint32, b uint8) bool {
+		d := int(a>>8) - int(b)
+		return d > -40 && d < 40
+	}
+	return diff(r, want.R) && diff(g, want.G) && diff(b, want.B)
+}
+
+func TestCheckImageAndCreate(t *testing.T) {
+	generator, sourceDir, outputDir := newLocalGenerator(t)
+	red, blue := color.NRGBA{255, 0, 0, 255}, color.NRGBA{0, 0, 255, 255}
+	// The last gene is the base layer
+	genes := []string{"07", "02"}
+	writeLayer(t, sourceDir, 0, "02", 64, red)
+	writeLayer(t, sourceDir, 1, "07", 32, blue)
+
+	if err := generator.CheckImageAndCreate(genes); err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := imaging.Open(filepath.Join(outputDir, ImageName(genes)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds() != image.Rect(0, 0, IMG_SIZE, IMG_SIZE) {
+		t.Errorf("Got bounds %v, expected %vx%v", img.Bounds(), IMG_SIZE, IMG_SIZE)
+	}
+	if got := img.At(8, 8); !closeTo(got, blue) {
+		t.Errorf("Got %v in the top layer, expected blue", got)
+	}
+	if got := img.At(48, 48); !closeTo(got, red) {
+		t.Errorf("Got %v in the base layer, expected red", got)
+	}
+}
+
+func TestCheckImageAndCreateSkipsExistingImage(t *testing.T) {
+	generator, _, outputDir := newLocalGenerator(t)
+	genes := []string{"07", "02"}
+	if err := ioutil.WriteFile(filepath.Join(outputDir, ImageName(genes)), []byte("rendered"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The layers don't exist, so rendering would fail
+	if err := generator.CheckImageAndCreate(genes); err != nil {
+		t.Errorf("Expected the existing image to be kept, got %v", err)
+	}
+}
+
+func TestCheckImageAndCreateMissingLayer(t *testing.T) {
+	generator, sourceDir, outputDir := newLocalGenerator(t)
+	genes := []string{"07", "02"}
+	writeLayer(t, sourceDir, 0, "02", 8, color.NRGBA{255, 0, 0, 255})
+
+	err := generator.CheckImageAndCreate(genes)
+	var notFound *LayerNotFoundError
+	if !errors.As(err, &notFound) {
+		t.Fatalf("Got %v, expected a LayerNotFoundError", err)
+	}
+	if notFound.Layer != 1 || notFound.Gene != "07" || !errors.Is(err, ErrNotFound) {
+		t.Errorf("Got %+v, expected layer 1 with gene 07 wrapping ErrNotFound", notFound)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, ImageName(genes))); !os.IsNotExist(err) {
+		t.Errorf("Expected no image to be saved, got %v", err)
+	}
+}
+
+// failingStorage fails the first failures image checks before it delegates to the storage
+type failingStorage struct {
+	ImageStorage
+	failures int
+	checks   int
+}
+
+func (s *failingStorage) ImageExists(ctx context.Context, name string) (bool, error) {
+	s.checks++
+	if s.checks <= s.failures {
+		return false, io.ErrUnexpectedEOF
+	}
+	return s.ImageStorage.ImageExists(ctx, name)
+}
+
+func TestRetryStorage(t *testing.T) {
+	local, err := NewLocalImageStorage(t.TempDir(), t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storage := &failingStorage{ImageStorage: local, failures: STORAGE_RETRY_ATTEMPTS - 1}
+	err = retryStorage("check image", "a.jpg", func() error {
+		_, err := storage.ImageExists(context.Background(), "a.jpg")
+		return err
+	})
+	if err != nil || storage.checks != STORAGE_RETRY_ATTEMPTS {
+		t.Errorf("Got %v after %v checks, expected success after %v", err, storage.checks, STORAGE_RETRY_ATTEMPTS)
+	}
+
+	storage = &failingStorage{ImageStorage: local, failures: STORAGE_RETRY_ATTEMPTS}
+	err = retryStorage("check image", "a.jpg", func() error {
+		_, err := storage.ImageExists(context.Background(), "a.jpg")
+		return err
+	})
+	var storageErr *StorageError
+	if !errors.As(err, &storageErr) || !errors.Is(err, io.ErrUnexpectedEOF) || storage.checks != STORAGE_RETRY_ATTEMPTS {
+		t.Errorf("Got %v after %v checks, expected a StorageError after %v", err, storage.checks, STORAGE_RETRY_ATTEMPTS)
+	}
+
+	attempts := 0
+	err = retryStorage("read layer", "./images/0/02.png", func() error {
+		attempts++
+		_, err := local.OpenLayer(context.Background(), "./images/0/02.png")
+		return err
+	})
+	if !errors.Is(err, ErrNotFound) || attempts != 1 {
+		t.Errorf("Got %v after %v attempts, expected ErrNotFound without retries", err, attempts)
+	}
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// FailedRender is an image of the render queue which couldn't be rendered. Image is the name of the rendered image and identifies the render
+type FailedRender struct {
+	Image    string    `json:"image"`
+	Genes    []string  `json:"genes"`
+	Error    string    `json:"error"`
+	Attempts int       `json:"attempts"`
+	FailedAt time.Time `json:"failedat"`
+}
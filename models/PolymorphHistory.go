@@ -14,4 +14,6 @@ type PolymorphHistory struct {
 	NewGene           string    `json:"newgene,omitempty"`
 	OldGene           string    `json:"oldgene,omitempty"`
 	Character         string    `json:"character,omitempty"`
+	// Wallet is the lower case address which sent the morph transaction
+	Wallet string `json:"wallet,omitempty"`
 }
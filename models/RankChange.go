@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// RankChange is a snapshot of a polymorph's rank taken when the ranking changes it
+type RankChange struct {
+	TokenId      int       `json:"tokenid"`
+	Rank         int       `json:"rank"`
+	PreviousRank int       `json:"previousrank"`
+	DateTime     time.Time `json:"datetime"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Webhook is a registered webhook of a collection. Type selects the event it's called for:
+//
+// "morph" - TokenId is morphed or scrambled. "rank" - a polymorph owned by Wallet changes rank by at least MinRankChange places. "set" - any polymorph completes SetName
+type Webhook struct {
+	Id            string    `json:"id"`
+	Url           string    `json:"url"`
+	Secret        string    `json:"secret"`
+	Type          string    `json:"type"`
+	TokenId       int       `json:"tokenid,omitempty"`
+	Wallet        string    `json:"wallet,omitempty"`
+	MinRankChange int       `json:"minrankchange,omitempty"`
+	SetName       string    `json:"setname,omitempty"`
+	CreatedAt     time.Time `json:"createdat"`
+}
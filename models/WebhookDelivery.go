@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// WebhookDelivery records the outcome of calling a webhook for an event. StatusCode is 0 if no response was received
+type WebhookDelivery struct {
+	Id          string    `json:"id"`
+	WebhookId   string    `json:"webhookid"`
+	EventType   string    `json:"eventtype"`
+	TokenId     int       `json:"tokenid"`
+	Payload     string    `json:"payload"`
+	Delivered   bool      `json:"delivered"`
+	Attempts    int       `json:"attempts"`
+	StatusCode  int       `json:"statuscode"`
+	Error       string    `json:"error,omitempty"`
+	CreatedAt   time.Time `json:"createdat"`
+	CompletedAt time.Time `json:"completedat"`
+}
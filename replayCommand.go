@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+
+	"rarity-backend/config"
+	"rarity-backend/dlt"
+	"rarity-backend/handlers"
+	"rarity-backend/helpers"
+	"rarity-backend/repositories"
+	"rarity-backend/services"
+	"rarity-backend/store"
+	"rarity-backend/structs"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/joho/godotenv"
+)
+
+// replay processes a block range into a scratch database and prints how its rarity entities, ranks and history differ from the live collections.
+//
+// Usage: rarity-backend replay -from 12850000 [-to 12900000] [-collection polymorphs] [-db polymorphs-replay] [-max 20]
+//
+// The genes are read at the -to block, which is fixed to the latest block when the replay starts if it's 0, so past ranges need an archive node.
+//
+// The scratch database is dropped before the replay. It defaults to the database of the collection with a "-replay" suffix and can't be the database of a configured collection.
+// Replays which don't start at the deployment block of the contract only know the polymorphs minted or morphed in the range, so the live polymorphs outside of it
+// are reported as missing and the ranks are computed from a part of the collection.
+func replay(args []string) {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	fromFlag := flags.Int64("from", 0, "first block of the range")
+	toFlag := flags.Int64("to", 0, "last block of the range, the latest block if it's 0")
+	collectionFlag := flags.String("collection", "", "name of the collection, the first configured collection if it's empty")
+	dbFlag := flags.String("db", "", "name of the scratch database")
+	maxFlag := flags.Int("max", 20, "maximum number of keys and differences printed per section")
+	flags.Parse(args)
+
+	if *fromFlag < 1 {
+		log.Fatal("-from must be a positive block number")
+	}
+	if *toFlag != 0 && *toFlag < *fromFlag {
+		log.Fatal("-to must not be before -from")
+	}
+
+	err := godotenv.Load()
+	if err != nil {
+		log.Fatal("Error loading .env file: " + err.Error())
+	}
+
+	collections := config.NewCollectionsConfig()
+	collection := collections[0]
+	if *collectionFlag != "" {
+		found := false
+		for _, c := range collections {
+			if c.Name == *collectionFlag {
+				collection, found = c, true
+			}
+		}
+		if !found {
+			log.Fatalf("Unknown collection %v", *collectionFlag)
+		}
+	}
+
+	scratchDBInfo := collection.DBInfo
+	scratchDBInfo.PolymorphDBName = *dbFlag
+	if scratchDBInfo.PolymorphDBName == "" {
+		scratchDBInfo.PolymorphDBName = collection.DBInfo.PolymorphDBName + "-replay"
+	}
+	for _, c := range collections {
+		if c.DBInfo.PolymorphDBName == scratchDBInfo.PolymorphDBName {
+			log.Fatalf("The scratch database can't be %v, it's the database of collection %v", scratchDBInfo.PolymorphDBName, c.Name)
+		}
+	}
+
+	ethClient := connectToEthereum()
+	contractAbi, err := abi.JSON(strings.NewReader(string(store.StoreABI)))
+	if err != nil {
+		log.Fatal(err)
+	}
+	contractChain, err := dlt.NewContractChain(ethClient.Client, common.HexToAddress(collection.ContractAddress))
+	if err != nil {
+		log.Fatal(err)
+	}
+	// The polymorphs must have the genes they had at the end of the range, not the genes of morphs after it
+	if *toFlag == 0 {
+		header, err := contractChain.HeaderByNumber(context.Background(), nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		*toFlag = header.Number.Int64()
+		if *toFlag < *fromFlag {
+			log.Fatalf("-from is after the latest block %v", *toFlag)
+		}
+	}
+	chain := contractChain.AtBlock(big.NewInt(*toFlag))
+
+	if err := handlers.DropDatabase(scratchDBInfo.PolymorphDBName); err != nil {
+		log.Fatal(err)
+	}
+	configService := config.NewConfigService(collection.ConfigPath)
+	lastBlock := services.ReplayProcess(chain, contractAbi, collection.ContractAddress, configService, config.RarityModels[collection.RarityModel], scratchDBInfo, repositories.NewMongoRepositories(scratchDBInfo), *fromFlag, *toFlag)
+
+	// History snapshots are compared within the time span of the replayed blocks
+	blockTime := func(number int64) string {
+		header, err := chain.HeaderByNumber(context.Background(), big.NewInt(number))
+		if err != nil {
+			log.Fatal(err)
+		}
+		return time.Unix(int64(header.Time), 0).UTC().Format(time.RFC3339)
+	}
+	from, to := blockTime(*fromFlag), blockTime(int64(lastBlock))
+
+	liveEntities, err := handlers.GetRarityDocuments(collection.DBInfo.PolymorphDBName, collection.DBInfo.RarityCollectionName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	replayEntities, err := handlers.GetRarityDocuments(scratchDBInfo.PolymorphDBName, scratchDBInfo.RarityCollectionName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	liveHistory, err := handlers.GetHistoryDocuments(collection.DBInfo.PolymorphDBName, collection.DBInfo.HistoryCollectionName, from, to)
+	if err != nil {
+		log.Fatal(err)
+	}
+	replayHistory, err := handlers.GetHistoryDocuments(scratchDBInfo.PolymorphDBName, scratchDBInfo.HistoryCollectionName, from, to)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	printReplayReport(structs.ReplayReport{
+		FromBlock: *fromFlag,
+		ToBlock:   int64(lastBlock),
+		Entities:  helpers.DiffEntities(liveEntities, replayEntities),
+		Ranks:     helpers.DiffRanks(liveEntities, replayEntities),
+		History:   helpers.DiffHistory(liveHistory, replayHistory),
+	}, collection.Name, scratchDBInfo.PolymorphDBName, *maxFlag)
+}
+
+// printReplayReport prints the report with at most max keys and differences per section
+func printReplayReport(report structs.ReplayReport, collectionName string, scratchDBName string, max int) {
+	fmt.Printf("Replay of blocks %v - %v of collection %v into %v\n", report.FromBlock, report.ToBlock, collectionName, scratchDBName)
+
+	sections := []struct {
+		name string
+		diff structs.DocumentsDiff
+	}{
+		{"Rarity entities", report.Entities},
+		{"Ranks", report.Ranks},
+		{"History", report.History},
+	}
+	for _, section := range sections {
+		diff := section.diff
+		fmt.Printf("\n%v: %v compared, %v matching, %v only live, %v only replayed, %v different fields\n",
+			section.name, diff.Compared, diff.Matching, len(diff.OnlyLive), len(diff.OnlyReplay), len(diff.Differences))
+		printKeys("Only live", diff.OnlyLive, max)
+		printKeys("Only replayed", diff.OnlyReplay, max)
+		for i, difference := range diff.Differences {
+			if i == max {
+				fmt.Printf("  ... %v more differences\n", len(diff.Differences)-max)
+				break
+			}
+			fmt.Printf("  %v %v: live %v, replayed %v\n", difference.Key, difference.Field, difference.Live, difference.Replay)
+		}
+	}
+}
+
+func printKeys(label string, keys []string, max int) {
+	if len(keys) == 0 {
+		return
+	}
+	if len(keys) > max {
+		fmt.Printf("  %v: %v ... %v more\n", label, strings.Join(keys[:max], ", "), len(keys)-max)
+		return
+	}
+	fmt.Printf("  %v: %v\n", label, strings.Join(keys, ", "))
+}
@@ -0,0 +1,231 @@
+package repositories
+
+import (
+	"rarity-backend/models"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// MemoryPolymorph is an entity stored in MemoryPolymorphs together with the fields only the update operations write
+type MemoryPolymorph struct {
+	Entity    models.PolymorphEntity
+	Morphs    int
+	Scrambles int
+	OldGenes  []string
+}
+
+// MemoryPolymorphs stores the entities by token id. Unlike the rarities collection, inserting a token twice replaces the first entity
+type MemoryPolymorphs struct {
+	mutex       sync.Mutex
+	records     map[int]*MemoryPolymorph
+	rankChanges []models.RankChange
+}
+
+func (r *MemoryPolymorphs) InsertMints(entities []models.PolymorphEntity) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, entity := range entities {
+		r.records[entity.TokenId] = &MemoryPolymorph{Entity: entity}
+	}
+	return nil
+}
+
+// SaveMorph replaces the whole entity like the $set of the Mongo update, including the rank
+func (r *MemoryPolymorphs) SaveMorph(entity models.PolymorphEntity, oldGene string, geneDiff int) (string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	record, ok := r.records[entity.TokenId]
+	if !ok {
+		record = &MemoryPolymorph{}
+		r.records[entity.TokenId] = record
+	}
+	record.Entity = entity
+	if geneDiff > 0 && geneDiff <= 2 {
+		record.OldGenes = append(record.OldGenes, oldGene)
+		record.Morphs++
+	} else if geneDiff > 2 {
+		record.OldGenes = append(record.OldGenes, oldGene)
+		record.Scrambles++
+	}
+
+	if !ok {
+		return "Inserted id in memory: " + strconv.Itoa(entity.TokenId), nil
+	}
+	return "Updated id in memory: " + strconv.Itoa(entity.TokenId), nil
+}
+
+func (r *MemoryPolymorphs) SetMorphCounters(tokenId int, morphs int, scrambles int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if record, ok := r.records[tokenId]; ok {
+		record.Morphs = morphs
+		record.Scrambles = scrambles
+	}
+	return nil
+}
+
+func (r *MemoryPolymorphs) FindAllByRarity() ([]models.PolymorphEntity, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	entities := make([]models.PolymorphEntity, 0, len(r.records))
+	for _, record := range r.records {
+		entities = append(entities, record.Entity)
+	}
+	sort.Slice(entities, func(i, j int) bool {
+		if entities[i].RarityScore != entities[j].RarityScore {
+			return entities[i].RarityScore > entities[j].RarityScore
+		}
+		return entities[i].TokenId < entities[j].TokenId
+	})
+	return entities, nil
+}
+
+// UpdateRanks always records the changes, they are returned by RankChanges
+func (r *MemoryPolymorphs) UpdateRanks(changes []models.RankChange) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, change := range changes {
+		if record, ok := r.records[change.TokenId]; ok {
+			record.Entity.Rank = change.Rank
+		}
+	}
+	r.rankChanges = append(r.rankChanges, changes...)
+	return nil
+}
+
+// Get returns a copy of the stored polymorph
+func (r *MemoryPolymorphs) Get(tokenId int) (MemoryPolymorph, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	record, ok := r.records[tokenId]
+	if !ok {
+		return MemoryPolymorph{}, false
+	}
+	polymorph := *record
+	polymorph.OldGenes = append([]string{}, record.OldGenes...)
+	return polymorph, true
+}
+
+// Count returns the number of stored polymorphs
+func (r *MemoryPolymorphs) Count() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return len(r.records)
+}
+
+// RankChanges returns every recorded rank change in the order they were persisted
+func (r *MemoryPolymorphs) RankChanges() []models.RankChange {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]models.RankChange{}, r.rankChanges...)
+}
+
+// MemoryHistory stores the snapshots in the order they were saved
+type MemoryHistory struct {
+	mutex     sync.Mutex
+	snapshots []models.PolymorphHistory
+}
+
+func (r *MemoryHistory) Save(snapshot models.PolymorphHistory) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.snapshots = append(r.snapshots, snapshot)
+	return nil
+}
+
+// ByToken returns the snapshots of the polymorph sorted by time. Snapshots with the same time keep the order they were saved in
+func (r *MemoryHistory) ByToken(tokenId int) []models.PolymorphHistory {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	var snapshots []models.PolymorphHistory
+	for _, snapshot := range r.snapshots {
+		if snapshot.TokenId == tokenId {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool { return snapshots[i].DateTime.Before(snapshots[j].DateTime) })
+	return snapshots
+}
+
+// Count returns the number of stored snapshots
+func (r *MemoryHistory) Count() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return len(r.snapshots)
+}
+
+// MemoryTransactions stores the processed morph events in the order they were saved
+type MemoryTransactions struct {
+	mutex        sync.Mutex
+	transactions []models.Transaction
+}
+
+func (r *MemoryTransactions) Save(transaction models.Transaction) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.transactions = append(r.transactions, transaction)
+	return nil
+}
+
+func (r *MemoryTransactions) Processed() (map[string]map[uint]bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return transactionsMapping(r.transactions), nil
+}
+
+// Count returns the number of stored transactions
+func (r *MemoryTransactions) Count() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return len(r.transactions)
+}
+
+// MemoryBlocks stores the last processed block
+type MemoryBlocks struct {
+	mutex sync.Mutex
+	last  int64
+}
+
+func (r *MemoryBlocks) LastProcessed() (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.last, nil
+}
+
+func (r *MemoryBlocks) SaveLastProcessed(number uint64) (string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.last = int64(number)
+	return "Successfully persisted new last processed block number: " + strconv.FormatUint(number, 10), nil
+}
+
+// MemoryMorphCosts stores the morph prices by token id
+type MemoryMorphCosts struct {
+	mutex  sync.Mutex
+	prices map[string]float32
+}
+
+func (r *MemoryMorphCosts) Save(morphCost models.MorphCost) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.prices[morphCost.TokenId] = morphCost.Price
+	return nil
+}
+
+func (r *MemoryMorphCosts) Prices() (map[string]float32, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	prices := make(map[string]float32, len(r.prices))
+	for tokenId, price := range r.prices {
+		prices[tokenId] = price
+	}
+	return prices, nil
+}
+
+// MemoryMorphStats doesn't roll up the history, the daily stats are only read from mongodb
+type MemoryMorphStats struct{}
+
+func (r *MemoryMorphStats) Rollup() error {
+	return nil
+}
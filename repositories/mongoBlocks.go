@@ -1,4 +1,4 @@
-package handlers
+package repositories
 
 import (
 	"context"
@@ -12,59 +12,54 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-// GetLastProcessedBlockNumber fetches the last processed block number from the block collection. At any point of the application there should be only one record in the collection
+// MongoBlocks stores the last processed block in the blocks collection. At any point of the application there should be only one record in the collection
+type MongoBlocks struct {
+	polymorphDBName      string
+	blocksCollectionName string
+}
+
+// LastProcessed fetches the last processed block number from the block collection.
 //
 // If no collection or record exists - returns 0. This means the application will start processing from the beggining.
-func GetLastProcessedBlockNumber(polymorphDBName string, blocksCollectionName string) (int64, error) {
-	collection, err := db.GetMongoDbCollection(polymorphDBName, blocksCollectionName)
+func (r *MongoBlocks) LastProcessed() (int64, error) {
+	collection, err := db.GetMongoDbCollection(r.polymorphDBName, r.blocksCollectionName)
 	if err != nil {
 		return 0, err
 	}
-
 	lastBlock := collection.FindOne(context.Background(), bson.M{})
-
 	if lastBlock.Err() != nil {
 		return 0, lastBlock.Err()
 	}
 
 	var result bson.M
 	lastBlock.Decode(&result)
-
 	if result == nil {
 		return 0, err
 	}
-
 	lastProcessedBlockNumber := result[constants.BlockFieldNames.Number]
 	block := lastProcessedBlockNumber.(int64)
 	return block, nil
 }
 
-// CreateOrUpdateLastProcessedBlock persists the passed block number in the parameters to the block collection. At any point of the application there should be only one record in the collection
+// SaveLastProcessed persists the block number to the block collection.
 //
 // If no collection or records exists - it will create a new one.
-func CreateOrUpdateLastProcessedBlock(number uint64, polymorphDBName string, blocksCollectionName string) (string, error) {
-	collection, err := db.GetMongoDbCollection(polymorphDBName, blocksCollectionName)
+func (r *MongoBlocks) SaveLastProcessed(number uint64) (string, error) {
+	collection, err := db.GetMongoDbCollection(r.polymorphDBName, r.blocksCollectionName)
 	if err != nil {
 		return "", err
 	}
-
 	entity := models.ProcessedBlockEntity{Number: number}
-
 	update := bson.M{
 		"$set": entity,
 	}
-
 	// This option will create new entity if no matching is found
 	opts := options.Update().SetUpsert(true)
-
 	objID, _ := primitive.ObjectIDFromHex(strconv.FormatInt(0, 16))
 	filter := bson.M{constants.BlockFieldNames.ObjId: objID}
-
 	_, err = collection.UpdateOne(context.Background(), filter, update, opts)
-
 	if err != nil {
-		return "", nil
+		return "", err
 	}
-
 	return "Successfully persisted new last processed block number: " + strconv.FormatUint(number, 10), nil
 }
@@ -0,0 +1,33 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"rarity-backend/db"
+	"rarity-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// MongoHistory stores the snapshots in the history collection
+type MongoHistory struct {
+	polymorphDBName       string
+	historyCollectionName string
+}
+
+// Save persists the polymorph history snapshot to the database.
+//
+// The snapshot is converted through its JSON representation, so the date time is stored as an RFC3339 string
+func (r *MongoHistory) Save(snapshot models.PolymorphHistory) error {
+	collection, err := db.GetMongoDbCollection(r.polymorphDBName, r.historyCollectionName)
+	if err != nil {
+		return err
+	}
+
+	var bdoc interface{}
+	json, _ := json.Marshal(snapshot)
+	bson.UnmarshalExtJSON(json, false, &bdoc)
+
+	_, err = collection.InsertOne(context.Background(), bdoc)
+	return err
+}
@@ -0,0 +1,59 @@
+package repositories
+
+import (
+	"context"
+	"rarity-backend/constants"
+	"rarity-backend/db"
+	"rarity-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoMorphCosts stores the morph prices in the morph cost collection
+type MongoMorphCosts struct {
+	polymorphDBName     string
+	priceCollectionName string
+}
+
+// Save persists the new polymorph morph price to the database
+//
+// This price will be fetched and stored in memory every time the process starts.
+func (r *MongoMorphCosts) Save(morphCost models.MorphCost) error {
+	collection, err := db.GetMongoDbCollection(r.polymorphDBName, r.priceCollectionName)
+	if err != nil {
+		return err
+	}
+	update := bson.M{
+		"$set": morphCost,
+	}
+	opts := options.Update().SetUpsert(true)
+	filter := bson.M{constants.MorphFieldNames.TokenId: morphCost.TokenId}
+	_, err = collection.UpdateOne(context.Background(), filter, update, opts)
+	return err
+}
+
+// Prices fetches all records from the morph cost collection.
+//
+// The application needs to track the changes in the morph prices in order to create correct morph history snapshots
+func (r *MongoMorphCosts) Prices() (map[string]float32, error) {
+	collection, err := db.GetMongoDbCollection(r.polymorphDBName, r.priceCollectionName)
+	if err != nil {
+		return nil, err
+	}
+
+	var morphPrices []models.MorphCost
+	results, err := collection.Find(context.Background(), bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	if err := results.All(context.Background(), &morphPrices); err != nil {
+		return nil, err
+	}
+
+	priceMap := make(map[string]float32)
+	for _, price := range morphPrices {
+		priceMap[price.TokenId] = price.Price
+	}
+	return priceMap, nil
+}
@@ -0,0 +1,50 @@
+package repositories
+
+import (
+	"context"
+	"rarity-backend/db"
+	"rarity-backend/helpers"
+	"rarity-backend/structs"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoMorphStats rolls the history collection up into the daily stats collection. Nothing is rolled up if the stats collection isn't configured
+type MongoMorphStats struct {
+	polymorphDBName       string
+	historyCollectionName string
+	statsCollectionName   string
+}
+
+// Rollup aggregates the days since the day before the last rolled up day again, see helpers.DailyMorphStatsRollupSince
+func (r *MongoMorphStats) Rollup() error {
+	if r.statsCollectionName == "" {
+		return nil
+	}
+	stats, err := db.GetMongoDbCollection(r.polymorphDBName, r.statsCollectionName)
+	if err != nil {
+		return err
+	}
+
+	var last structs.DailyMorphStats
+	err = stats.FindOne(context.Background(), bson.M{}, options.FindOne().SetSort(bson.M{"_id": -1})).Decode(&last)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return err
+	}
+	since, err := helpers.DailyMorphStatsRollupSince(last.Day)
+	if err != nil {
+		return err
+	}
+
+	history, err := db.GetMongoDbCollection(r.polymorphDBName, r.historyCollectionName)
+	if err != nil {
+		return err
+	}
+	curr, err := history.Aggregate(context.Background(), helpers.DailyMorphStatsRollupPipeline(since, r.statsCollectionName))
+	if err != nil {
+		return err
+	}
+	return curr.Close(context.Background())
+}
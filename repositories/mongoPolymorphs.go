@@ -0,0 +1,174 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"rarity-backend/constants"
+	"rarity-backend/db"
+	"rarity-backend/models"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoPolymorphs stores the entities in the rarities collection. The rank history collection is optional
+type MongoPolymorphs struct {
+	polymorphDBName           string
+	rarityCollectionName      string
+	rankHistoryCollectionName string
+}
+
+// InsertMints persists all the processed mints in the database in one go.
+//
+// Bulk writing to database saves a lot of time
+func (r *MongoPolymorphs) InsertMints(entities []models.PolymorphEntity) error {
+	collection, err := db.GetMongoDbCollection(r.polymorphDBName, r.rarityCollectionName)
+	if err != nil {
+		return err
+	}
+
+	documents := make([]interface{}, len(entities))
+	for i, entity := range entities {
+		documents[i] = mintDocument(entity)
+	}
+	res, err := collection.InsertMany(context.Background(), documents)
+	if err != nil {
+		return err
+	}
+	log.Println(fmt.Sprintf("Inserted %v polymorphs in DB", len(res.InsertedIDs)))
+	return nil
+}
+
+// mintDocument converts the entity of a minted polymorph to its document. The morph counters start at 0, so they can be filtered and sorted on before the first morph
+func mintDocument(entity models.PolymorphEntity) bson.M {
+	bdoc := bson.M{}
+	json, _ := json.Marshal(entity)
+	bson.UnmarshalExtJSON(json, false, &bdoc)
+	bdoc[constants.MorphFieldNames.Morphs] = 0
+	bdoc[constants.MorphFieldNames.Scrambles] = 0
+	return bdoc
+}
+
+// SaveMorph persists the polymorph entity in the rarities collection.
+//
+// The old gene is appended to the oldGenes field. It's currently used to manually verify if the persisted entities and history snapshot are accurate
+func (r *MongoPolymorphs) SaveMorph(entity models.PolymorphEntity, oldGene string, geneDiff int) (string, error) {
+	collection, err := db.GetMongoDbCollection(r.polymorphDBName, r.rarityCollectionName)
+	if err != nil {
+		return "", err
+	}
+
+	opts := options.Update().SetUpsert(true)
+	filter := bson.M{constants.MorphFieldNames.TokenId: entity.TokenId}
+	update := bson.M{}
+	update["$set"] = entity
+
+	// Polymorphs which weren't stored at mint start with the counters at 0, like minted ones
+	if geneDiff > 0 && geneDiff <= 2 {
+		update["$push"] = bson.M{constants.MorphFieldNames.OldGenes: oldGene}
+		update["$inc"] = bson.M{constants.MorphFieldNames.Morphs: 1}
+		update["$setOnInsert"] = bson.M{constants.MorphFieldNames.Scrambles: 0}
+	} else if geneDiff > 2 {
+		update["$push"] = bson.M{constants.MorphFieldNames.OldGenes: oldGene}
+		update["$inc"] = bson.M{constants.MorphFieldNames.Scrambles: 1}
+		update["$setOnInsert"] = bson.M{constants.MorphFieldNames.Morphs: 0}
+	} else {
+		update["$setOnInsert"] = bson.M{constants.MorphFieldNames.Morphs: 0, constants.MorphFieldNames.Scrambles: 0}
+	}
+	res, err := collection.UpdateOne(context.Background(), filter, update, opts)
+	if err != nil {
+		return "", err
+	}
+
+	if res.UpsertedCount != 0 {
+		return "Inserted id in polymorph db: " + strconv.Itoa(entity.TokenId), nil
+	} else if res.ModifiedCount != 0 {
+		return "Updated id in polymorph db: " + strconv.Itoa(entity.TokenId), nil
+	} else {
+		return "Didn't do shit in polymorph db (probably score is the same): " + strconv.Itoa(entity.TokenId), nil
+	}
+}
+
+// SetMorphCounters overwrites the morph and scramble counters of the polymorph. It's used to repair counters which don't match the history
+func (r *MongoPolymorphs) SetMorphCounters(tokenId int, morphs int, scrambles int) error {
+	collection, err := db.GetMongoDbCollection(r.polymorphDBName, r.rarityCollectionName)
+	if err != nil {
+		return err
+	}
+
+	_, err = collection.UpdateOne(context.Background(), bson.M{constants.MorphFieldNames.TokenId: tokenId}, bson.M{"$set": bson.M{
+		constants.MorphFieldNames.Morphs:    morphs,
+		constants.MorphFieldNames.Scrambles: scrambles,
+	}})
+	return err
+}
+
+// FindAllByRarity fetches at most 10000 entities, which is the supply of the collection
+func (r *MongoPolymorphs) FindAllByRarity() ([]models.PolymorphEntity, error) {
+	collection, err := db.GetMongoDbCollection(r.polymorphDBName, r.rarityCollectionName)
+	if err != nil {
+		return nil, err
+	}
+
+	var findOptions options.FindOptions
+	findOptions.SetLimit(10000)
+	findOptions.SetSort(bson.D{{Key: constants.MorphFieldNames.RarityScore, Value: -1}, {Key: constants.MorphFieldNames.TokenId, Value: 1}})
+	results, err := collection.Find(context.Background(), bson.D{}, &findOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	var entities []models.PolymorphEntity
+	err = results.All(context.Background(), &entities)
+	return entities, err
+}
+
+// UpdateRanks persists the changed ranks in one go. If the rank history collection is set, every change is also recorded in the rank history
+func (r *MongoPolymorphs) UpdateRanks(changes []models.RankChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	collection, err := db.GetMongoDbCollection(r.polymorphDBName, r.rarityCollectionName)
+	if err != nil {
+		return err
+	}
+
+	operations := make([]mongo.WriteModel, len(changes))
+	for i, change := range changes {
+		operation := mongo.NewUpdateOneModel()
+		operation.SetFilter(bson.M{constants.MorphFieldNames.TokenId: change.TokenId})
+		operation.SetUpdate(bson.M{"$set": bson.M{constants.MorphFieldNames.Rank: change.Rank}})
+		operations[i] = operation
+	}
+	res, err := collection.BulkWrite(context.Background(), operations, &options.BulkWriteOptions{})
+	if err != nil {
+		return err
+	}
+	log.Println(fmt.Sprintf("Updated %v entities' rank in polymorph db", res.ModifiedCount))
+
+	if r.rankHistoryCollectionName == "" {
+		return nil
+	}
+	// The ranks are already persisted, so a failed history insert is only logged. Returning it would drop the changes for good
+	if err := r.insertRankHistory(changes); err != nil {
+		log.Println(err)
+	}
+	return nil
+}
+
+func (r *MongoPolymorphs) insertRankHistory(changes []models.RankChange) error {
+	rankHistory, err := db.GetMongoDbCollection(r.polymorphDBName, r.rankHistoryCollectionName)
+	if err != nil {
+		return err
+	}
+	documents := make([]interface{}, len(changes))
+	for i, change := range changes {
+		documents[i] = change
+	}
+	_, err = rankHistory.InsertMany(context.Background(), documents)
+	return err
+}
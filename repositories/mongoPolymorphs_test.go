@@ -0,0 +1,24 @@
+package repositories
+
+import (
+	"rarity-backend/config"
+	"rarity-backend/constants"
+	"rarity-backend/models"
+	"testing"
+)
+
+// The field registry only checks the field names, so every public field has to be written when a polymorph is minted
+func TestMintDocumentHasPublicFields(t *testing.T) {
+	document := mintDocument(models.PolymorphEntity{TokenId: 1})
+	for name, spec := range config.MORPH_FIELDS {
+		if _, ok := document[name]; spec.Public && !ok {
+			t.Errorf("Public field %v isn't stored at mint", name)
+		}
+	}
+
+	for _, counter := range []string{constants.MorphFieldNames.Morphs, constants.MorphFieldNames.Scrambles} {
+		if document[counter] != 0 {
+			t.Errorf("Got %v %v, expected the counter to start at 0", document[counter], counter)
+		}
+	}
+}
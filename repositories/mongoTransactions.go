@@ -0,0 +1,66 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"rarity-backend/db"
+	"rarity-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// MongoTransactions stores the processed morph events in the transactions collection
+type MongoTransactions struct {
+	polymorphDBName            string
+	transactionsCollectionName string
+}
+
+// Save persists the processed transaction in the database
+//
+// If the application stops it will be able to load the processed event in memory from the database
+func (r *MongoTransactions) Save(transaction models.Transaction) error {
+	collection, err := db.GetMongoDbCollection(r.polymorphDBName, r.transactionsCollectionName)
+	if err != nil {
+		return err
+	}
+
+	var bdoc interface{}
+	json, _ := json.Marshal(transaction)
+	bson.UnmarshalExtJSON(json, false, &bdoc)
+	_, err = collection.InsertOne(context.Background(), bdoc)
+	return err
+}
+
+// Processed fetches all records from the transactions collections. Returns a mapping of the records.
+//
+// The application has to know which morph events have already been processed in order to prevent duplicate false information stored in database
+func (r *MongoTransactions) Processed() (map[string]map[uint]bool, error) {
+	collection, err := db.GetMongoDbCollection(r.polymorphDBName, r.transactionsCollectionName)
+	if err != nil {
+		return nil, err
+	}
+
+	var transactions []models.Transaction
+	results, err := collection.Find(context.Background(), bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	if err := results.All(context.Background(), &transactions); err != nil {
+		return nil, err
+	}
+	return transactionsMapping(transactions), nil
+}
+
+// transactionsMapping returns the tx hash -> log index mapping of the transactions
+func transactionsMapping(transactions []models.Transaction) map[string]map[uint]bool {
+	txMap := make(map[string]map[uint]bool)
+	for _, tx := range transactions {
+		nestedMap, ok := txMap[tx.TxHash]
+		if !ok {
+			nestedMap = make(map[uint]bool)
+			txMap[tx.TxHash] = nestedMap
+		}
+		nestedMap[tx.LogIndex] = true
+	}
+	return txMap
+}
@@ -0,0 +1,88 @@
+package repositories
+
+import (
+	"rarity-backend/models"
+	"rarity-backend/structs"
+)
+
+// Repositories contains the stores the ingestion process persists its state to
+type Repositories struct {
+	Polymorphs   PolymorphRepository
+	History      HistoryRepository
+	Transactions TransactionRepository
+	Blocks       BlockRepository
+	MorphCosts   MorphCostRepository
+	MorphStats   MorphStatsRepository
+}
+
+// PolymorphRepository stores the rarity entities of the polymorphs
+type PolymorphRepository interface {
+	// InsertMints inserts the entities of newly minted polymorphs
+	InsertMints(entities []models.PolymorphEntity) error
+	// SaveMorph updates the entity of a morphed polymorph or inserts it if it doesn't exist.
+	//
+	// Depending on the number of gene differences either the scramble or the morph counter is incremented and the old gene is appended to the old genes
+	SaveMorph(entity models.PolymorphEntity, oldGene string, geneDiff int) (string, error)
+	// SetMorphCounters overwrites the morph and scramble counters of the polymorph
+	SetMorphCounters(tokenId int, morphs int, scrambles int) error
+	// FindAllByRarity returns all entities sorted by rarity score descending, ties sorted by token id
+	FindAllByRarity() ([]models.PolymorphEntity, error)
+	// UpdateRanks persists the changed ranks and records them in the rank history
+	UpdateRanks(changes []models.RankChange) error
+}
+
+// HistoryRepository stores the morph and scramble snapshots of the polymorphs
+type HistoryRepository interface {
+	Save(snapshot models.PolymorphHistory) error
+}
+
+// TransactionRepository stores the processed morph events, so they aren't processed twice after a restart
+type TransactionRepository interface {
+	Save(transaction models.Transaction) error
+	// Processed returns a tx hash -> log index mapping of the processed morph events
+	Processed() (map[string]map[uint]bool, error)
+}
+
+// BlockRepository stores the last processed block
+type BlockRepository interface {
+	// LastProcessed returns 0 if no block has been processed yet
+	LastProcessed() (int64, error)
+	SaveLastProcessed(number uint64) (string, error)
+}
+
+// MorphCostRepository stores the current morph price of each polymorph
+type MorphCostRepository interface {
+	Save(morphCost models.MorphCost) error
+	// Prices returns a token id -> morph price mapping
+	Prices() (map[string]float32, error)
+}
+
+// MorphStatsRepository stores the daily morph stats rolled up from the history
+type MorphStatsRepository interface {
+	// Rollup aggregates the snapshots saved since the last rollup into the daily stats
+	Rollup() error
+}
+
+// NewMongoRepositories returns the repositories backed by the collections of dbInfo
+func NewMongoRepositories(dbInfo structs.DBInfo) Repositories {
+	return Repositories{
+		Polymorphs:   &MongoPolymorphs{dbInfo.PolymorphDBName, dbInfo.RarityCollectionName, dbInfo.RankHistoryCollectionName},
+		History:      &MongoHistory{dbInfo.PolymorphDBName, dbInfo.HistoryCollectionName},
+		Transactions: &MongoTransactions{dbInfo.PolymorphDBName, dbInfo.TransactionsCollectionName},
+		Blocks:       &MongoBlocks{dbInfo.PolymorphDBName, dbInfo.BlocksCollectionName},
+		MorphCosts:   &MongoMorphCosts{dbInfo.PolymorphDBName, dbInfo.MorphCostCollectionName},
+		MorphStats:   &MongoMorphStats{dbInfo.PolymorphDBName, dbInfo.HistoryCollectionName, dbInfo.MorphStatsCollectionName},
+	}
+}
+
+// NewMemoryRepositories returns empty in-memory repositories. They aren't persisted and are meant for tests
+func NewMemoryRepositories() Repositories {
+	return Repositories{
+		Polymorphs:   &MemoryPolymorphs{records: make(map[int]*MemoryPolymorph)},
+		History:      &MemoryHistory{},
+		Transactions: &MemoryTransactions{},
+		Blocks:       &MemoryBlocks{},
+		MorphCosts:   &MemoryMorphCosts{prices: make(map[string]float32)},
+		MorphStats:   &MemoryMorphStats{},
+	}
+}
@@ -0,0 +1,306 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"math"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"rarity-backend/constants"
+	"rarity-backend/dlt"
+	"rarity-backend/store"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// testContract is the address the fake node emits the polymorph events from
+var testContract = common.HexToAddress("0x1000000000000000000000000000000000000001")
+
+// testBlockTime is the time of block 0, every following block is 15 seconds later
+const testBlockTime = 1600000000
+
+// fakeNode answers the JSON-RPC calls the ingestion makes with recorded fixture blocks, logs, transactions and genes.
+//
+// Events are emitted in the block after the head. geneOf returns the genes at the end of the called block, so the new genes of the latest state right away
+type fakeNode struct {
+	t           *testing.T
+	contractAbi abi.ABI
+	mutex       sync.Mutex
+	head        uint64
+	logs        []types.Log
+	// genes are the genes each polymorph got in the order of their blocks
+	genes map[int64][]blockGene
+	// transactions by block hash and transaction index
+	transactions map[common.Hash]map[uint]*types.Transaction
+	senders      map[common.Hash]common.Address
+	nonce        uint64
+	// minter signs the transactions of the mints
+	minter *ecdsa.PrivateKey
+}
+
+// newFakeNode starts the node and returns it with the chain of an ethclient connected to it
+func newFakeNode(t *testing.T, wallets ...*ecdsa.PrivateKey) (chainFixture, dlt.Chain) {
+	t.Helper()
+	contractAbi, err := abi.JSON(strings.NewReader(store.StoreABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := &fakeNode{
+		t:            t,
+		minter:       newTestKey(t),
+		contractAbi:  contractAbi,
+		genes:        make(map[int64][]blockGene),
+		transactions: make(map[common.Hash]map[uint]*types.Transaction),
+		senders:      make(map[common.Hash]common.Address),
+	}
+
+	server := httptest.NewServer(node)
+	t.Cleanup(server.Close)
+	client, err := ethclient.Dial(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Close)
+	chain, err := dlt.NewContractChain(client, testContract)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return node, chain
+}
+
+func (n *fakeNode) address() common.Address {
+	return testContract
+}
+
+func (n *fakeNode) commit() uint64 {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.head++
+	return n.head
+}
+
+func (n *fakeNode) mint(tokenId int64, gene string) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.setGene(tokenId, gene)
+	data, err := n.contractAbi.Events[constants.MintEvent.Name].Inputs.NonIndexed().Pack(toBig(n.t, gene))
+	if err != nil {
+		n.t.Fatal(err)
+	}
+	n.emit(constants.MintEvent.Name, tokenId, data, n.minter)
+}
+
+func (n *fakeNode) morph(tokenId int64, oldGene string, newGene string, price int64, wallet *ecdsa.PrivateKey) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.setGene(tokenId, newGene)
+	data, err := n.contractAbi.Events[constants.MorphEvent.Name].Inputs.NonIndexed().Pack(toBig(n.t, oldGene), toBig(n.t, oldGene), big.NewInt(price), uint8(1))
+	if err != nil {
+		n.t.Fatal(err)
+	}
+	n.emit(constants.MorphEvent.Name, tokenId, data, wallet)
+}
+
+// emit appends the log of an event in its own transaction signed by key
+// blockGene is a gene a polymorph got in a block
+type blockGene struct {
+	block uint64
+	gene  *big.Int
+}
+
+// setGene records the gene of the event emitted in the block after the head
+func (n *fakeNode) setGene(tokenId int64, gene string) {
+	n.genes[tokenId] = append(n.genes[tokenId], blockGene{block: n.head + 1, gene: toBig(n.t, gene)})
+}
+
+// geneAt returns the gene of the polymorph at the end of the block, or 0 if it wasn't minted yet
+func (n *fakeNode) geneAt(tokenId int64, block uint64) *big.Int {
+	gene := big.NewInt(0)
+	for _, g := range n.genes[tokenId] {
+		if g.block <= block {
+			gene = g.gene
+		}
+	}
+	return gene
+}
+
+func (n *fakeNode) emit(event string, tokenId int64, data []byte, key *ecdsa.PrivateKey) {
+	block := n.head + 1
+	blockHash := testHeader(block).Hash()
+	if n.transactions[blockHash] == nil {
+		n.transactions[blockHash] = make(map[uint]*types.Transaction)
+	}
+	txIndex := uint(len(n.transactions[blockHash]))
+
+	tx, err := types.SignTx(types.NewTransaction(n.nonce, testContract, big.NewInt(0), 100000, big.NewInt(1), nil), types.LatestSignerForChainID(big.NewInt(1)), key)
+	if err != nil {
+		n.t.Fatal(err)
+	}
+	n.nonce++
+	n.senders[tx.Hash()] = crypto.PubkeyToAddress(key.PublicKey)
+	n.transactions[blockHash][txIndex] = tx
+
+	n.logs = append(n.logs, types.Log{
+		Address:     testContract,
+		Topics:      []common.Hash{n.contractAbi.Events[event].ID, common.BigToHash(big.NewInt(tokenId))},
+		Data:        data,
+		BlockNumber: block,
+		TxHash:      tx.Hash(),
+		TxIndex:     txIndex,
+		BlockHash:   blockHash,
+		Index:       txIndex,
+	})
+}
+
+type rpcRequest struct {
+	Id     json.RawMessage   `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+func (n *fakeNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var request rpcRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	n.mutex.Lock()
+	result, err := n.call(request)
+	n.mutex.Unlock()
+
+	response := map[string]interface{}{"jsonrpc": "2.0", "id": request.Id}
+	if err != nil {
+		response["error"] = map[string]interface{}{"code": -32000, "message": err.Error()}
+	} else {
+		response["result"] = result
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (n *fakeNode) call(request rpcRequest) (interface{}, error) {
+	switch request.Method {
+	case "eth_getBlockByNumber":
+		var number string
+		json.Unmarshal(request.Params[0], &number)
+		block := n.head
+		if number != "latest" {
+			block = hexutil.MustDecodeUint64(number)
+		}
+		if block > n.head {
+			return nil, nil
+		}
+		return testBlock(block)
+	case "eth_getLogs":
+		var query struct {
+			FromBlock string `json:"fromBlock"`
+			ToBlock   string `json:"toBlock"`
+		}
+		json.Unmarshal(request.Params[0], &query)
+		from, to := hexutil.MustDecodeUint64(query.FromBlock), hexutil.MustDecodeUint64(query.ToBlock)
+		logs := []types.Log{}
+		for _, ethLog := range n.logs {
+			if ethLog.BlockNumber >= from && ethLog.BlockNumber <= to {
+				logs = append(logs, ethLog)
+			}
+		}
+		return logs, nil
+	case "eth_call":
+		var call struct {
+			Data hexutil.Bytes `json:"data"`
+		}
+		var number string
+		json.Unmarshal(request.Params[0], &call)
+		json.Unmarshal(request.Params[1], &number)
+		// The genes of the latest state include the events emitted after the head
+		block := uint64(math.MaxUint64)
+		if number != "latest" {
+			block = hexutil.MustDecodeUint64(number)
+		}
+		gene := n.geneAt(new(big.Int).SetBytes(call.Data[4:]).Int64(), block)
+		output, err := n.contractAbi.Methods["geneOf"].Outputs.Pack(gene)
+		return hexutil.Bytes(output), err
+	case "eth_getTransactionByBlockHashAndIndex":
+		var blockHash common.Hash
+		var index hexutil.Uint
+		json.Unmarshal(request.Params[0], &blockHash)
+		json.Unmarshal(request.Params[1], &index)
+		tx, ok := n.transactions[blockHash][uint(index)]
+		if !ok {
+			return nil, nil
+		}
+		return testTransaction(tx, blockHash, uint(index), n.senders[tx.Hash()])
+	}
+	n.t.Errorf("Unexpected JSON-RPC call %v", request.Method)
+	return nil, nil
+}
+
+// testHeader returns the header of the block. Headers depend only on the number, so every block has a stable hash
+func testHeader(block uint64) *types.Header {
+	return &types.Header{
+		UncleHash:  types.EmptyUncleHash,
+		TxHash:     types.EmptyRootHash,
+		Difficulty: big.NewInt(1),
+		Number:     new(big.Int).SetUint64(block),
+		GasLimit:   8000000,
+		Time:       testBlockTime + block*15,
+	}
+}
+
+// testBlock returns the JSON of the block without transactions, which is enough for the header and the block time
+func testBlock(block uint64) (map[string]interface{}, error) {
+	encoded, err := json.Marshal(testHeader(block))
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		return nil, err
+	}
+	fields["transactions"] = []interface{}{}
+	fields["uncles"] = []interface{}{}
+	return fields, nil
+}
+
+// testTransaction returns the JSON of the transaction in a block, including the sender
+func testTransaction(tx *types.Transaction, blockHash common.Hash, index uint, sender common.Address) (map[string]interface{}, error) {
+	encoded, err := tx.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		return nil, err
+	}
+	fields["blockHash"] = blockHash
+	fields["transactionIndex"] = hexutil.Uint(index)
+	fields["from"] = sender
+	return fields, nil
+}
+
+func newTestKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func toBig(t *testing.T, value string) *big.Int {
+	number, ok := new(big.Int).SetString(value, 10)
+	if !ok {
+		t.Fatalf("Invalid number %v", value)
+	}
+	return number
+}
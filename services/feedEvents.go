@@ -0,0 +1,123 @@
+package services
+
+import (
+	"log"
+	"math/big"
+	"rarity-backend/config"
+	"rarity-backend/dlt"
+	"rarity-backend/events"
+	"rarity-backend/metadata"
+	"rarity-backend/models"
+	"rarity-backend/structs"
+	"rarity-backend/webhooks"
+	"strconv"
+	"time"
+)
+
+// mintFeedEvents creates the feed events of the persisted mints
+func mintFeedEvents(mints []models.PolymorphEntity, configService *structs.ConfigService) []structs.FeedEvent {
+	var feedEvents []structs.FeedEvent
+	now := time.Now().UTC()
+	for _, mint := range mints {
+		feedEvents = append(feedEvents, structs.FeedEvent{
+			Type:      events.MINT,
+			TokenId:   mint.TokenId,
+			NewTraits: geneTraits(mint.CurrentGene, mint.TokenId, configService),
+			NewScore:  mint.RarityScore,
+			Time:      now,
+		})
+	}
+	return feedEvents
+}
+
+// morphFeedEvent creates the feed event of a morph or scramble history snapshot.
+//
+// The scores are calculated from the genes without the virgin scaler, because the polymorph isn't virgin after the change.
+// CompletedSet is set if the new traits complete a set the old traits didn't
+func morphFeedEvent(snapshot models.PolymorphHistory, configService *structs.ConfigService, rarityModel structs.RarityModel) structs.FeedEvent {
+	oldTraits := geneTraits(snapshot.OldGene, snapshot.TokenId, configService)
+	newTraits := geneTraits(snapshot.NewGene, snapshot.TokenId, configService)
+	oldRarity := CalulateRarityScore(oldTraits, false, rarityModel)
+	newRarity := CalulateRarityScore(newTraits, false, rarityModel)
+
+	event := structs.FeedEvent{
+		Type:         events.MORPH,
+		TokenId:      snapshot.TokenId,
+		ChangedTrait: snapshot.AttributeChanged,
+		OldTraits:    oldTraits,
+		NewTraits:    newTraits,
+		OldScore:     oldRarity.ScaledRarity,
+		NewScore:     newRarity.ScaledRarity,
+		Time:         snapshot.DateTime,
+	}
+	if newRarity.HasCompletedSet && (!oldRarity.HasCompletedSet || oldRarity.MainSetName != newRarity.MainSetName) {
+		event.CompletedSet = newRarity.MainSetName
+	}
+	if snapshot.Type == "Scramble" {
+		event.Type = events.SCRAMBLE
+		event.ChangedTrait = ""
+	}
+	return event
+}
+
+// rankFeedEvents creates the feed events of the rank changes of at least config.FEED_RANK_CHANGE_THRESHOLD places. The first ranks of new polymorphs are skipped
+func rankFeedEvents(changes []models.RankChange) []structs.FeedEvent {
+	var feedEvents []structs.FeedEvent
+	for _, change := range changes {
+		moved := change.Rank - change.PreviousRank
+		if moved < 0 {
+			moved = -moved
+		}
+		if change.PreviousRank == 0 || moved < config.FEED_RANK_CHANGE_THRESHOLD {
+			continue
+		}
+		feedEvents = append(feedEvents, structs.FeedEvent{
+			Type:    events.RANK_CHANGE,
+			TokenId: change.TokenId,
+			OldRank: change.PreviousRank,
+			NewRank: change.Rank,
+			Time:    change.DateTime,
+		})
+	}
+	return feedEvents
+}
+
+// dispatchRankWebhooks sends the rank changes watched by rank webhooks with the owner of the polymorph.
+//
+// Owners are only looked up for the changes which are large enough for at least one webhook
+func dispatchRankWebhooks(dispatcher *webhooks.Dispatcher, chain dlt.Chain, changes []models.RankChange) {
+	minRankChange, ok := dispatcher.MinRankChange()
+	if !ok {
+		return
+	}
+	for _, change := range changes {
+		moved := change.Rank - change.PreviousRank
+		if moved < 0 {
+			moved = -moved
+		}
+		if change.PreviousRank == 0 || moved < minRankChange {
+			continue
+		}
+		owner, err := chain.OwnerOf(big.NewInt(int64(change.TokenId)))
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		dispatcher.Dispatch(structs.FeedEvent{
+			Type:    events.RANK_CHANGE,
+			TokenId: change.TokenId,
+			OldRank: change.PreviousRank,
+			NewRank: change.Rank,
+			Owner:   owner.Hex(),
+			Time:    change.DateTime,
+		})
+	}
+}
+
+func geneTraits(gene string, tokenId int, configService *structs.ConfigService) []structs.Attribute {
+	if gene == "" {
+		return nil
+	}
+	g := metadata.Genome(gene)
+	return (&g).Metadata(strconv.Itoa(tokenId), configService).Attributes
+}
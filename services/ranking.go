@@ -0,0 +1,30 @@
+package services
+
+import (
+	"rarity-backend/models"
+	"rarity-backend/repositories"
+	"time"
+)
+
+// UpdateAllRanking fetches all polymorphs sorted by rarity and ranks them in that order.
+//
+// Only the changed ranks are persisted. Returns the rank changes, or an error if they couldn't be persisted.
+// The changes aren't returned in that case because they will be computed again by the next ranking
+func UpdateAllRanking(polymorphs repositories.PolymorphRepository) ([]models.RankChange, error) {
+	entities, err := polymorphs.FindAllByRarity()
+	if err != nil {
+		return nil, err
+	}
+
+	rankedAt := time.Now()
+	var changes []models.RankChange
+	for i, entity := range entities {
+		if newRank := i + 1; entity.Rank != newRank {
+			changes = append(changes, models.RankChange{TokenId: entity.TokenId, Rank: newRank, PreviousRank: entity.Rank, DateTime: rankedAt})
+		}
+	}
+	if err := polymorphs.UpdateRanks(changes); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
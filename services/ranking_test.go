@@ -0,0 +1,55 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"rarity-backend/models"
+	"rarity-backend/repositories"
+)
+
+// failingRanks fails to persist rank changes while failing is set
+type failingRanks struct {
+	*repositories.MemoryPolymorphs
+	failing bool
+}
+
+func (r *failingRanks) UpdateRanks(changes []models.RankChange) error {
+	if r.failing {
+		return errors.New("write failed")
+	}
+	return r.MemoryPolymorphs.UpdateRanks(changes)
+}
+
+func TestUpdateAllRanking(t *testing.T) {
+	memory := repositories.NewMemoryRepositories().Polymorphs.(*repositories.MemoryPolymorphs)
+	memory.InsertMints([]models.PolymorphEntity{
+		{TokenId: 1, RarityScore: 5},
+		{TokenId: 2, RarityScore: 9},
+		{TokenId: 3, RarityScore: 9},
+	})
+	polymorphs := &failingRanks{MemoryPolymorphs: memory, failing: true}
+
+	changes, err := UpdateAllRanking(polymorphs)
+	if err == nil || changes != nil {
+		t.Fatalf("Got %v and %v, expected the write error without changes", changes, err)
+	}
+
+	// The changes which couldn't be persisted are computed again
+	polymorphs.failing = false
+	changes, err = UpdateAllRanking(polymorphs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ranks := map[int]int{}
+	for _, change := range changes {
+		ranks[change.TokenId] = change.Rank
+	}
+	if len(changes) != 3 || ranks[2] != 1 || ranks[3] != 2 || ranks[1] != 3 {
+		t.Errorf("Got changes %+v, expected ranks 2, 3, 1", changes)
+	}
+
+	if changes, err = UpdateAllRanking(polymorphs); err != nil || len(changes) != 0 {
+		t.Errorf("Got %v and %v, expected no changes once the ranks are persisted", changes, err)
+	}
+}
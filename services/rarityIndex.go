@@ -15,14 +15,14 @@ import (
 //
 // It calculates the rarity score of the polymorph, the different scalers used in the formuala and other rarity related metadata that is tracked and stored in the database.
 //
-// Configurations can be found in rarityConfig.go
-func CalulateRarityScore(attributes []structs.Attribute, isVirgin bool) structs.RarityResult {
+// The sets and hands are taken from the rarity model of the collection. Configurations can be found in rarityConfig.go
+func CalulateRarityScore(attributes []structs.Attribute, isVirgin bool, rarityModel structs.RarityModel) structs.RarityResult {
 	leftHand, rightHand, rarityAttributes := parseAttributes(attributes)
 
-	hasCompletedSet, setName, mainMatchingTraits, secSetname, secMatchingTraits := calculateCompleteSets(rarityAttributes)
+	hasCompletedSet, setName, mainMatchingTraits, secSetname, secMatchingTraits := calculateCompleteSets(rarityAttributes, rarityModel.CombosMap)
 	isColoredSet, colorMismatches := getColorMismatches(attributes, setName)
 	scalers := getScalers(hasCompletedSet, setName, colorMismatches, isVirgin, isColoredSet)
-	handsScaler, handsSetName, matchingHandsCount, mainMatchingTraitsWithHands := getFullSetHandsScaler(mainMatchingTraits, hasCompletedSet, setName, leftHand, rightHand)
+	handsScaler, handsSetName, matchingHandsCount, mainMatchingTraitsWithHands := getFullSetHandsScaler(mainMatchingTraits, hasCompletedSet, setName, leftHand, rightHand, rarityModel.HandsMap)
 
 	mainSetCount := float64(len(mainMatchingTraits))
 	secSetBonus := config.SECONDARY_SET_SCALER * float64(len(secMatchingTraits))
@@ -136,11 +136,11 @@ func getColorMismatches(attributes []structs.Attribute, longestSet string) (bool
 
 // getFullSetHandsScaler calculates the correct hands scaler based on the state of the set(no, incomplete or completed set)
 func getFullSetHandsScaler(mainMatchingTraits []string, hasCompletedSet bool, completedSetName string,
-	leftHandAttr structs.Attribute, rightHandAttr structs.Attribute) (float64, string, int, []string) {
+	leftHandAttr structs.Attribute, rightHandAttr structs.Attribute, handsMap map[string][]string) (float64, string, int, []string) {
 	var matchingSetHandsCount int
 
 	// Match left hand
-	for _, handAttribute := range config.HandsMap[completedSetName] {
+	for _, handAttribute := range handsMap[completedSetName] {
 		if handAttribute == leftHandAttr.Value {
 			matchingSetHandsCount++
 			mainMatchingTraits = append(mainMatchingTraits, leftHandAttr.TraitType)
@@ -149,7 +149,7 @@ func getFullSetHandsScaler(mainMatchingTraits []string, hasCompletedSet bool, co
 	}
 
 	// Match right hand
-	for _, handAttribute := range config.HandsMap[completedSetName] {
+	for _, handAttribute := range handsMap[completedSetName] {
 		if handAttribute == rightHandAttr.Value {
 			matchingSetHandsCount++
 			mainMatchingTraits = append(mainMatchingTraits, rightHandAttr.TraitType)
@@ -199,7 +199,7 @@ func getFullSetHandsScaler(mainMatchingTraits []string, hasCompletedSet bool, co
 // calculateCompleteSets iterates over polymorph's attributes.
 //
 // Return if set has been completed, main set name, main set attrbiutes, secondary set name, secondary set attributes
-func calculateCompleteSets(attributes []structs.Attribute) (bool, string, []string, string, []string) {
+func calculateCompleteSets(attributes []structs.Attribute, combosMap map[string]int) (bool, string, []string, string, []string) {
 	var hasCompletedSet bool
 	var mainSet int
 	var mainSetName string
@@ -211,7 +211,7 @@ func calculateCompleteSets(attributes []structs.Attribute) (bool, string, []stri
 		for _, set := range attr.Sets {
 			setMap[set]++
 			setTraitsMap[set] = append(setTraitsMap[set], attr.TraitType)
-			if setMap[set] == config.CombosMap[set] {
+			if setMap[set] == combosMap[set] {
 				hasCompletedSet = true
 				mainSetName = set
 				mainSet = setMap[set]
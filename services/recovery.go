@@ -2,35 +2,68 @@ package services
 
 import (
 	"context"
-	"encoding/json"
 	"log"
 	"math/big"
+	"rarity-backend/cache"
 	"rarity-backend/constants"
 	"rarity-backend/dlt"
-	"rarity-backend/handlers"
+	"rarity-backend/events"
 	"rarity-backend/helpers"
 	"rarity-backend/metadata"
 	"rarity-backend/models"
-	"rarity-backend/store"
+	"rarity-backend/repositories"
 	"rarity-backend/structs"
+	"rarity-backend/webhooks"
+	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/ethereum/go-ethereum/accounts/abi"
-	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/core/types"
-	"go.mongodb.org/mongo-driver/bson"
 )
 
+// backgroundWrites tracks the database writes which run in the background, so commands can wait for them before exiting
+var backgroundWrites sync.WaitGroup
+
+// inBackground runs the write in a new goroutine tracked by backgroundWrites and by the writes of the poll
+func inBackground(pollWrites *sync.WaitGroup, write func()) {
+	backgroundWrites.Add(1)
+	pollWrites.Add(1)
+	go func() {
+		defer backgroundWrites.Done()
+		defer pollWrites.Done()
+		write()
+	}()
+}
+
+// WaitForBackgroundWrites blocks until the history snapshots, morph prices and transactions saved in the background are persisted
+func WaitForBackgroundWrites() {
+	backgroundWrites.Wait()
+}
+
 // RecoverProcess is the main function which handles the polling and processing of mint and morph events
-func RecoverProcess(ethClient *dlt.EthereumClient, contractAbi abi.ABI, instance *store.Store, address string, configService *structs.ConfigService,
-	dbInfo structs.DBInfo, txState map[string]map[uint]bool, morphCostMap map[string]float32) {
+func RecoverProcess(chain dlt.Chain, contractAbi abi.ABI, address string, configService *structs.ConfigService, rarityModel structs.RarityModel,
+	dbInfo structs.DBInfo, repos repositories.Repositories, txState map[string]map[uint]bool, morphCostMap map[string]float32, imageGenerator *metadata.ImageGenerator, responseCache *cache.ResponseCache, eventHub *events.Hub, webhookDispatcher *webhooks.Dispatcher) {
+	processBlocks(chain, contractAbi, address, configService, rarityModel, dbInfo, repos, txState, morphCostMap, imageGenerator, responseCache, eventHub, webhookDispatcher, 0, 0)
+}
+
+// processBlocks processes the mint and morph events from startBlock to endBlock. A zero startBlock continues after the last processed block and a zero endBlock processes until the latest block.
+//
+// The state is persisted to repos, dbInfo is only used for the response cache namespace.
+//
+// Returns the last processed block
+func processBlocks(chain dlt.Chain, contractAbi abi.ABI, address string, configService *structs.ConfigService, rarityModel structs.RarityModel,
+	dbInfo structs.DBInfo, repos repositories.Repositories, txState map[string]map[uint]bool, morphCostMap map[string]float32, imageGenerator *metadata.ImageGenerator, responseCache *cache.ResponseCache, eventHub *events.Hub, webhookDispatcher *webhooks.Dispatcher,
+	startBlock int64, endBlock int64) uint64 {
 	var wg sync.WaitGroup
+	// pollWrites tracks the background writes of this poll, so the responses are only invalidated once they are persisted
+	var pollWrites sync.WaitGroup
 	mintsMutex := structs.MintsMutex{TokensMap: make(map[string]bool)}
 	eventLogsMutex := structs.EventLogsMutex{EventLogs: []types.Log{}}
 	genesMap := make(map[string]string)
 	tokenToMorphEvent := make(map[string]types.Log)
 
-	lastProcessedBlockNumber := collectEvents(ethClient, contractAbi, instance, address, configService, dbInfo.PolymorphDBName, dbInfo.RarityCollectionName, dbInfo.BlocksCollectionName, 0, 0, &wg, &eventLogsMutex)
+	lastProcessedBlockNumber := collectEvents(chain, address, repos.Blocks, startBlock, endBlock, &wg, &eventLogsMutex)
 
 	// Persist mints
 	for _, ethLog := range eventLogsMutex.EventLogs {
@@ -38,13 +71,16 @@ func RecoverProcess(ethClient *dlt.EthereumClient, contractAbi abi.ABI, instance
 		switch eventSig {
 		case constants.MintEvent.Signature:
 			wg.Add(1)
-			go processMint(ethLog, &wg, contractAbi, configService, dbInfo.PolymorphDBName, dbInfo.RarityCollectionName, &mintsMutex)
+			go processMint(ethLog, &wg, contractAbi, configService, rarityModel, &mintsMutex)
 		}
 	}
 
 	wg.Wait()
-	if len(mintsMutex.Documents) > 0 {
-		handlers.PersistMintEvents(mintsMutex.Documents, dbInfo.PolymorphDBName, dbInfo.RarityCollectionName)
+	if len(mintsMutex.Mints) > 0 {
+		if err := repos.Polymorphs.InsertMints(mintsMutex.Mints); err != nil {
+			log.Fatal(err)
+		}
+		eventHub.Publish(mintFeedEvents(mintsMutex.Mints, configService)...)
 	}
 
 	// Sort polymorphs
@@ -54,31 +90,43 @@ func RecoverProcess(ethClient *dlt.EthereumClient, contractAbi abi.ABI, instance
 		eventSig := ethLog.Topics[0].String()
 		switch eventSig {
 		case constants.MorphEvent.Signature:
-			processInitialMorphs(ethLog, ethClient, contractAbi, instance, configService, dbInfo, txState, genesMap, tokenToMorphEvent, morphCostMap)
+			processInitialMorphs(ethLog, chain, contractAbi, configService, rarityModel, repos, &pollWrites, txState, genesMap, tokenToMorphEvent, morphCostMap, imageGenerator, eventHub, webhookDispatcher)
 		}
 	}
 
 	// Persist final scrambles
 	for id := range genesMap {
 		ethLog := tokenToMorphEvent[id]
-		processFinalMorphs(ethLog, ethClient, contractAbi, instance, configService, dbInfo, txState, genesMap, morphCostMap)
+		processFinalMorphs(ethLog, chain, contractAbi, configService, rarityModel, repos, &pollWrites, txState, genesMap, morphCostMap, imageGenerator, eventHub, webhookDispatcher)
 	}
 
-	// Persist Ranking
-	handlers.UpdateAllRanking(dbInfo.PolymorphDBName, dbInfo.RarityCollectionName)
+	// Persist Ranking. Rank changes which couldn't be persisted aren't published, the next poll ranks them again
+	rankChanges, err := UpdateAllRanking(repos.Polymorphs)
+	if err != nil {
+		log.Println(err)
+	}
+	// The rollup and the invalidated responses must see the snapshots saved in the background
+	pollWrites.Wait()
+	if err := repos.MorphStats.Rollup(); err != nil {
+		log.Println(err)
+	}
+	invalidateResponses(responseCache, dbInfo, mintsMutex.TokensMap, genesMap, rankChanges)
+	eventHub.Publish(rankFeedEvents(rankChanges)...)
+	dispatchRankWebhooks(webhookDispatcher, chain, rankChanges)
 	// Persist block
-	res, err := handlers.CreateOrUpdateLastProcessedBlock(lastProcessedBlockNumber, dbInfo.PolymorphDBName, dbInfo.BlocksCollectionName)
+	res, err := repos.Blocks.SaveLastProcessed(lastProcessedBlockNumber)
 	if err != nil {
 		log.Println(err)
 	} else {
 		log.Println(res)
 	}
+	return lastProcessedBlockNumber
 }
 
 // processMint is the core function for processing mint events metadata. It unpacks event data, calculates rarity score, prepares database entity but doesn't persist it
 //
 // Uses Mutes and WaitGroup in order to process events faster and prevent race conditions.
-func processMint(mintEvent types.Log, wg *sync.WaitGroup, contractAbi abi.ABI, configService *structs.ConfigService, polymorphDBName string, rarityCollectionName string, mintsMutex *structs.MintsMutex) {
+func processMint(mintEvent types.Log, wg *sync.WaitGroup, contractAbi abi.ABI, configService *structs.ConfigService, rarityModel structs.RarityModel, mintsMutex *structs.MintsMutex) {
 	defer wg.Done()
 	var event structs.PolymorphEvent
 	mintsMutex.Mutex.Lock()
@@ -88,15 +136,11 @@ func processMint(mintEvent types.Log, wg *sync.WaitGroup, contractAbi abi.ABI, c
 	if event.NewGene.String() != "0" && !mintsMutex.TokensMap[event.MorphId.String()] {
 		g := metadata.Genome(event.NewGene.String())
 		metadataJson := (&g).Metadata(event.MorphId.String(), configService)
-		rarityResult := CalulateRarityScore(metadataJson.Attributes, true)
+		rarityResult := CalulateRarityScore(metadataJson.Attributes, true, rarityModel)
 		mintEntity := helpers.CreateMorphEntity(event, metadataJson, true, rarityResult)
 
 		mintsMutex.Mints = append(mintsMutex.Mints, mintEntity)
 		mintsMutex.TokensMap[event.MorphId.String()] = true
-		var bdoc interface{}
-		json, _ := json.Marshal(mintEntity)
-		bson.UnmarshalExtJSON(json, false, &bdoc)
-		mintsMutex.Documents = append(mintsMutex.Documents, bdoc)
 	} else {
 		log.Println("Empty gene mint event for morph id: " + event.MorphId.String())
 	}
@@ -118,8 +162,8 @@ func processMint(mintEvent types.Log, wg *sync.WaitGroup, contractAbi abi.ABI, c
 // We save the new gene to the oldGenesMap and repeat the process for the next event for this polymorph.
 //
 // !! It's important to note which gene is passed as the new one and which as the old one in order to understand how the logic works.
-func processInitialMorphs(morphEvent types.Log, ethClient *dlt.EthereumClient, contractAbi abi.ABI, instance *store.Store, configService *structs.ConfigService, dbInfo structs.DBInfo,
-	txState map[string]map[uint]bool, oldGenesMap map[string]string, tokenToMorphEvent map[string]types.Log, morphCostMap map[string]float32) {
+func processInitialMorphs(morphEvent types.Log, chain dlt.Chain, contractAbi abi.ABI, configService *structs.ConfigService, rarityModel structs.RarityModel, repos repositories.Repositories, pollWrites *sync.WaitGroup,
+	txState map[string]map[uint]bool, oldGenesMap map[string]string, tokenToMorphEvent map[string]types.Log, morphCostMap map[string]float32, imageGenerator *metadata.ImageGenerator, eventHub *events.Hub, webhookDispatcher *webhooks.Dispatcher) {
 	var mEvent structs.MorphedEvent
 	err := contractAbi.UnpackIntoInterface(&mEvent, constants.MorphEvent.Name, morphEvent.Data)
 	if err != nil {
@@ -135,25 +179,32 @@ func processInitialMorphs(morphEvent types.Log, ethClient *dlt.EthereumClient, c
 		mId := morphEvent.Topics[1].Big()
 
 		// This will get the newest gene
-		result, err := instance.GeneOf(&bind.CallOpts{}, mId)
+		result, err := chain.GeneOf(mId)
 		if err != nil {
 			log.Println(err)
 		}
 		mEvent.NewGene = result
 		var geneDifferences, geneIdx int
 		newAttr, oldAttr := structs.Attribute{}, structs.Attribute{}
+		var feedEvent *structs.FeedEvent
 		if oldGenesMap[mId.String()] != "" {
 			geneIdx, geneDifferences = helpers.DetectGeneDifferences(oldGenesMap[mId.String()], mEvent.OldGene.String())
 			if geneDifferences <= 2 {
 				newAttr, oldAttr = helpers.GetAttribute(mEvent.OldGene.String(), oldGenesMap[mId.String()], geneIdx, configService)
 			}
-			block, err := ethClient.Client.BlockByNumber(context.Background(), big.NewInt(int64(morphEvent.BlockNumber)))
+			block, err := chain.HeaderByNumber(context.Background(), big.NewInt(int64(morphEvent.BlockNumber)))
 			if err != nil {
 				log.Println(err)
 			}
-			polySnapshot := helpers.CreateMorphSnapshot(geneDifferences, mId.String(), mEvent.OldGene.String(), oldGenesMap[mId.String()], block.Time(), oldAttr, newAttr, morphCostMap, configService)
-			go handlers.SavePolymorphHistory(polySnapshot, dbInfo.PolymorphDBName, dbInfo.HistoryCollectionName)
-			go handlers.SaveMorphPrice(models.MorphCost{TokenId: mId.String(), Price: morphCostMap[mId.String()]}, dbInfo.PolymorphDBName, dbInfo.MorphCostCollectionName)
+			polySnapshot := helpers.CreateMorphSnapshot(geneDifferences, mId.String(), mEvent.OldGene.String(), oldGenesMap[mId.String()], block.Time, oldAttr, newAttr, morphCostMap, configService)
+			// The changes were made by the previous morph event of the polymorph
+			polySnapshot.Wallet = morphSender(chain, tokenToMorphEvent[mId.String()])
+			inBackground(pollWrites, func() { saveSnapshot(repos.History, polySnapshot) })
+			renderImage(imageGenerator, polySnapshot.NewGene, configService)
+			morphCost := models.MorphCost{TokenId: mId.String(), Price: morphCostMap[mId.String()]}
+			inBackground(pollWrites, func() { saveMorphCost(repos.MorphCosts, morphCost) })
+			event := morphFeedEvent(polySnapshot, configService, rarityModel)
+			feedEvent = &event
 		}
 		toSaveGene := oldGenesMap[mId.String()]
 		oldGenesMap[mId.String()] = mEvent.OldGene.String()
@@ -162,14 +213,18 @@ func processInitialMorphs(morphEvent types.Log, ethClient *dlt.EthereumClient, c
 		g := metadata.Genome(mEvent.NewGene.String())
 		metadataJson := (&g).Metadata(mId.String(), configService)
 
-		rarityResult := CalulateRarityScore(metadataJson.Attributes, false)
+		rarityResult := CalulateRarityScore(metadataJson.Attributes, false, rarityModel)
 		morphEntity := helpers.CreateMorphEntity(structs.PolymorphEvent{NewGene: mEvent.NewGene, OldGene: mEvent.OldGene, MorphId: mId}, metadataJson, false, rarityResult)
 
-		res, err := handlers.PersistSinglePolymorph(morphEntity, dbInfo.PolymorphDBName, dbInfo.RarityCollectionName, toSaveGene, geneDifferences)
+		res, err := repos.Polymorphs.SaveMorph(morphEntity, toSaveGene, geneDifferences)
 		if err != nil {
 			log.Println(err)
 		} else {
 			log.Println(res)
+			if feedEvent != nil {
+				eventHub.Publish(*feedEvent)
+				webhookDispatcher.Dispatch(*feedEvent)
+			}
 		}
 
 		if !hasTxMap {
@@ -177,12 +232,13 @@ func processInitialMorphs(morphEvent types.Log, ethClient *dlt.EthereumClient, c
 			txState[morphEvent.TxHash.Hex()] = txMap
 		}
 		txState[morphEvent.TxHash.Hex()][morphEvent.Index] = true
-		go handlers.SaveTransaction(dbInfo.PolymorphDBName, dbInfo.TransactionsCollectionName, models.Transaction{
+		transaction := models.Transaction{
 			BlockNumber: morphEvent.BlockNumber,
 			TxIndex:     morphEvent.TxIndex,
 			TxHash:      morphEvent.TxHash.Hex(),
 			LogIndex:    morphEvent.Index,
-		})
+		}
+		inBackground(pollWrites, func() { saveTransaction(repos.Transactions, transaction) })
 	} else if txMap[morphEvent.Index] {
 		log.Println("Already processed morph event! Skipping...")
 	}
@@ -200,8 +256,8 @@ func processInitialMorphs(morphEvent types.Log, ethClient *dlt.EthereumClient, c
 // We don't persist the transaction as the transaction has already been persisted in processInitialMorphs.
 //
 // !! It's important to note which gene is passed as the new one and which as the old one in order to understand how the logic works.
-func processFinalMorphs(morphEvent types.Log, ethClient *dlt.EthereumClient, contractAbi abi.ABI, instance *store.Store, configService *structs.ConfigService, dbInfo structs.DBInfo,
-	txState map[string]map[uint]bool, oldGenesMap map[string]string, morphCostMap map[string]float32) {
+func processFinalMorphs(morphEvent types.Log, chain dlt.Chain, contractAbi abi.ABI, configService *structs.ConfigService, rarityModel structs.RarityModel, repos repositories.Repositories, pollWrites *sync.WaitGroup,
+	txState map[string]map[uint]bool, oldGenesMap map[string]string, morphCostMap map[string]float32, imageGenerator *metadata.ImageGenerator, eventHub *events.Hub, webhookDispatcher *webhooks.Dispatcher) {
 	var mEvent structs.MorphedEvent
 	err := contractAbi.UnpackIntoInterface(&mEvent, constants.MorphEvent.Name, morphEvent.Data)
 	if err != nil {
@@ -211,7 +267,7 @@ func processFinalMorphs(morphEvent types.Log, ethClient *dlt.EthereumClient, con
 	mId := morphEvent.Topics[1].Big()
 
 	// This will get the newest gene
-	result, err := instance.GeneOf(&bind.CallOpts{}, mId)
+	result, err := chain.GeneOf(mId)
 	if err != nil {
 		log.Println(err)
 	}
@@ -222,26 +278,102 @@ func processFinalMorphs(morphEvent types.Log, ethClient *dlt.EthereumClient, con
 	if geneDifferences <= 2 {
 		newAttr, oldAttr = helpers.GetAttribute(mEvent.NewGene.String(), oldGenesMap[mId.String()], geneIdx, configService)
 	}
-	block, err := ethClient.Client.HeaderByNumber(context.Background(), big.NewInt(int64(morphEvent.BlockNumber)))
+	block, err := chain.HeaderByNumber(context.Background(), big.NewInt(int64(morphEvent.BlockNumber)))
 	if err != nil {
 		log.Println(err)
 	}
 	polySnapshot := helpers.CreateMorphSnapshot(geneDifferences, mId.String(), mEvent.NewGene.String(), oldGenesMap[mId.String()], block.Time, oldAttr, newAttr, morphCostMap, configService)
-	go handlers.SavePolymorphHistory(polySnapshot, dbInfo.PolymorphDBName, dbInfo.HistoryCollectionName)
-	go handlers.SaveMorphPrice(models.MorphCost{TokenId: mId.String(), Price: morphCostMap[mId.String()]}, dbInfo.PolymorphDBName, dbInfo.MorphCostCollectionName)
+	polySnapshot.Wallet = morphSender(chain, morphEvent)
+	inBackground(pollWrites, func() { saveSnapshot(repos.History, polySnapshot) })
+	renderImage(imageGenerator, polySnapshot.NewGene, configService)
+	morphCost := models.MorphCost{TokenId: mId.String(), Price: morphCostMap[mId.String()]}
+	inBackground(pollWrites, func() { saveMorphCost(repos.MorphCosts, morphCost) })
 
 	g := metadata.Genome(mEvent.NewGene.String())
 	metadata := (&g).Metadata(mId.String(), configService)
 
-	rarityResult := CalulateRarityScore(metadata.Attributes, false)
+	rarityResult := CalulateRarityScore(metadata.Attributes, false, rarityModel)
 	morphEntity := helpers.CreateMorphEntity(structs.PolymorphEvent{NewGene: mEvent.NewGene, MorphId: mId}, metadata, false, rarityResult)
 
-	res, err := handlers.PersistSinglePolymorph(morphEntity, dbInfo.PolymorphDBName, dbInfo.RarityCollectionName, oldGenesMap[mId.String()], geneDifferences)
+	res, err := repos.Polymorphs.SaveMorph(morphEntity, oldGenesMap[mId.String()], geneDifferences)
 	if err != nil {
 		log.Println(err)
 	} else {
 		log.Println(res)
+		feedEvent := morphFeedEvent(polySnapshot, configService, rarityModel)
+		eventHub.Publish(feedEvent)
+		webhookDispatcher.Dispatch(feedEvent)
+	}
+}
+
+// saveSnapshot persists the history snapshot. A failed snapshot is only logged
+func saveSnapshot(history repositories.HistoryRepository, snapshot models.PolymorphHistory) {
+	if err := history.Save(snapshot); err != nil {
+		log.Println(err)
+		return
+	}
+	log.Println("Inserted history snapshot for polymorph #" + strconv.Itoa(snapshot.TokenId))
+}
+
+// saveMorphCost persists the morph price. The process stops if it fails, because the next snapshots of the polymorph would have the wrong price
+func saveMorphCost(morphCosts repositories.MorphCostRepository, morphCost models.MorphCost) {
+	if err := morphCosts.Save(morphCost); err != nil {
+		log.Fatalln(err)
+	}
+	log.Printf("\nInserted new morph cost in DB:\n#:%v\nPrice: %v\n", morphCost.TokenId, morphCost.Price)
+}
+
+// saveTransaction persists the processed morph event. The process stops if it fails, because the event would be processed again after a restart
+func saveTransaction(transactions repositories.TransactionRepository, transaction models.Transaction) {
+	if err := transactions.Save(transaction); err != nil {
+		log.Fatalln(err)
+	}
+	log.Printf("\nInserted new transaction in DB:\ntxHash: %v\nLogIndex: %v\n", transaction.TxHash, transaction.LogIndex)
+}
+
+// invalidateResponses drops the cached API responses of the minted, morphed and re-ranked polymorphs.
+//
+// It runs after the ranking and after the history snapshots of the poll saved in the background have been inserted
+func invalidateResponses(responseCache *cache.ResponseCache, dbInfo structs.DBInfo, minted map[string]bool, morphed map[string]string, rankChanges []models.RankChange) {
+	if len(minted) == 0 && len(morphed) == 0 && len(rankChanges) == 0 {
+		return
+	}
+
+	namespace := cache.Namespace(dbInfo)
+	tags := []string{cache.ListTag(namespace)}
+	for id := range minted {
+		if tokenId, err := strconv.Atoi(id); err == nil {
+			tags = append(tags, cache.TokenTag(namespace, tokenId))
+		}
+	}
+	for id := range morphed {
+		if tokenId, err := strconv.Atoi(id); err == nil {
+			tags = append(tags, cache.TokenTag(namespace, tokenId), cache.HistoryTag(namespace, tokenId))
+		}
+	}
+	for _, change := range rankChanges {
+		tags = append(tags, cache.TokenTag(namespace, change.TokenId))
+	}
+	responseCache.Invalidate(tags...)
+}
+
+// morphSender returns the lower case address which sent the transaction of the morph event, or an empty string if it can't be fetched
+func morphSender(chain dlt.Chain, morphEvent types.Log) string {
+	sender, err := chain.TransactionSender(context.Background(), morphEvent.BlockHash, morphEvent.TxIndex)
+	if err != nil {
+		log.Println(err)
+		return ""
+	}
+	return strings.ToLower(sender.Hex())
+}
+
+// renderImage schedules rendering of the image of a new gene. The image generator is nil if rendering is disabled
+func renderImage(imageGenerator *metadata.ImageGenerator, gene string, configService *structs.ConfigService) {
+	if imageGenerator == nil {
+		return
 	}
+	g := metadata.Genome(gene)
+	imageGenerator.Enqueue((&g).Genes(configService))
 }
 
 // NOT BEING USED CURRENTLY
@@ -262,7 +394,7 @@ func processFinalMorphs(morphEvent types.Log, ethClient *dlt.EthereumClient, con
 
 // 		mId := morphEvent.Topics[1].Big()
 // 		// This will get the newest gene
-// 		result, err := instance.GeneOf(&bind.CallOpts{}, mId)
+// 		result, err := chain.GeneOf(mId)
 // 		if err != nil {
 // 			log.Println(err)
 // 		}
@@ -272,7 +404,7 @@ func processFinalMorphs(morphEvent types.Log, ethClient *dlt.EthereumClient, con
 // 		g := metadata.Genome(mEvent.NewGene.String())
 // 		metadataJson := (&g).Metadata(mId.String(), configService)
 
-// 		rarityResult := rarityIndex.CalulateRarityScore(metadataJson.Attributes, false)
+// 		rarityResult := rarityIndex.CalulateRarityScore(metadataJson.Attributes, false, rarityModel)
 // 		morphEntity := helpers.CreateMorphEntity(structs.PolymorphEvent{
 // 			NewGene: mEvent.NewGene,
 // 			OldGene: mEvent.OldGene,
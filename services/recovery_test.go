@@ -0,0 +1,351 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"rarity-backend/config"
+	"rarity-backend/constants"
+	"rarity-backend/dlt"
+	"rarity-backend/models"
+	"rarity-backend/repositories"
+	"rarity-backend/store"
+	"rarity-backend/structs"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Genes of the fixtures. Each morph changes one trait, the scramble changes the whole gene
+const (
+	firstGene     = "123456789012345678"
+	firstMorphed  = "123406789012345678"
+	firstRemorph  = "123406789012345078"
+	firstScramble = "987654321098765432"
+	secondGene    = "111111111111111111"
+	secondMorphed = "111111112211111111"
+)
+
+// chainFixture emits the events of the polymorphs contract. Events are pending until they are committed in a new block
+type chainFixture interface {
+	address() common.Address
+	mint(tokenId int64, gene string)
+	// morph emits the morph of the polymorph from oldGene to newGene sent by the wallet
+	morph(tokenId int64, oldGene string, newGene string, price int64, wallet *ecdsa.PrivateKey)
+	// commit returns the number of the new block
+	commit() uint64
+}
+
+// chainFixtures creates the chains every scenario runs against: recorded fixtures served over JSON-RPC to the ethclient and a test contract on go-ethereum's simulated backend
+var chainFixtures = map[string]func(t *testing.T, wallets ...*ecdsa.PrivateKey) (chainFixture, dlt.Chain){
+	"fixtures":  newFakeNode,
+	"simulated": newSimulatedChain,
+}
+
+// recoveryTest runs RecoverProcess against a chain with in-memory repositories
+type recoveryTest struct {
+	t             *testing.T
+	fixture       chainFixture
+	chain         dlt.Chain
+	wallets       []*ecdsa.PrivateKey
+	repos         repositories.Repositories
+	configService *structs.ConfigService
+	contractAbi   abi.ABI
+}
+
+// forEachChain runs the scenario against every chain fixture
+func forEachChain(t *testing.T, scenario func(test *recoveryTest)) {
+	for name, newChain := range chainFixtures {
+		newChain := newChain
+		t.Run(name, func(t *testing.T) {
+			wallets := []*ecdsa.PrivateKey{newTestKey(t), newTestKey(t), newTestKey(t)}
+			fixture, chain := newChain(t, wallets...)
+			contractAbi, err := abi.JSON(strings.NewReader(store.StoreABI))
+			if err != nil {
+				t.Fatal(err)
+			}
+			scenario(&recoveryTest{
+				t:             t,
+				fixture:       fixture,
+				chain:         chain,
+				wallets:       wallets,
+				repos:         repositories.NewMemoryRepositories(),
+				configService: config.NewConfigService("../config.json"),
+				contractAbi:   contractAbi,
+			})
+		})
+	}
+}
+
+// run polls once with the state a started process loads from the repositories and waits for the background writes
+func (r *recoveryTest) run() map[string]float32 {
+	txState, _ := r.repos.Transactions.Processed()
+	morphCostMap, _ := r.repos.MorphCosts.Prices()
+	r.poll(txState, morphCostMap)
+	return morphCostMap
+}
+
+func (r *recoveryTest) poll(txState map[string]map[uint]bool, morphCostMap map[string]float32) {
+	RecoverProcess(r.chain, r.contractAbi, r.fixture.address().Hex(), r.configService, config.RarityModels[config.DEFAULT_RARITY_MODEL],
+		structs.DBInfo{}, r.repos, txState, morphCostMap, nil, nil, nil, nil)
+	WaitForBackgroundWrites()
+}
+
+func (r *recoveryTest) polymorph(tokenId int) repositories.MemoryPolymorph {
+	r.t.Helper()
+	polymorph, ok := r.repos.Polymorphs.(*repositories.MemoryPolymorphs).Get(tokenId)
+	if !ok {
+		r.t.Fatalf("Polymorph #%v wasn't persisted", tokenId)
+	}
+	return polymorph
+}
+
+func (r *recoveryTest) history(tokenId int) []models.PolymorphHistory {
+	return r.repos.History.(*repositories.MemoryHistory).ByToken(tokenId)
+}
+
+func (r *recoveryTest) transactions() int {
+	return r.repos.Transactions.(*repositories.MemoryTransactions).Count()
+}
+
+func (r *recoveryTest) lastBlock() uint64 {
+	block, _ := r.repos.Blocks.LastProcessed()
+	return uint64(block)
+}
+
+func (r *recoveryTest) blockTime(block uint64) time.Time {
+	r.t.Helper()
+	header, err := r.chain.HeaderByNumber(context.Background(), new(big.Int).SetUint64(block))
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	return time.Unix(int64(header.Time), 0)
+}
+
+func (r *recoveryTest) wallet(i int) string {
+	return strings.ToLower(crypto.PubkeyToAddress(r.wallets[i].PublicKey).Hex())
+}
+
+// snapshot returns the snapshot of the gene change. Snapshots of the same block have the same time, so they are looked up by gene
+func (r *recoveryTest) snapshot(tokenId int, oldGene string, newGene string) models.PolymorphHistory {
+	r.t.Helper()
+	snapshots := r.history(tokenId)
+	for _, snapshot := range snapshots {
+		if snapshot.OldGene == oldGene && snapshot.NewGene == newGene {
+			return snapshot
+		}
+	}
+	r.t.Fatalf("Missing snapshot %v -> %v of polymorph #%v in %+v", oldGene, newGene, tokenId, snapshots)
+	return models.PolymorphHistory{}
+}
+
+func TestRecoverProcessMints(t *testing.T) {
+	forEachChain(t, func(test *recoveryTest) {
+		test.fixture.mint(1, firstGene)
+		test.fixture.mint(2, secondGene)
+		block := test.fixture.commit()
+		test.run()
+
+		first, second := test.polymorph(1), test.polymorph(2)
+		if first.Entity.CurrentGene != firstGene || second.Entity.CurrentGene != secondGene {
+			t.Errorf("Polymorphs have genes %v and %v, expected the minted genes", first.Entity.CurrentGene, second.Entity.CurrentGene)
+		}
+		if !first.Entity.IsVirgin || !second.Entity.IsVirgin || first.Morphs != 0 || len(test.history(1)) != 0 {
+			t.Errorf("Minted polymorphs should be virgins without morphs or history")
+		}
+		ranks := map[int]bool{first.Entity.Rank: true, second.Entity.Rank: true}
+		if !ranks[1] || !ranks[2] {
+			t.Errorf("Polymorphs have ranks %v and %v, expected 1 and 2", first.Entity.Rank, second.Entity.Rank)
+		}
+		if (first.Entity.Rank == 1) != (first.Entity.RarityScore >= second.Entity.RarityScore) {
+			t.Errorf("Polymorph #1 has rank %v with score %v, polymorph #2 has score %v", first.Entity.Rank, first.Entity.RarityScore, second.Entity.RarityScore)
+		}
+		if test.lastBlock() != block {
+			t.Errorf("Last processed block is %v, expected %v", test.lastBlock(), block)
+		}
+	})
+}
+
+func TestRecoverProcessMorph(t *testing.T) {
+	forEachChain(t, func(test *recoveryTest) {
+		test.fixture.mint(1, firstGene)
+		test.fixture.commit()
+		test.run()
+		test.fixture.morph(1, firstGene, firstMorphed, 1, test.wallets[0])
+		block := test.fixture.commit()
+		morphCostMap := test.run()
+
+		first := test.polymorph(1)
+		if first.Entity.CurrentGene != firstMorphed || first.Entity.IsVirgin || first.Morphs != 1 || first.Scrambles != 0 {
+			t.Errorf("Polymorph #1 has gene %v, virgin %v, %v morphs and %v scrambles, expected one morph to %v",
+				first.Entity.CurrentGene, first.Entity.IsVirgin, first.Morphs, first.Scrambles, firstMorphed)
+		}
+		snapshot := test.snapshot(1, firstGene, firstMorphed)
+		if snapshot.Type != constants.HISTORY_TYPE_MORPH || snapshot.AttributeChanged == "" || snapshot.PreviousAttribute == snapshot.NewAttribute {
+			t.Errorf("Unexpected morph snapshot %+v", snapshot)
+		}
+		if snapshot.Wallet != test.wallet(0) || snapshot.Price != config.SCRAMBLE_COST || !snapshot.DateTime.Equal(test.blockTime(block)) {
+			t.Errorf("Morph snapshot has wallet %v, price %v and time %v, expected %v, %v and the time of block %v",
+				snapshot.Wallet, snapshot.Price, snapshot.DateTime, test.wallet(0), config.SCRAMBLE_COST, block)
+		}
+		if morphCostMap["1"] != 2*config.SCRAMBLE_COST {
+			t.Errorf("Morph price is %v after a morph, expected it to double", morphCostMap["1"])
+		}
+		if test.transactions() != 1 {
+			t.Errorf("%v transactions were persisted, expected 1", test.transactions())
+		}
+	})
+}
+
+func TestRecoverProcessScramble(t *testing.T) {
+	forEachChain(t, func(test *recoveryTest) {
+		test.fixture.mint(1, firstGene)
+		test.fixture.commit()
+		test.fixture.morph(1, firstGene, firstScramble, 1, test.wallets[0])
+		test.fixture.commit()
+		morphCostMap := test.run()
+
+		first := test.polymorph(1)
+		if first.Entity.CurrentGene != firstScramble || first.Morphs != 0 || first.Scrambles != 1 {
+			t.Errorf("Polymorph #1 has gene %v, %v morphs and %v scrambles, expected one scramble to %v", first.Entity.CurrentGene, first.Morphs, first.Scrambles, firstScramble)
+		}
+		snapshot := test.snapshot(1, firstGene, firstScramble)
+		if snapshot.Type != constants.HISTORY_TYPE_SCRAMBLE || snapshot.AttributeChanged != "" || snapshot.Wallet != test.wallet(0) {
+			t.Errorf("Unexpected scramble snapshot %+v", snapshot)
+		}
+		if morphCostMap["1"] != config.SCRAMBLE_COST {
+			t.Errorf("Morph price is %v after a scramble, expected %v", morphCostMap["1"], config.SCRAMBLE_COST)
+		}
+	})
+}
+
+func TestRecoverProcessMultipleMorphsPerPoll(t *testing.T) {
+	forEachChain(t, func(test *recoveryTest) {
+		test.fixture.mint(1, firstGene)
+		test.fixture.mint(2, secondGene)
+		test.fixture.commit()
+		test.fixture.morph(1, firstGene, firstMorphed, 1, test.wallets[0])
+		test.fixture.morph(2, secondGene, secondMorphed, 1, test.wallets[2])
+		test.fixture.commit()
+		test.fixture.morph(1, firstMorphed, firstRemorph, 1, test.wallets[1])
+		test.fixture.commit()
+		test.fixture.morph(1, firstRemorph, firstScramble, 1, test.wallets[2])
+		test.fixture.commit()
+		morphCostMap := test.run()
+
+		first := test.polymorph(1)
+		if first.Entity.CurrentGene != firstScramble || first.Morphs != 2 || first.Scrambles != 1 {
+			t.Errorf("Polymorph #1 has gene %v, %v morphs and %v scrambles, expected 2 morphs and a scramble to %v", first.Entity.CurrentGene, first.Morphs, first.Scrambles, firstScramble)
+		}
+		expectedOldGenes := []string{firstGene, firstMorphed, firstRemorph}
+		if strings.Join(first.OldGenes, ",") != strings.Join(expectedOldGenes, ",") {
+			t.Errorf("Polymorph #1 has old genes %v, expected %v", first.OldGenes, expectedOldGenes)
+		}
+		if len(test.history(1)) != 3 {
+			t.Fatalf("Polymorph #1 has %v snapshots, expected 3", len(test.history(1)))
+		}
+
+		// Every snapshot is attributed to the sender of its own morph and has the price before it
+		snapshots := []struct {
+			oldGene    string
+			newGene    string
+			changeType string
+			wallet     int
+			price      float32
+		}{
+			{firstGene, firstMorphed, constants.HISTORY_TYPE_MORPH, 0, config.SCRAMBLE_COST},
+			{firstMorphed, firstRemorph, constants.HISTORY_TYPE_MORPH, 1, 2 * config.SCRAMBLE_COST},
+			{firstRemorph, firstScramble, constants.HISTORY_TYPE_SCRAMBLE, 2, 4 * config.SCRAMBLE_COST},
+		}
+		for _, expected := range snapshots {
+			snapshot := test.snapshot(1, expected.oldGene, expected.newGene)
+			if snapshot.Type != expected.changeType || snapshot.Wallet != test.wallet(expected.wallet) || snapshot.Price != expected.price {
+				t.Errorf("Snapshot %v -> %v has type %v, wallet %v and price %v, expected %v, %v and %v", expected.oldGene, expected.newGene,
+					snapshot.Type, snapshot.Wallet, snapshot.Price, expected.changeType, test.wallet(expected.wallet), expected.price)
+			}
+		}
+		if morphCostMap["1"] != config.SCRAMBLE_COST {
+			t.Errorf("Morph price is %v after the scramble, expected %v", morphCostMap["1"], config.SCRAMBLE_COST)
+		}
+
+		second := test.polymorph(2)
+		if second.Entity.CurrentGene != secondMorphed || second.Morphs != 1 || test.snapshot(2, secondGene, secondMorphed).Wallet != test.wallet(2) {
+			t.Errorf("Polymorph #2 has gene %v and %v morphs, expected one morph to %v", second.Entity.CurrentGene, second.Morphs, secondMorphed)
+		}
+		if test.transactions() != 4 {
+			t.Errorf("%v transactions were persisted, expected the 4 morphs", test.transactions())
+		}
+	})
+}
+
+func TestRecoverProcessResumesAfterRestart(t *testing.T) {
+	forEachChain(t, func(test *recoveryTest) {
+		test.fixture.mint(1, firstGene)
+		test.fixture.mint(2, secondGene)
+		test.fixture.commit()
+		test.fixture.morph(1, firstGene, firstMorphed, 1, test.wallets[0])
+		test.fixture.commit()
+		test.run()
+
+		// The restarted process scans the last processed block again, its morph is skipped
+		test.fixture.morph(2, secondGene, secondMorphed, 1, test.wallets[1])
+		test.fixture.commit()
+		block := test.fixture.commit()
+		test.run()
+
+		if count := test.repos.Polymorphs.(*repositories.MemoryPolymorphs).Count(); count != 2 {
+			t.Errorf("%v polymorphs were persisted, expected 2", count)
+		}
+		if first := test.polymorph(1); first.Morphs != 1 || len(test.history(1)) != 1 {
+			t.Errorf("Polymorph #1 has %v morphs and %v snapshots, the processed morph shouldn't be processed again", first.Morphs, len(test.history(1)))
+		}
+		second := test.polymorph(2)
+		if second.Entity.CurrentGene != secondMorphed || second.Morphs != 1 {
+			t.Errorf("Polymorph #2 has gene %v and %v morphs, expected %v and 1", second.Entity.CurrentGene, second.Morphs, secondMorphed)
+		}
+		test.snapshot(2, secondGene, secondMorphed)
+		if test.transactions() != 2 {
+			t.Errorf("%v transactions were persisted, expected 2", test.transactions())
+		}
+		if test.lastBlock() != block {
+			t.Errorf("Last processed block is %v, expected %v", test.lastBlock(), block)
+		}
+	})
+}
+
+// slowHistory delays saving the snapshots like a slow database
+type slowHistory struct {
+	repositories.HistoryRepository
+}
+
+func (h slowHistory) Save(snapshot models.PolymorphHistory) error {
+	time.Sleep(100 * time.Millisecond)
+	return h.HistoryRepository.Save(snapshot)
+}
+
+func TestRecoverProcessWaitsForSnapshotsOfThePoll(t *testing.T) {
+	forEachChain(t, func(test *recoveryTest) {
+		history := test.repos.History.(*repositories.MemoryHistory)
+		test.repos.History = slowHistory{history}
+		test.fixture.mint(1, firstGene)
+		test.fixture.commit()
+		test.run()
+		test.fixture.morph(1, firstGene, firstMorphed, 1, test.wallets[0])
+		test.fixture.commit()
+
+		// The responses are invalidated before RecoverProcess returns, so the snapshots must be persisted by then
+		txState, _ := test.repos.Transactions.Processed()
+		morphCostMap, _ := test.repos.MorphCosts.Prices()
+		RecoverProcess(test.chain, test.contractAbi, test.fixture.address().Hex(), test.configService, config.RarityModels[config.DEFAULT_RARITY_MODEL],
+			structs.DBInfo{}, test.repos, txState, morphCostMap, nil, nil, nil, nil)
+		if snapshots := history.ByToken(1); len(snapshots) != 1 {
+			t.Errorf("Got %v snapshots when the poll returned, expected the morph snapshot", len(snapshots))
+		}
+		WaitForBackgroundWrites()
+	})
+}
@@ -0,0 +1,25 @@
+package services
+
+import (
+	"rarity-backend/dlt"
+	"rarity-backend/repositories"
+	"rarity-backend/structs"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+)
+
+// ReplayProcess processes the mint and morph events from fromBlock to toBlock into repos, the same way RecoverProcess does.
+//
+// The replay starts without processed transactions and morph prices, so repos and dbInfo should point to an empty scratch database.
+// Images aren't rendered, responses aren't invalidated and neither the live feed nor the webhooks are notified.
+//
+// Returns the last processed block after every background write is persisted
+func ReplayProcess(chain dlt.Chain, contractAbi abi.ABI, address string, configService *structs.ConfigService, rarityModel structs.RarityModel,
+	dbInfo structs.DBInfo, repos repositories.Repositories, fromBlock int64, toBlock int64) uint64 {
+	txState := make(map[string]map[uint]bool)
+	morphCostMap := make(map[string]float32)
+
+	lastBlock := processBlocks(chain, contractAbi, address, configService, rarityModel, dbInfo, repos, txState, morphCostMap, nil, nil, nil, nil, fromBlock, toBlock)
+	WaitForBackgroundWrites()
+	return lastBlock
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"strings"
+	"testing"
+
+	"rarity-backend/config"
+	"rarity-backend/dlt"
+	"rarity-backend/repositories"
+	"rarity-backend/store"
+	"rarity-backend/structs"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+)
+
+// The simulated backend only calls the contract at the latest block, so the replay runs against the recorded fixtures
+func TestReplayProcessReadsGenesAtTheLastBlock(t *testing.T) {
+	wallet := newTestKey(t)
+	node, chain := newFakeNode(t, wallet)
+	contractAbi, err := abi.JSON(strings.NewReader(store.StoreABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	test := &recoveryTest{t: t, repos: repositories.NewMemoryRepositories(), wallets: []*ecdsa.PrivateKey{wallet}}
+
+	node.mint(1, firstGene)
+	from := node.commit()
+	node.morph(1, firstGene, firstMorphed, 1, wallet)
+	to := node.commit()
+	// The morph after the replayed range changes the gene of the latest state
+	node.morph(1, firstMorphed, firstRemorph, 1, wallet)
+	node.commit()
+
+	lastBlock := ReplayProcess(chain.(*dlt.ContractChain).AtBlock(new(big.Int).SetUint64(to)), contractAbi, node.address().Hex(), config.NewConfigService("../config.json"),
+		config.RarityModels[config.DEFAULT_RARITY_MODEL], structs.DBInfo{}, test.repos, int64(from), int64(to))
+
+	if lastBlock != to {
+		t.Errorf("Replay processed until block %v, expected %v", lastBlock, to)
+	}
+	first := test.polymorph(1)
+	if first.Entity.CurrentGene != firstMorphed || first.Morphs != 1 {
+		t.Errorf("Polymorph #1 has gene %v and %v morphs, expected one morph to %v", first.Entity.CurrentGene, first.Morphs, firstMorphed)
+	}
+	if snapshots := test.history(1); len(snapshots) != 1 || snapshots[0].NewGene != firstMorphed {
+		t.Errorf("Got snapshots %+v, expected only the morph to %v", snapshots, firstMorphed)
+	}
+}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"encoding/binary"
+	"math/big"
+	"strings"
+	"testing"
+
+	"rarity-backend/constants"
+	"rarity-backend/dlt"
+	"rarity-backend/store"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// testContractAbi is the ABI of the test contract deployed to the simulated backend.
+//
+// mint and morph emit the events of the polymorphs contract and store the gene returned by its geneOf.
+// Like the polymorphs contract, morph emits the gene before the morph in both gene parameters of the event
+const testContractAbi = `[
+	{"type":"function","name":"mint","stateMutability":"nonpayable","outputs":[],"inputs":[
+		{"name":"tokenId","type":"uint256"},{"name":"gene","type":"uint256"}]},
+	{"type":"function","name":"morph","stateMutability":"nonpayable","outputs":[],"inputs":[
+		{"name":"tokenId","type":"uint256"},{"name":"oldGene","type":"uint256"},{"name":"newGene","type":"uint256"},{"name":"price","type":"uint256"}]}
+]`
+
+// simulatedChainId is the chain id of go-ethereum's simulated backend
+var simulatedChainId = big.NewInt(1337)
+
+// simulatedChain runs the test contract on go-ethereum's simulated backend. Transactions are mined by commit
+type simulatedChain struct {
+	t        *testing.T
+	backend  *backends.SimulatedBackend
+	contract *bind.BoundContract
+	at       common.Address
+	deployer *ecdsa.PrivateKey
+}
+
+// newSimulatedChain deploys the test contract to a simulated backend which funds the deployer and the wallets
+func newSimulatedChain(t *testing.T, wallets ...*ecdsa.PrivateKey) (chainFixture, dlt.Chain) {
+	t.Helper()
+	deployer := newTestKey(t)
+	alloc := core.GenesisAlloc{}
+	for _, key := range append(wallets, deployer) {
+		alloc[crypto.PubkeyToAddress(key.PublicKey)] = core.GenesisAccount{Balance: new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)}
+	}
+	backend := backends.NewSimulatedBackend(alloc, 8000000)
+	t.Cleanup(func() { backend.Close() })
+
+	contractAbi, err := abi.JSON(strings.NewReader(testContractAbi))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts, err := bind.NewKeyedTransactorWithChainID(deployer, simulatedChainId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at, _, contract, err := bind.DeployContract(opts, contractAbi, testContractCode(t, contractAbi), backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend.Commit()
+
+	chain, err := dlt.NewContractChain(backend, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &simulatedChain{t: t, backend: backend, contract: contract, at: at, deployer: deployer}, chain
+}
+
+func (s *simulatedChain) address() common.Address {
+	return s.at
+}
+
+func (s *simulatedChain) commit() uint64 {
+	s.backend.Commit()
+	return s.backend.Blockchain().CurrentBlock().NumberU64()
+}
+
+func (s *simulatedChain) mint(tokenId int64, gene string) {
+	s.transact(s.deployer, "mint", big.NewInt(tokenId), toBig(s.t, gene))
+}
+
+func (s *simulatedChain) morph(tokenId int64, oldGene string, newGene string, price int64, wallet *ecdsa.PrivateKey) {
+	s.transact(wallet, "morph", big.NewInt(tokenId), toBig(s.t, oldGene), toBig(s.t, newGene), big.NewInt(price))
+}
+
+func (s *simulatedChain) transact(key *ecdsa.PrivateKey, method string, params ...interface{}) {
+	opts, err := bind.NewKeyedTransactorWithChainID(key, simulatedChainId)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	if _, err := s.contract.Transact(opts, method, params...); err != nil {
+		s.t.Fatal(err)
+	}
+}
+
+// testContractCode assembles the creation code of the test contract. The runtime code dispatches on the selector:
+//
+// geneOf(tokenId) returns the stored gene
+//
+// mint(tokenId, gene) stores the gene and logs TokenMinted(tokenId, gene)
+//
+// morph(tokenId, oldGene, newGene, price) stores the new gene and logs TokenMorphed(tokenId, oldGene, oldGene, price, 1)
+func testContractCode(t *testing.T, contractAbi abi.ABI) []byte {
+	t.Helper()
+	storeAbi, err := abi.JSON(strings.NewReader(store.StoreABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runtime := newAssembler()
+	runtime.push(0).op(vm.CALLDATALOAD).push(0xe0).op(vm.SHR)
+	selectors := map[string][]byte{
+		"geneOf": storeAbi.Methods["geneOf"].ID,
+		"mint":   contractAbi.Methods["mint"].ID,
+		"morph":  contractAbi.Methods["morph"].ID,
+	}
+	for _, name := range []string{"geneOf", "mint", "morph"} {
+		runtime.op(vm.DUP1).pushBytes(selectors[name]).op(vm.EQ).pushLabel(name).op(vm.JUMPI)
+	}
+	runtime.push(0).op(vm.DUP1, vm.REVERT)
+
+	// The token id is the first argument of every function
+	runtime.label("geneOf")
+	runtime.push(4).op(vm.CALLDATALOAD, vm.SLOAD).push(0).op(vm.MSTORE).push(32).push(0).op(vm.RETURN)
+
+	runtime.label("mint")
+	runtime.push(36).op(vm.CALLDATALOAD, vm.DUP1).push(4).op(vm.CALLDATALOAD, vm.SSTORE)
+	runtime.push(0).op(vm.MSTORE)
+	runtime.push(4).op(vm.CALLDATALOAD).pushBytes(common.HexToHash(constants.MintEvent.Signature).Bytes()).push(32).push(0).op(vm.LOG2, vm.STOP)
+
+	runtime.label("morph")
+	runtime.push(68).op(vm.CALLDATALOAD).push(4).op(vm.CALLDATALOAD, vm.SSTORE)
+	runtime.push(36).op(vm.CALLDATALOAD, vm.DUP1).push(0).op(vm.MSTORE).push(32).op(vm.MSTORE)
+	runtime.push(100).op(vm.CALLDATALOAD).push(64).op(vm.MSTORE)
+	runtime.push(1).push(96).op(vm.MSTORE)
+	runtime.push(4).op(vm.CALLDATALOAD).pushBytes(common.HexToHash(constants.MorphEvent.Signature).Bytes()).push(128).push(0).op(vm.LOG2, vm.STOP)
+
+	code := runtime.assemble()
+	// The creation code copies the runtime code, which follows it, to memory and returns it
+	creation := newAssembler()
+	creation.pushBytes(uint16Bytes(len(code))).op(vm.DUP1).pushBytes(uint16Bytes(13)).push(0).op(vm.CODECOPY).push(0).op(vm.RETURN)
+	return append(creation.assemble(), code...)
+}
+
+// assembler builds EVM code with jump labels
+type assembler struct {
+	code   []byte
+	labels map[string]int
+	// jumps are the positions of the label placeholders by label
+	jumps map[int]string
+}
+
+func newAssembler() *assembler {
+	return &assembler{labels: make(map[string]int), jumps: make(map[int]string)}
+}
+
+func (a *assembler) op(ops ...vm.OpCode) *assembler {
+	for _, op := range ops {
+		a.code = append(a.code, byte(op))
+	}
+	return a
+}
+
+// push pushes a value of one byte
+func (a *assembler) push(value byte) *assembler {
+	return a.pushBytes([]byte{value})
+}
+
+func (a *assembler) pushBytes(value []byte) *assembler {
+	a.code = append(a.code, byte(vm.PUSH1)+byte(len(value)-1))
+	a.code = append(a.code, value...)
+	return a
+}
+
+func (a *assembler) pushLabel(label string) *assembler {
+	a.code = append(a.code, byte(vm.PUSH2))
+	a.jumps[len(a.code)] = label
+	a.code = append(a.code, 0, 0)
+	return a
+}
+
+func (a *assembler) label(label string) *assembler {
+	a.labels[label] = len(a.code)
+	return a.op(vm.JUMPDEST)
+}
+
+func (a *assembler) assemble() []byte {
+	for position, label := range a.jumps {
+		copy(a.code[position:], uint16Bytes(a.labels[label]))
+	}
+	return a.code
+}
+
+func uint16Bytes(value int) []byte {
+	bytes := make([]byte, 2)
+	binary.BigEndian.PutUint16(bytes, uint16(value))
+	return bytes
+}
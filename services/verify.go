@@ -0,0 +1,162 @@
+package services
+
+import (
+	"log"
+	"math/big"
+	"rarity-backend/config"
+	"rarity-backend/constants"
+	"rarity-backend/dlt"
+	"rarity-backend/handlers"
+	"rarity-backend/helpers"
+	"rarity-backend/metadata"
+	"rarity-backend/repositories"
+	"rarity-backend/structs"
+	"sort"
+	"strconv"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// VerifyCollection checks the stored state of every polymorph of the collection against the chain and its own history:
+//
+// 1. The current gene of the entity is the gene returned by GeneOf
+//
+// 2. The old gene of every history snapshot is the new gene of the previous snapshot and the latest snapshot has the current gene
+//
+// 3. The morph and scramble counters match the number of morph and scramble snapshots
+//
+// The entities and snapshots are read from the collections of dbInfo, repairs are persisted to repos.
+//
+// In repair mode entities with an outdated gene are recalculated from the gene on the chain, counters are set to the number of snapshots and the ranking is updated.
+// Broken history chains are only reported, because the missing snapshots can't be recreated without the morph events
+func VerifyCollection(chain dlt.Chain, configService *structs.ConfigService, rarityModel structs.RarityModel, dbInfo structs.DBInfo, repos repositories.Repositories, repair bool) (structs.VerifyReport, error) {
+	entities, err := handlers.GetRarityDocuments(dbInfo.PolymorphDBName, dbInfo.RarityCollectionName)
+	if err != nil {
+		return structs.VerifyReport{}, err
+	}
+	snapshots, err := handlers.GetAllHistoryDocuments(dbInfo.PolymorphDBName, dbInfo.HistoryCollectionName)
+	if err != nil {
+		return structs.VerifyReport{}, err
+	}
+	history := helpers.GroupSnapshotsByToken(snapshots)
+
+	var tokenIds []int
+	for _, entity := range entities {
+		if tokenId, ok := helpers.DocumentTokenId(entity); ok {
+			tokenIds = append(tokenIds, tokenId)
+		}
+	}
+	chainGenes := fetchGenes(chain, tokenIds)
+
+	report := structs.VerifyReport{Tokens: len(entities), Mismatches: []structs.VerifyMismatch{}}
+	rankingChanged := false
+	for _, entity := range entities {
+		tokenId, ok := helpers.DocumentTokenId(entity)
+		if !ok {
+			continue
+		}
+
+		currentGene, _ := entity[constants.MorphFieldNames.CurrentGene].(string)
+		if chainGene, ok := chainGenes[tokenId]; ok && chainGene != currentGene {
+			mismatch := structs.VerifyMismatch{TokenId: tokenId, Check: helpers.CHECK_GENE, Stored: currentGene, Expected: chainGene}
+			if repair {
+				mismatch.Repaired = repairGene(tokenId, chainGene, configService, rarityModel, repos.Polymorphs)
+				rankingChanged = rankingChanged || mismatch.Repaired
+			}
+			report.Mismatches = append(report.Mismatches, mismatch)
+		}
+
+		historyMismatches := helpers.CheckHistory(entity, history[tokenId])
+		if repair {
+			repairCounters(tokenId, history[tokenId], historyMismatches, repos.Polymorphs)
+		}
+		report.Mismatches = append(report.Mismatches, historyMismatches...)
+	}
+
+	for _, mismatch := range report.Mismatches {
+		if mismatch.Repaired {
+			report.Repaired++
+		}
+	}
+	sort.SliceStable(report.Mismatches, func(i, j int) bool { return report.Mismatches[i].TokenId < report.Mismatches[j].TokenId })
+	if rankingChanged {
+		if _, err := UpdateAllRanking(repos.Polymorphs); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// fetchGenes returns the gene of each token on the chain. Tokens whose gene can't be fetched are logged and left out
+func fetchGenes(chain dlt.Chain, tokenIds []int) map[int]string {
+	genes := make(map[int]string, len(tokenIds))
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+
+	ids := make(chan int)
+	for i := 0; i < config.VERIFY_GENE_WORKERS; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for tokenId := range ids {
+				gene, err := chain.GeneOf(big.NewInt(int64(tokenId)))
+				if err != nil {
+					log.Printf("Could not fetch the gene of polymorph #%v: %v", tokenId, err)
+					continue
+				}
+				mutex.Lock()
+				genes[tokenId] = gene.String()
+				mutex.Unlock()
+			}
+		}()
+	}
+	for _, tokenId := range tokenIds {
+		ids <- tokenId
+	}
+	close(ids)
+	wg.Wait()
+	return genes
+}
+
+// repairGene recalculates the entity of the polymorph from the gene on the chain. A changed gene means that the polymorph was morphed, so it isn't a virgin anymore
+func repairGene(tokenId int, gene string, configService *structs.ConfigService, rarityModel structs.RarityModel, polymorphs repositories.PolymorphRepository) bool {
+	newGene, ok := new(big.Int).SetString(gene, 10)
+	if !ok {
+		log.Printf("Invalid gene %v of polymorph #%v", gene, tokenId)
+		return false
+	}
+
+	g := metadata.Genome(gene)
+	metadataJson := (&g).Metadata(strconv.Itoa(tokenId), configService)
+	rarityResult := CalulateRarityScore(metadataJson.Attributes, false, rarityModel)
+	entity := helpers.CreateMorphEntity(structs.PolymorphEvent{NewGene: newGene, MorphId: big.NewInt(int64(tokenId))}, metadataJson, false, rarityResult)
+
+	if _, err := polymorphs.SaveMorph(entity, "", 0); err != nil {
+		log.Println(err)
+		return false
+	}
+	return true
+}
+
+// repairCounters sets the morph and scramble counters to the number of snapshots if one of them doesn't match
+func repairCounters(tokenId int, snapshots []bson.M, mismatches []structs.VerifyMismatch, polymorphs repositories.PolymorphRepository) {
+	var counterMismatches []*structs.VerifyMismatch
+	for i := range mismatches {
+		if mismatches[i].Check == helpers.CHECK_MORPHS || mismatches[i].Check == helpers.CHECK_SCRAMBLES {
+			counterMismatches = append(counterMismatches, &mismatches[i])
+		}
+	}
+	if len(counterMismatches) == 0 {
+		return
+	}
+
+	morphs, scrambles := helpers.CountSnapshots(snapshots)
+	if err := polymorphs.SetMorphCounters(tokenId, morphs, scrambles); err != nil {
+		log.Println(err)
+		return
+	}
+	for _, mismatch := range counterMismatches {
+		mismatch.Repaired = true
+	}
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"rarity-backend/config"
+	"rarity-backend/helpers"
+	"rarity-backend/models"
+	"rarity-backend/repositories"
+	"rarity-backend/structs"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestFetchGenes(t *testing.T) {
+	forEachChain(t, func(test *recoveryTest) {
+		test.fixture.mint(1, firstGene)
+		test.fixture.mint(2, secondGene)
+		test.fixture.commit()
+
+		genes := fetchGenes(test.chain, []int{1, 2})
+		if genes[1] != firstGene || genes[2] != secondGene {
+			t.Errorf("Got %v, expected the minted genes", genes)
+		}
+	})
+}
+
+func TestRepairGene(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	configService := config.NewConfigService("../config.json")
+	repos.Polymorphs.InsertMints([]models.PolymorphEntity{{TokenId: 1, CurrentGene: firstGene, IsVirgin: true}})
+
+	if !repairGene(1, firstMorphed, configService, config.RarityModels[config.DEFAULT_RARITY_MODEL], repos.Polymorphs) {
+		t.Fatal("Expected the gene to be repaired")
+	}
+	polymorph, _ := repos.Polymorphs.(*repositories.MemoryPolymorphs).Get(1)
+	if polymorph.Entity.CurrentGene != firstMorphed || polymorph.Entity.IsVirgin || polymorph.Morphs != 0 {
+		t.Errorf("Got %+v, expected the morphed gene without a virgin bonus or counted morph", polymorph)
+	}
+
+	if repairGene(1, "not a gene", configService, config.RarityModels[config.DEFAULT_RARITY_MODEL], repos.Polymorphs) {
+		t.Error("Expected an invalid gene not to be repaired")
+	}
+}
+
+func TestRepairCounters(t *testing.T) {
+	snapshots := []bson.M{
+		{"oldgene": firstGene, "newgene": firstMorphed, "type": "Morph"},
+		// A morph which didn't change the gene isn't counted
+		{"oldgene": firstMorphed, "newgene": firstMorphed, "type": "Morph"},
+		{"oldgene": firstMorphed, "newgene": firstScramble, "type": "Scramble"},
+	}
+
+	tests := []struct {
+		name       string
+		mismatches []structs.VerifyMismatch
+		repaired   bool
+	}{
+		{"counter mismatch", []structs.VerifyMismatch{
+			{TokenId: 1, Check: helpers.CHECK_HISTORY_CHAIN},
+			{TokenId: 1, Check: helpers.CHECK_MORPHS},
+		}, true},
+		{"no counter mismatch", []structs.VerifyMismatch{{TokenId: 1, Check: helpers.CHECK_LATEST_SNAPSHOT}}, false},
+	}
+
+	for _, test := range tests {
+		repos := repositories.NewMemoryRepositories()
+		repos.Polymorphs.InsertMints([]models.PolymorphEntity{{TokenId: 1, CurrentGene: firstScramble}})
+		repos.Polymorphs.SetMorphCounters(1, 5, 5)
+
+		repairCounters(1, snapshots, test.mismatches, repos.Polymorphs)
+
+		polymorph, _ := repos.Polymorphs.(*repositories.MemoryPolymorphs).Get(1)
+		if repaired := polymorph.Morphs == 1 && polymorph.Scrambles == 1; repaired != test.repaired {
+			t.Errorf("%v: got %v morphs and %v scrambles, expected repaired %v", test.name, polymorph.Morphs, polymorph.Scrambles, test.repaired)
+		}
+		for _, mismatch := range test.mismatches {
+			counter := mismatch.Check == helpers.CHECK_MORPHS || mismatch.Check == helpers.CHECK_SCRAMBLES
+			if mismatch.Repaired != counter {
+				t.Errorf("%v: %v mismatch got repaired %v", test.name, mismatch.Check, mismatch.Repaired)
+			}
+		}
+	}
+}
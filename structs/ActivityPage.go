@@ -0,0 +1,10 @@
+package structs
+
+import "go.mongodb.org/mongo-driver/bson"
+
+// ActivityPage is the response of the activity endpoint. Results are history snapshots, newest first. NextCursor is empty on the last page
+type ActivityPage struct {
+	Total      int64    `json:"total"`
+	NextCursor string   `json:"nextCursor"`
+	Results    []bson.M `json:"results"`
+}
@@ -0,0 +1,26 @@
+package structs
+
+// Collection describes a single polymorph collection indexed by the application.
+//
+// Name is used as collection namespace in the API routes.
+type Collection struct {
+	Name            string `json:"name"`
+	ContractAddress string `json:"contractAddress"`
+	ConfigPath      string `json:"configPath"`
+	RarityModel     string `json:"rarityModel"`
+	DBInfo          DBInfo `json:"db"`
+	Images          Images `json:"images"`
+	// ConfigService is loaded from ConfigPath when the application starts
+	ConfigService *ConfigService `json:"-"`
+}
+
+// Images are the locations of the image layers, rendered images and cached image variants of a collection.
+//
+// The storage kind is set by IMAGE_STORAGE for all collections. Empty dirs fall back to IMAGE_SOURCE_DIR, IMAGE_OUTPUT_DIR and IMAGE_CACHE_DIR, empty buckets to the polymorph buckets.
+type Images struct {
+	SourceDir    string `json:"sourceDir"`
+	OutputDir    string `json:"outputDir"`
+	CacheDir     string `json:"cacheDir"`
+	SourceBucket string `json:"sourceBucket"`
+	UploadBucket string `json:"uploadBucket"`
+}
@@ -0,0 +1,13 @@
+package structs
+
+// CollectionStats are the aggregated statistics of the polymorphs of a collection
+type CollectionStats struct {
+	Total              int64   `bson:"total" json:"total"`
+	Virgins            int64   `bson:"virgins" json:"virgins"`
+	CompletedSets      int64   `bson:"completedsets" json:"completedSets"`
+	AverageRarityScore float64 `bson:"averagerarityscore" json:"averageRarityScore"`
+	MinRarityScore     float64 `bson:"minrarityscore" json:"minRarityScore"`
+	MaxRarityScore     float64 `bson:"maxrarityscore" json:"maxRarityScore"`
+	Morphs             int64   `bson:"morphs" json:"morphs"`
+	Scrambles          int64   `bson:"scrambles" json:"scrambles"`
+}
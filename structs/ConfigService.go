@@ -1,9 +1,11 @@
 package structs
 
-type AttributeSet struct {
-	Name string   `json:"name"`
-	Sets []string `json:"sets"`
-}
+import "rarity-backend/genome"
+
+type AttributeSet = genome.Trait
+
+// GeneSlot describes where a single trait is stored in the gene and how it's decoded. See genome.Slot
+type GeneSlot = genome.Slot
 
 type ConfigService struct {
 	Type        []string       `json:"type"`
@@ -16,4 +18,9 @@ type ConfigService struct {
 	Headwear    []AttributeSet `json:"headwear"`
 	WeaponRight []AttributeSet `json:"weaponright"`
 	WeaponLeft  []AttributeSet `json:"weaponleft"`
+	Genome      []GeneSlot     `json:"genome"`
+	// Traits contains every config list referenced by the genome layout keyed by list name
+	Traits map[string][]AttributeSet `json:"-"`
+	// Decoder decodes genes using the genome layout and the trait lists
+	Decoder *genome.Decoder `json:"-"`
 }
@@ -1,10 +1,17 @@
 package structs
 
 type DBInfo struct {
-	PolymorphDBName            string
-	RarityCollectionName       string
-	TransactionsCollectionName string
-	BlocksCollectionName       string
-	HistoryCollectionName      string
-	MorphCostCollectionName    string
+	PolymorphDBName            string `json:"polymorphDb"`
+	RarityCollectionName       string `json:"rarityCollection"`
+	TransactionsCollectionName string `json:"transactionsCollection"`
+	BlocksCollectionName       string `json:"blocksCollection"`
+	HistoryCollectionName      string `json:"historyCollection"`
+	MorphCostCollectionName    string `json:"morphCostCollection"`
+	// RankHistoryCollectionName is optional. Rank changes are only recorded if it's set
+	RankHistoryCollectionName string `json:"rankHistoryCollection"`
+	// WebhooksCollectionName and WebhookDeliveriesCollectionName are optional. Webhooks are disabled if they aren't set
+	WebhooksCollectionName          string `json:"webhooksCollection"`
+	WebhookDeliveriesCollectionName string `json:"webhookDeliveriesCollection"`
+	// MorphStatsCollectionName is optional. Daily morph stats are only rolled up if it's set
+	MorphStatsCollectionName string `json:"morphStatsCollection"`
 }
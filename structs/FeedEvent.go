@@ -0,0 +1,24 @@
+package structs
+
+import "time"
+
+// FeedEvent is an event of the live feed. Id is assigned by the hub which publishes the event.
+//
+// Traits and scores are set for mints, morphs and scrambles. Ranks are set for rank changes
+type FeedEvent struct {
+	Id           uint64 `json:"id"`
+	Type         string `json:"type"`
+	TokenId      int    `json:"tokenId"`
+	ChangedTrait string `json:"changedTrait,omitempty"`
+	// CompletedSet is the set the morph or scramble completed
+	CompletedSet string      `json:"completedSet,omitempty"`
+	OldTraits    []Attribute `json:"oldTraits,omitempty"`
+	NewTraits    []Attribute `json:"newTraits,omitempty"`
+	OldScore     float64     `json:"oldScore,omitempty"`
+	NewScore     float64     `json:"newScore,omitempty"`
+	OldRank      int         `json:"oldRank,omitempty"`
+	NewRank      int         `json:"newRank,omitempty"`
+	// Owner is only set for the rank changes sent to webhooks
+	Owner string    `json:"owner,omitempty"`
+	Time  time.Time `json:"time"`
+}
@@ -46,4 +46,50 @@ type PolymorphFieldNames struct {
 	BaseRarity            string
 	Scrambles             string
 	Morphs                string
+	ImageURL              string
+	Description           string
+	Name                  string
+}
+
+type FailedRenderFieldNames struct {
+	Image    string
+	Genes    string
+	Error    string
+	Attempts string
+	FailedAt string
+}
+
+type HistoryFieldNames struct {
+	ObjId            string
+	Type             string
+	TokenId          string
+	DateTime         string
+	AttributeChanged string
+	Price            string
+	NewGene          string
+	OldGene          string
+	Character        string
+	Wallet           string
+}
+
+type RankHistoryFieldNames struct {
+	TokenId      string
+	Rank         string
+	PreviousRank string
+	DateTime     string
+}
+
+type WebhookFieldNames struct {
+	Id            string
+	Type          string
+	TokenId       string
+	Wallet        string
+	MinRankChange string
+	SetName       string
+	CreatedAt     string
+}
+
+type WebhookDeliveryFieldNames struct {
+	WebhookId string
+	CreatedAt string
 }
@@ -0,0 +1,21 @@
+package structs
+
+// FieldSpec describes how a polymorph field can be used in API queries
+type FieldSpec struct {
+	Name      string
+	Type      string
+	Operators []string
+	Sortable  bool
+	Public    bool
+	Facetable bool
+}
+
+// SupportsOperator returns whether the field can be filtered with the operator
+func (f FieldSpec) SupportsOperator(operator string) bool {
+	for _, op := range f.Operators {
+		if op == operator {
+			return true
+		}
+	}
+	return false
+}
@@ -9,5 +9,4 @@ type MintsMutex struct {
 	Mutex     sync.Mutex
 	Mints     []models.PolymorphEntity
 	TokensMap map[string]bool
-	Documents []interface{}
 }
@@ -0,0 +1,44 @@
+package structs
+
+// MorphSpend is the number of morphs and scrambles and the ETH spent on them
+type MorphSpend struct {
+	Morphs         int64   `bson:"morphs" json:"morphs"`
+	Scrambles      int64   `bson:"scrambles" json:"scrambles"`
+	MorphVolume    float64 `bson:"morphvolume" json:"morphVolume"`
+	ScrambleVolume float64 `bson:"scramblevolume" json:"scrambleVolume"`
+	Volume         float64 `bson:"volume" json:"volume"`
+}
+
+// MorphEconomicsSummary is the spend on morphs and scrambles of the whole collection
+type MorphEconomicsSummary struct {
+	MorphSpend `bson:",inline"`
+	// Tokens is the number of polymorphs which were morphed or scrambled at least once
+	Tokens               int64   `bson:"tokens" json:"tokens"`
+	AverageSpendPerToken float64 `bson:"-" json:"averageSpendPerToken"`
+}
+
+// DailyMorphStats is the spend on morphs and scrambles of a UTC day
+type DailyMorphStats struct {
+	Day        string `bson:"_id" json:"day"`
+	MorphSpend `bson:",inline"`
+	Tokens     int64 `bson:"tokens" json:"tokens"`
+}
+
+// TokenMorphSpend is the spend on morphs and scrambles of a polymorph
+type TokenMorphSpend struct {
+	TokenId    int `bson:"_id" json:"tokenid"`
+	MorphSpend `bson:",inline"`
+}
+
+// WalletMorphSpend is the spend on morphs and scrambles of a wallet
+type WalletMorphSpend struct {
+	Wallet     string `bson:"_id" json:"wallet"`
+	MorphSpend `bson:",inline"`
+}
+
+// TraitSlotMorphs is the number of morphs which changed a trait type and the ETH spent on them
+type TraitSlotMorphs struct {
+	TraitType string  `bson:"_id" json:"traitType"`
+	Morphs    int64   `bson:"morphs" json:"morphs"`
+	Volume    float64 `bson:"volume" json:"volume"`
+}
@@ -0,0 +1,28 @@
+package structs
+
+import "go.mongodb.org/mongo-driver/bson"
+
+// PolymorphsPage is the response of the polymorphs endpoint. NextCursor is empty on the last page
+type PolymorphsPage struct {
+	Total      int64          `json:"total"`
+	NextCursor string         `json:"nextCursor"`
+	Filters    AppliedFilters `json:"filters"`
+	Results    []bson.M       `json:"results"`
+	// Facets contains the counts per value of the requested facet fields
+	Facets map[string][]FacetCount `json:"facets,omitempty"`
+}
+
+// FacetCount is the number of polymorphs with the value in a facet field
+type FacetCount struct {
+	Value interface{} `bson:"_id" json:"value"`
+	Count int64       `bson:"count" json:"count"`
+}
+
+// AppliedFilters are the filter, search, selected fields and sort used for a page of polymorphs
+type AppliedFilters struct {
+	Filter    string `json:"filter,omitempty"`
+	Search    string `json:"search,omitempty"`
+	Select    string `json:"select,omitempty"`
+	SortField string `json:"sortField"`
+	SortDir   string `json:"sortDir"`
+}
@@ -3,9 +3,23 @@ package structs
 type QueryParams struct {
 	Take      string `schema:"take"`
 	Page      string `schema:"page"`
+	Cursor    string `schema:"cursor"`
 	SortField string `schema:"sortField"`
 	SortDir   string `schema:"sortDir"`
 	Select    string `schema:"select"`
 	Filter    string `schema:"filter"`
 	Search    string `schema:"search"`
+	Facets    string `schema:"facets"`
+}
+
+type ActivityQueryParams struct {
+	Take      string `schema:"take"`
+	Cursor    string `schema:"cursor"`
+	Type      string `schema:"type"`
+	Attribute string `schema:"attribute"`
+	Character string `schema:"character"`
+	From      string `schema:"from"`
+	To        string `schema:"to"`
+	MinPrice  string `schema:"minPrice"`
+	MaxPrice  string `schema:"maxPrice"`
 }
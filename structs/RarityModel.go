@@ -0,0 +1,7 @@
+package structs
+
+// RarityModel contains the set configuration used by the rarity score formula
+type RarityModel struct {
+	CombosMap map[string]int
+	HandsMap  map[string][]string
+}
@@ -0,0 +1,28 @@
+package structs
+
+// ReplayReport compares the collections of a replayed block range with the live collections
+type ReplayReport struct {
+	FromBlock int64
+	ToBlock   int64
+	// Entities compares the rarity entities without their rank, Ranks only compares the ranks
+	Entities DocumentsDiff
+	Ranks    DocumentsDiff
+	History  DocumentsDiff
+}
+
+// DocumentsDiff is the difference between the live and replayed documents, matched by key
+type DocumentsDiff struct {
+	Compared    int
+	Matching    int
+	OnlyLive    []string
+	OnlyReplay  []string
+	Differences []FieldDifference
+}
+
+// FieldDifference is a field which has a different value in the live and replayed document with the key
+type FieldDifference struct {
+	Key    string
+	Field  string
+	Live   interface{}
+	Replay interface{}
+}
@@ -0,0 +1,18 @@
+package structs
+
+// TokenMetadata is the ERC-721 token metadata served to marketplaces
+type TokenMetadata struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Image       string           `json:"image"`
+	ExternalUrl string           `json:"external_url"`
+	Attributes  []TokenAttribute `json:"attributes"`
+}
+
+// TokenAttribute is a single attribute in the OpenSea metadata format. DisplayType is empty for string traits
+type TokenAttribute struct {
+	DisplayType string      `json:"display_type,omitempty"`
+	TraitType   string      `json:"trait_type"`
+	Value       interface{} `json:"value"`
+	MaxValue    interface{} `json:"max_value,omitempty"`
+}
@@ -0,0 +1,35 @@
+package structs
+
+// TraitCatalog contains every trait type with its possible values and every set of a collection
+type TraitCatalog struct {
+	Types []TraitTypeCatalog `json:"types"`
+	Sets  []SetCatalog       `json:"sets"`
+}
+
+// TraitTypeCatalog contains the values a trait type can be decoded into, in gene order
+type TraitTypeCatalog struct {
+	TraitType string       `json:"traitType"`
+	Values    []TraitValue `json:"values"`
+}
+
+// TraitValue is a single value of a trait type with the sets it belongs to
+type TraitValue struct {
+	Value string   `json:"value"`
+	Sets  []string `json:"sets"`
+}
+
+// SetCatalog describes a set. Size is the number of matching traits needed to complete the set, 0 if the set can't be completed.
+//
+// Hands are the hand accessories which match the set
+type SetCatalog struct {
+	Name  string   `json:"name"`
+	Size  int      `json:"size"`
+	Hands []string `json:"hands"`
+}
+
+// TraitSuggestion is an autocomplete result. TraitType is empty for sets
+type TraitSuggestion struct {
+	Text      string `json:"text"`
+	Kind      string `json:"kind"`
+	TraitType string `json:"traitType,omitempty"`
+}
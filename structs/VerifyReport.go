@@ -0,0 +1,18 @@
+package structs
+
+// VerifyReport lists the polymorphs whose stored state doesn't match the chain or their own history
+type VerifyReport struct {
+	Tokens     int
+	Mismatches []VerifyMismatch
+	// Repaired is the number of mismatches fixed in repair mode
+	Repaired int
+}
+
+// VerifyMismatch is a failed check of a polymorph. Stored is the value in the database and Expected the value the check derived it should have
+type VerifyMismatch struct {
+	TokenId  int
+	Check    string
+	Stored   string
+	Expected string
+	Repaired bool
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"rarity-backend/config"
+	"rarity-backend/dlt"
+	"rarity-backend/repositories"
+	"rarity-backend/services"
+	"rarity-backend/structs"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/joho/godotenv"
+)
+
+// verify checks the stored polymorphs of every collection against the chain and their history and prints the mismatches, see services.VerifyCollection.
+//
+// Usage: rarity-backend verify [-repair] [-collection polymorphs] [-max 100]
+//
+// Exits with status 1 if a mismatch is left unrepaired, so it can run as a scheduled check.
+func verify(args []string) {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	repairFlag := flags.Bool("repair", false, "repair outdated genes and counters")
+	collectionFlag := flags.String("collection", "", "name of the collection, every configured collection if it's empty")
+	maxFlag := flags.Int("max", 100, "maximum number of mismatches printed per collection")
+	flags.Parse(args)
+
+	err := godotenv.Load()
+	if err != nil {
+		log.Fatal("Error loading .env file: " + err.Error())
+	}
+
+	ethClient := connectToEthereum()
+	unrepaired := 0
+	verified := 0
+	for _, collection := range config.NewCollectionsConfig() {
+		if *collectionFlag != "" && collection.Name != *collectionFlag {
+			continue
+		}
+		verified++
+
+		chain, err := dlt.NewContractChain(ethClient.Client, common.HexToAddress(collection.ContractAddress))
+		if err != nil {
+			log.Fatal(err)
+		}
+		configService := config.NewConfigService(collection.ConfigPath)
+		report, err := services.VerifyCollection(chain, configService, config.RarityModels[collection.RarityModel], collection.DBInfo, repositories.NewMongoRepositories(collection.DBInfo), *repairFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		printVerifyReport(report, collection.Name, *maxFlag)
+		unrepaired += len(report.Mismatches) - report.Repaired
+	}
+
+	if verified == 0 {
+		log.Fatalf("Unknown collection %v", *collectionFlag)
+	}
+	if unrepaired > 0 {
+		os.Exit(1)
+	}
+}
+
+// printVerifyReport prints the report with at most max mismatches
+func printVerifyReport(report structs.VerifyReport, collectionName string, max int) {
+	fmt.Printf("Verified %v polymorphs of collection %v: %v mismatches, %v repaired\n", report.Tokens, collectionName, len(report.Mismatches), report.Repaired)
+	for i, mismatch := range report.Mismatches {
+		if i == max {
+			fmt.Printf("  ... %v more mismatches\n", len(report.Mismatches)-max)
+			break
+		}
+		repaired := ""
+		if mismatch.Repaired {
+			repaired = " (repaired)"
+		}
+		fmt.Printf("  #%v %v: stored %v, expected %v%v\n", mismatch.TokenId, mismatch.Check, mismatch.Stored, mismatch.Expected, repaired)
+	}
+}
@@ -0,0 +1,101 @@
+package webhooks
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// reservedNetworks are the networks webhooks can't be delivered to: private, loopback, link-local, shared, multicast, documentation and other special purpose ranges
+var reservedNetworks = parseNetworks(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.0.2.0/24",
+	"192.88.99.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"198.51.100.0/24",
+	"203.0.113.0/24",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+	"::/128",
+	"::1/128",
+	"64:ff9b::/96",
+	"100::/64",
+	"2001::/23",
+	"2001:db8::/32",
+	"2002::/16",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+)
+
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// IsPublicIP returns whether the address is reachable on the public internet. IPv4 mapped IPv6 addresses are checked as IPv4 addresses
+func IsPublicIP(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	if len(ip) != net.IPv4len && len(ip) != net.IPv6len {
+		return false
+	}
+	for _, network := range reservedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// newClient returns the http client of the webhook requests.
+//
+// The addresses are checked after the host is resolved, right before connecting, so hosts which resolve to internal addresses or change their records after the registration can't be used to reach internal services.
+// Redirects aren't followed for the same reason, the redirect response fails the delivery
+func newClient(allowed func(ip net.IP) bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: REQUEST_TIMEOUT,
+		Control: func(network string, address string, conn syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !allowed(ip) {
+				return fmt.Errorf("webhook address %v isn't public", host)
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: REQUEST_TIMEOUT,
+		Transport: &http.Transport{
+			// Proxies would be dialed instead of the webhook, so the address check wouldn't apply
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   REQUEST_TIMEOUT,
+			ResponseHeaderTimeout: REQUEST_TIMEOUT,
+			MaxIdleConnsPerHost:   2,
+			IdleConnTimeout:       90 * time.Second,
+		},
+		CheckRedirect: func(request *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
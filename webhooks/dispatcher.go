@@ -0,0 +1,227 @@
+// Package webhooks calls the registered webhooks of a collection when the indexer commits the events they watch.
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"rarity-backend/models"
+	"rarity-backend/structs"
+	"strconv"
+	"time"
+)
+
+const (
+	TYPE_MORPH = "morph"
+	TYPE_RANK  = "rank"
+	TYPE_SET   = "set"
+)
+
+const ID_HEADER = "X-Webhook-Id"
+const DELIVERY_HEADER = "X-Webhook-Delivery"
+const TIMESTAMP_HEADER = "X-Webhook-Timestamp"
+const SIGNATURE_HEADER = "X-Webhook-Signature"
+
+const MAX_ATTEMPTS = 5
+const RETRY_DELAY = time.Second
+const REQUEST_TIMEOUT = 5 * time.Second
+const QUEUE_SIZE = 1000
+const DEFAULT_WORKERS = 4
+
+// Store contains the registered webhooks and the delivery log
+type Store interface {
+	// Matching returns the webhooks which watch the event
+	Matching(event structs.FeedEvent) ([]models.Webhook, error)
+	// MinRankChange returns the smallest rank change watched by a rank webhook. Returns false if there are no rank webhooks
+	MinRankChange() (int, bool, error)
+	RecordDelivery(delivery models.WebhookDelivery) error
+}
+
+// Payload is the json body sent to the webhooks
+type Payload struct {
+	WebhookId  string            `json:"webhookId"`
+	DeliveryId string            `json:"deliveryId"`
+	Event      structs.FeedEvent `json:"event"`
+}
+
+// delivery is a queued delivery. It's queued again with a doubled delay after every failed attempt which can be retried
+type delivery struct {
+	webhook models.Webhook
+	body    []byte
+	result  models.WebhookDelivery
+	delay   time.Duration
+}
+
+// Dispatcher delivers the events to the webhooks which watch them.
+//
+// Deliveries run in the background, so slow webhooks don't delay the indexing. Failed requests are retried with exponential backoff and every delivery is recorded in the store.
+// The workers don't wait for the retries, so failing webhooks only hold a worker for the duration of a request.
+//
+// Webhooks are only delivered to public addresses, see newClient.
+//
+// A nil *Dispatcher is valid and delivers nothing
+type Dispatcher struct {
+	store      Store
+	client     *http.Client
+	queue      chan delivery
+	retryDelay time.Duration
+}
+
+// NewDispatcher creates a dispatcher and starts its delivery workers
+func NewDispatcher(store Store, workers int) *Dispatcher {
+	return newDispatcher(store, newClient(IsPublicIP), RETRY_DELAY, workers)
+}
+
+func newDispatcher(store Store, client *http.Client, retryDelay time.Duration, workers int) *Dispatcher {
+	d := &Dispatcher{
+		store:      store,
+		client:     client,
+		queue:      make(chan delivery, QUEUE_SIZE),
+		retryDelay: retryDelay,
+	}
+	for i := 0; i < workers; i++ {
+		go d.worker()
+	}
+	return d
+}
+
+// Dispatch queues the deliveries of the event to the webhooks which watch it
+func (d *Dispatcher) Dispatch(event structs.FeedEvent) {
+	if d == nil {
+		return
+	}
+	webhooks, err := d.store.Matching(event)
+	if err != nil {
+		log.Println("Could not load webhooks: " + err.Error())
+		return
+	}
+	for _, webhook := range webhooks {
+		job := delivery{
+			webhook: webhook,
+			result: models.WebhookDelivery{
+				Id:        NewToken(),
+				WebhookId: webhook.Id,
+				EventType: event.Type,
+				TokenId:   event.TokenId,
+				CreatedAt: time.Now().UTC(),
+			},
+			delay: d.retryDelay,
+		}
+		job.body, _ = json.Marshal(Payload{WebhookId: webhook.Id, DeliveryId: job.result.Id, Event: event})
+		job.result.Payload = string(job.body)
+		d.enqueue(job)
+	}
+}
+
+// enqueue queues the delivery. The delivery fails if the queue is full
+func (d *Dispatcher) enqueue(job delivery) {
+	select {
+	case d.queue <- job:
+	default:
+		job.result.Error = "delivery queue is full"
+		job.result.CompletedAt = time.Now().UTC()
+		d.record(job.result)
+	}
+}
+
+// MinRankChange returns the smallest rank change watched by a rank webhook. Returns false if there are no rank webhooks
+func (d *Dispatcher) MinRankChange() (int, bool) {
+	if d == nil {
+		return 0, false
+	}
+	min, ok, err := d.store.MinRankChange()
+	if err != nil {
+		log.Println("Could not load rank webhooks: " + err.Error())
+		return 0, false
+	}
+	return min, ok
+}
+
+func (d *Dispatcher) worker() {
+	for job := range d.queue {
+		d.attempt(job)
+	}
+}
+
+// attempt posts the event to the webhook. The delivery is recorded once it succeeds, fails with a client error or runs out of attempts, otherwise it's queued again after the retry delay
+func (d *Dispatcher) attempt(job delivery) {
+	job.result.Attempts++
+	statusCode, err := d.post(job.webhook, job.result.Id, job.body)
+	job.result.StatusCode = statusCode
+
+	retry := false
+	if err == nil && statusCode >= 200 && statusCode < 300 {
+		job.result.Delivered = true
+		job.result.Error = ""
+	} else {
+		if err != nil {
+			job.result.Error = err.Error()
+		} else {
+			job.result.Error = fmt.Sprintf("webhook responded with status %v", statusCode)
+		}
+		// Client errors and redirects won't be fixed by retrying, except rate limiting
+		retry = err != nil || statusCode >= 500 || statusCode == http.StatusTooManyRequests
+	}
+
+	if retry && job.result.Attempts < MAX_ATTEMPTS {
+		delay := job.delay
+		job.delay *= 2
+		time.AfterFunc(delay, func() { d.enqueue(job) })
+		return
+	}
+	job.result.CompletedAt = time.Now().UTC()
+	d.record(job.result)
+}
+
+// post sends the signed body to the webhook and returns the response status
+func (d *Dispatcher) post(webhook models.Webhook, deliveryId string, body []byte) (int, error) {
+	request, err := http.NewRequest(http.MethodPost, webhook.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(ID_HEADER, webhook.Id)
+	request.Header.Set(DELIVERY_HEADER, deliveryId)
+	request.Header.Set(TIMESTAMP_HEADER, timestamp)
+	request.Header.Set(SIGNATURE_HEADER, Sign(webhook.Secret, timestamp, body))
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	response.Body.Close()
+	return response.StatusCode, nil
+}
+
+func (d *Dispatcher) record(result models.WebhookDelivery) {
+	if err := d.store.RecordDelivery(result); err != nil {
+		log.Println("Could not record webhook delivery: " + err.Error())
+	}
+}
+
+// Sign returns the signature of a webhook request: "sha256=" followed by the hex encoded HMAC-SHA256 of the timestamp, a dot and the body, keyed with the webhook secret.
+//
+// Receivers should recompute the signature and reject old timestamps, so recorded requests can't be replayed
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewToken returns a random hex token used for ids and secrets
+func NewToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		log.Fatal(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,164 @@
+package webhooks
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"rarity-backend/models"
+	"rarity-backend/structs"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testStore returns the webhook for every event and sends the recorded deliveries to the channel
+type testStore struct {
+	webhook    models.Webhook
+	deliveries chan models.WebhookDelivery
+}
+
+func (s testStore) Matching(event structs.FeedEvent) ([]models.Webhook, error) {
+	return []models.Webhook{s.webhook}, nil
+}
+
+func (s testStore) MinRankChange() (int, bool, error) {
+	return 0, false, nil
+}
+
+func (s testStore) RecordDelivery(delivery models.WebhookDelivery) error {
+	s.deliveries <- delivery
+	return nil
+}
+
+func allowAll(ip net.IP) bool {
+	return true
+}
+
+// deliverTo delivers an event to the url and returns the recorded delivery
+func deliverTo(t *testing.T, client *http.Client, url string) models.WebhookDelivery {
+	store := testStore{
+		webhook:    models.Webhook{Id: "webhook", Url: url, Secret: "0123456789abcdef"},
+		deliveries: make(chan models.WebhookDelivery, 1),
+	}
+	dispatcher := newDispatcher(store, client, time.Millisecond, 1)
+	dispatcher.Dispatch(structs.FeedEvent{Type: TYPE_MORPH, TokenId: 1})
+
+	select {
+	case delivery := <-store.deliveries:
+		return delivery
+	case <-time.After(5 * time.Second):
+		t.Fatal("The delivery wasn't recorded")
+	}
+	return models.WebhookDelivery{}
+}
+
+func TestSign(t *testing.T) {
+	signature := Sign("0123456789abcdef", "1700000000", []byte(`{"event":"morph"}`))
+	if signature != "sha256=b6eee0e06cf1a744b38b92df710c3dfb9d81dfafa8ed9a7618f0df56a14c1e8a" {
+		t.Errorf("Got %v, expected the HMAC-SHA256 of the timestamp, a dot and the body", signature)
+	}
+}
+
+func TestDeliverySignature(t *testing.T) {
+	var valid atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		valid.Store(r.Header.Get(SIGNATURE_HEADER) == Sign("0123456789abcdef", r.Header.Get(TIMESTAMP_HEADER), body) &&
+			r.Header.Get(ID_HEADER) == "webhook")
+	}))
+	defer server.Close()
+
+	delivery := deliverTo(t, newClient(allowAll), server.URL)
+	if !delivery.Delivered || valid.Load() != true {
+		t.Errorf("Got delivery %+v, expected a delivered request with a valid signature", delivery)
+	}
+}
+
+func TestDeliveryRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		statuses  []int
+		delivered bool
+		attempts  int
+	}{
+		{"success", []int{200}, true, 1},
+		{"server error is retried", []int{500, 503, 204}, true, 3},
+		{"rate limiting is retried", []int{429, 200}, true, 2},
+		{"client error isn't retried", []int{400, 200}, false, 1},
+		{"not found isn't retried", []int{404}, false, 1},
+		{"redirect isn't followed", []int{302, 200}, false, 1},
+		{"attempts are limited", []int{500, 500, 500, 500, 500, 200}, false, MAX_ATTEMPTS},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var requests int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt32(&requests, 1)
+				status := test.statuses[len(test.statuses)-1]
+				if int(n) <= len(test.statuses) {
+					status = test.statuses[n-1]
+				}
+				if status == 302 {
+					w.Header().Set("Location", "/redirected")
+				}
+				w.WriteHeader(status)
+			}))
+			defer server.Close()
+
+			delivery := deliverTo(t, newClient(allowAll), server.URL)
+			sent := atomic.LoadInt32(&requests)
+			if delivery.Delivered != test.delivered || delivery.Attempts != test.attempts || int(sent) != test.attempts {
+				t.Errorf("Got delivered %v after %v attempts and %v requests, expected delivered %v after %v attempts",
+					delivery.Delivered, delivery.Attempts, sent, test.delivered, test.attempts)
+			}
+		})
+	}
+}
+
+func TestDeliveryToInternalAddress(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+	}))
+	defer server.Close()
+
+	delivery := deliverTo(t, newClient(IsPublicIP), server.URL)
+	if delivery.Delivered || atomic.LoadInt32(&requests) != 0 || !strings.Contains(delivery.Error, "isn't public") {
+		t.Errorf("Got delivery %+v, expected the loopback address to be rejected", delivery)
+	}
+}
+
+func TestIsPublicIP(t *testing.T) {
+	tests := []struct {
+		ip     string
+		public bool
+	}{
+		{"8.8.8.8", true},
+		{"2606:4700:4700::1111", true},
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"172.32.0.1", true},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"224.0.0.1", false},
+		{"::1", false},
+		{"::", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:169.254.169.254", false},
+		{"64:ff9b::a9fe:a9fe", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+	}
+
+	for _, test := range tests {
+		if public := IsPublicIP(net.ParseIP(test.ip)); public != test.public {
+			t.Errorf("%v: got public %v, expected %v", test.ip, public, test.public)
+		}
+	}
+}